@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"path"
+	"strings"
+	"text/template"
+)
+
+// nolint: gochecknoglobals
+var tmpl = template.Must(template.New("").Funcs(template.FuncMap{
+	"arg": func(f field) string {
+		if f.Array {
+			return "sdk.PostgreSQLArray(req." + f.GoName + ")"
+		}
+
+		return "req." + f.GoName
+	},
+	"dest": func(prefix string, f field) string {
+		if f.Array {
+			return "sdk.PostgreSQLArray(&" + prefix + f.GoName + ")"
+		}
+
+		return "&" + prefix + f.GoName
+	},
+}).Parse(`// Code generated by sqlgen. DO NOT EDIT.
+
+package {{ .Package }}
+
+import (
+	"context"
+{{- if .Time }}
+	"time"
+{{- end }}
+
+	"{{ .CQRS }}"
+	"github.com/gunawanwijaya/forest/sdk"
+)
+
+type PostgreSQLCore interface {
+{{- range .Queries }}
+	{{ .Name }}(ctx context.Context, req {{ .Name }}Request) (res {{ .Name }}Response, err error)
+{{- end }}
+}
+{{ range $q := .Queries }}
+type {{ $q.Name }}Request struct {
+{{- range $q.Params }}
+	{{ .GoName }} {{ .GoType }}{{ if $q.Named }} ` + "`" + `db:"{{ .Name }}"` + "`" + `{{ end }}
+{{- end }}
+}
+type {{ $q.Name }}Response struct {
+{{- if eq $q.Arity "exec" }}
+	RowsAffected int
+{{- else }}
+{{- if eq $q.Arity "many" }}
+	List []{{ $q.Name }}Response
+{{ end }}
+{{- range $q.Columns }}
+	{{ .GoName }} {{ .GoType }}
+{{- end }}
+{{- end }}
+}
+
+func (x *instance) {{ $q.Name }}(ctx context.Context, req {{ $q.Name }}Request) (res {{ $q.Name }}Response, err error) {
+{{- if and $q.Named (eq $q.Arity "exec") }}
+	err = new(sdk.SQL).
+		BoxExec(new(sdk.SQL).NamedExecContext(ctx, x.SQLConn, sdk.SQLDialectPostgreSQL, {{ $.CQRSName }}.SQL_{{ $q.File }}, req)).
+		Scan(&res.RowsAffected, nil)
+{{- else if $q.Named }}
+	err = new(sdk.SQL).
+		{{ if eq $q.Arity "one" }}BoxQueryRow{{ else }}BoxQuery{{ end }}(new(sdk.SQL).NamedQueryContext(ctx, x.SQLConn, sdk.SQLDialectPostgreSQL, {{ $.CQRSName }}.SQL_{{ $q.File }}, req)).
+		{{- template "scan" $q }}
+{{- else if eq $q.Arity "exec" }}
+	err = new(sdk.SQL).
+		BoxExec(x.SQLConn.ExecContext(ctx, {{ $.CQRSName }}.SQL_{{ $q.File }},{{ range $q.Params }}
+			{{ arg . }},{{ end }}
+		)).
+		Scan(&res.RowsAffected, nil)
+{{- else }}
+	err = new(sdk.SQL).
+		{{ if eq $q.Arity "one" }}BoxQueryRow{{ else }}BoxQuery{{ end }}(x.SQLConn.QueryContext(ctx, {{ $.CQRSName }}.SQL_{{ $q.File }},{{ range $q.Params }}
+			{{ arg . }},{{ end }}
+		)).
+		{{- template "scan" $q }}
+{{- end }}
+	return res, err
+}
+{{ end }}
+{{- define "scan" }}
+{{- if eq .Arity "one" }}
+		Scan({{ range $i, $c := .Columns }}{{ if $i }}, {{ end }}{{ dest "res." $c }}{{ end }})
+{{- else }}
+		Scan(func(i int) sdk.List {
+			res.List = append(res.List, {{ .Name }}Response{})
+			return sdk.List{
+{{- range .Columns }}
+				{{ dest "res.List[i]." . }},
+{{- end }}
+			}
+		})
+{{- end }}
+{{- end }}`))
+
+type templateQuery struct {
+	Name, File, Arity string
+	Named             bool
+	Params, Columns   []field
+}
+
+func generate(pkg, cqrsPath string, queries []*query) ([]byte, error) {
+	data := struct {
+		Package, CQRS, CQRSName string
+		Time                    bool
+		Queries                 []templateQuery
+	}{Package: pkg, CQRS: cqrsPath, CQRSName: path.Base(cqrsPath)}
+
+	for _, q := range queries {
+		for _, f := range append(append([]field{}, q.params...), q.columns...) {
+			data.Time = data.Time || strings.Contains(f.GoType, "time.Time")
+		}
+
+		data.Queries = append(data.Queries, templateQuery{q.name, q.file, q.arity, q.named, q.params, q.columns})
+	}
+
+	buf := new(bytes.Buffer)
+	if err := tmpl.Execute(buf, data); err != nil {
+		return nil, err
+	}
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format: %w\n%s", err, buf.String())
+	}
+
+	return src, nil
+}
@@ -0,0 +1,166 @@
+// sqlgen turns annotated SQL files into typed Go repository methods.
+//
+// Each query file should start with an annotation of its method name and
+// result arity, parameters are either positional or named (see sdk.SQL.Named)
+// and their types are inferred from the CREATE TABLE statements of the schema
+// (migration) directory.
+//
+//	-- name: NewUser :exec
+//	INSERT INTO core.users (id, email) VALUES (:id, :email)
+//
+// Arity is one of `:exec` (BoxExec), `:one` (BoxQueryRow) or `:many`
+// (BoxQuery). When a parameter or a column is unable to be inferred, it can be
+// declared explicitly.
+//
+//	-- param: $1 keyword text
+//	-- param: :keyword text
+//	-- column: total bigint
+//
+// Usage:
+//
+//	//go:generate go run github.com/gunawanwijaya/forest/cmd/generate/sqlgen -schema ../migration -query ../cqrs -package postgresql_core -out postgresql_core_gen.go
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+func main() {
+	var (
+		schemaDir = flag.String("schema", "", "directory of migration files (*.up.sql)")
+		queryDir  = flag.String("query", "", "directory of annotated query files (*.sql)")
+		cqrsPath  = flag.String("cqrs", "", "import path of the package embedding the query files, default to module path of -query")
+		pkg       = flag.String("package", "", "package name of the generated file")
+		out       = flag.String("out", "", "output file")
+	)
+
+	flag.Parse()
+
+	if err := run(*schemaDir, *queryDir, *cqrsPath, *pkg, *out); err != nil {
+		fmt.Fprintln(os.Stderr, "sqlgen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(schemaDir, queryDir, cqrsPath, pkg, out string) error {
+	if schemaDir == "" || queryDir == "" || pkg == "" || out == "" {
+		flag.Usage()
+
+		return fmt.Errorf("-schema, -query, -package and -out are required")
+	}
+
+	if cqrsPath == "" {
+		p, err := importPath(queryDir)
+		if err != nil {
+			return err
+		}
+
+		cqrsPath = p
+	}
+
+	schema, err := parseSchemaDir(schemaDir)
+	if err != nil {
+		return err
+	}
+
+	embedded, err := embeddedQueries(queryDir)
+	if err != nil {
+		return err
+	}
+
+	files, err := filepath.Glob(filepath.Join(queryDir, "*.sql"))
+	if err != nil {
+		return err
+	}
+
+	sort.Strings(files)
+
+	queries := make([]*query, 0, len(files))
+	for _, file := range files {
+		p, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		base := strings.TrimSuffix(filepath.Base(file), ".sql")
+		if _, ok := embedded["SQL_"+base]; !ok {
+			return fmt.Errorf("%s: not embedded as SQL_%s in %s", file, base, queryDir)
+		}
+
+		q, err := parseQuery(schema, base, string(p))
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+
+		queries = append(queries, q)
+	}
+
+	src, err := generate(pkg, cqrsPath, queries)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(out, src, 0o644)
+}
+
+// importPath resolve the import path of dir by looking up the nearest go.mod.
+func importPath(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	for root := abs; ; root = filepath.Dir(root) {
+		if p, err := os.ReadFile(filepath.Join(root, "go.mod")); err == nil {
+			for _, line := range strings.Split(string(p), "\n") {
+				if f := strings.Fields(line); len(f) == 2 && f[0] == "module" {
+					rel, err := filepath.Rel(root, abs)
+					if err != nil {
+						return "", err
+					}
+
+					return strings.TrimSuffix(f[1]+"/"+filepath.ToSlash(rel), "/."), nil
+				}
+			}
+		}
+
+		if filepath.Dir(root) == root {
+			return "", fmt.Errorf("go.mod not found from %s", dir)
+		}
+	}
+}
+
+// embeddedQueries list the `SQL_*` variables declared in the go files of dir.
+func embeddedQueries(dir string) (map[string]struct{}, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+
+	names := map[string]struct{}{}
+
+	for _, file := range files {
+		p, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, line := range strings.Split(string(p), "\n") {
+			f := strings.Fields(line)
+			if len(f) > 1 && f[0] == "var" {
+				f = f[1:]
+			}
+
+			if len(f) > 0 && strings.HasPrefix(f[0], "SQL_") {
+				names[f[0]] = struct{}{}
+			}
+		}
+	}
+
+	return names, nil
+}
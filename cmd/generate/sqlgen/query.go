@@ -0,0 +1,615 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gunawanwijaya/forest/sdk"
+)
+
+// nolint: gochecknoglobals
+var (
+	reAnnotationName   = regexp.MustCompile(`^--\s*name:\s*(\w+)\s+:(exec|one|many)\s*$`)
+	reAnnotationParam  = regexp.MustCompile(`^--\s*param:\s*(?:\$(\d+)\s+(\w+)|[:@](\w+))\s+(.+?)\s*$`)
+	reAnnotationColumn = regexp.MustCompile(`^--\s*column:\s*(\w+)\s+(.+?)\s*$`)
+
+	reTableRef   = regexp.MustCompile(`(?i)\b(?:FROM|JOIN|INTO|UPDATE)\s+([\w."]+)`)
+	reTableAlias = regexp.MustCompile(`(?i)^\s+(?:AS\s+)?(\w+)`)
+	reCast       = regexp.MustCompile(`(?i)\$(\d+)\s*::\s*((?:double\s+precision|character\s+varying|time(?:stamp)?\s+with(?:out)?\s+time\s+zone|\w+)(?:\[\])?)`)
+	reIn         = regexp.MustCompile(`(?i)([\w."]+)\s+IN\s*\(\s*\$(\d+)\s*\)`)
+	reAny        = regexp.MustCompile(`(?i)([\w."]+)\s*(?:=|<>|!=)\s*ANY\s*\(\s*\$(\d+)\s*\)`)
+	reCompareL   = regexp.MustCompile(`(?i)([\w."]+)\s*(?:=|<>|!=|<=|>=|<|>|\bI?LIKE\b)\s*\$(\d+)`)
+	reCompareR   = regexp.MustCompile(`(?i)\$(\d+)\s*(?:=|<>|!=|<=|>=|<|>)\s*([\w."]+)`)
+	reLimit      = regexp.MustCompile(`(?i)\b(LIMIT|OFFSET)\s+\$(\d+)`)
+	reIdentifier = regexp.MustCompile(`^[\w."]+$`)
+
+	// keywords that might follow a table name and should not be read as alias.
+	aliasKeywords = map[string]struct{}{
+		"WHERE": {}, "ORDER": {}, "GROUP": {}, "HAVING": {}, "LIMIT": {}, "OFFSET": {}, "JOIN": {},
+		"INNER": {}, "LEFT": {}, "RIGHT": {}, "FULL": {}, "CROSS": {}, "ON": {}, "USING": {}, "SET": {},
+		"VALUES": {}, "DEFAULT": {}, "RETURNING": {}, "FOR": {}, "UNION": {}, "NATURAL": {}, "SELECT": {},
+	}
+
+	initialisms = map[string]string{
+		"id": "ID", "ip": "IP", "url": "URL", "uri": "URI", "uuid": "UUID", "json": "JSON",
+		"api": "API", "sql": "SQL", "http": "HTTP", "https": "HTTPS",
+	}
+)
+
+type field struct {
+	Name   string // column or parameter name in sql
+	GoName string
+	GoType string
+	Array  bool
+}
+
+type query struct {
+	file    string
+	name    string
+	arity   string
+	named   bool
+	params  []field
+	columns []field
+}
+
+type tableRef struct {
+	alias string
+	table *table
+}
+
+// parseQuery will parse the annotation, validate the syntax and infer the type
+// of each parameter and column of src.
+func parseQuery(s schema, file, src string) (*query, error) {
+	q := &query{file: file}
+	explicitParams, explicitColumns := map[int]field{}, map[string]string{}
+
+	body := strings.TrimSuffix(strings.TrimSpace(sdk.SQL{}.RemoveComment(src)), ";")
+	names := sdk.SQL{}.NamedParameters(body)
+
+	// named parameters are rewritten into positional to infer the types, the
+	// generated method will bind the request using SQL.Named
+	if q.named = len(names) > 0; q.named {
+		values := map[string]interface{}{}
+		for _, name := range names {
+			values[name] = nil
+		}
+
+		positional, _, err := sdk.SQL{}.Named(sdk.SQLDialectPostgreSQL, body, values)
+		if err != nil {
+			return nil, err
+		}
+
+		body = positional
+	}
+
+	for _, line := range strings.Split(src, "\n") {
+		line = strings.TrimSpace(line)
+
+		if m := reAnnotationName.FindStringSubmatch(line); m != nil {
+			q.name, q.arity = m[1], m[2]
+		} else if m := reAnnotationParam.FindStringSubmatch(line); m != nil && m[1] != "" {
+			i, _ := strconv.Atoi(m[1])
+			explicitParams[i] = field{Name: m[2], GoType: m[4]}
+		} else if m != nil {
+			for i, name := range names {
+				if name == m[3] {
+					explicitParams[i+1] = field{Name: m[3], GoType: m[4]}
+				}
+			}
+		} else if m := reAnnotationColumn.FindStringSubmatch(line); m != nil {
+			explicitColumns[m[1]] = m[2]
+		}
+	}
+
+	if q.name == "" {
+		return nil, fmt.Errorf("missing annotation `-- name: <Name> :exec|:one|:many`")
+	}
+
+	if err := validate(q.arity, body); err != nil {
+		return nil, err
+	}
+
+	refs, err := tableRefs(s, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if q.params, err = inferParams(refs, body, names, explicitParams); err != nil {
+		return nil, err
+	}
+
+	if q.arity != "exec" {
+		if q.columns, err = inferColumns(refs, body, explicitColumns); err != nil {
+			return nil, err
+		}
+	}
+
+	return q, nil
+}
+
+// validate check the syntax of body that is able to be checked without a
+// database, the same check is done by the round-robin SQLConn at runtime.
+func validate(arity, body string) error {
+	x := sdk.SQL{}
+
+	switch {
+	case body == "":
+		return fmt.Errorf("empty query")
+	case x.IsMultipleCommand(body):
+		return fmt.Errorf("%w", sdk.ErrMultipleCommands)
+	case !x.IsValidCommand(body):
+		return fmt.Errorf("%w: %q", sdk.ErrInvalidCommand, body)
+	case arity == "exec" && !x.IsDMLCommand(body) && !x.IsDDLCommand(body):
+		return fmt.Errorf("%w: :exec expect INSERT, UPDATE, DELETE or DDL", sdk.ErrInvalidCommand)
+	case arity != "exec" && (x.IsDMLCommand(body) || x.IsDDLCommand(body)):
+		return fmt.Errorf("%w: :%s expect SELECT", sdk.ErrInvalidCommand, arity)
+	}
+
+	depth, quote := 0, byte(0)
+
+	for i := 0; i < len(body); i++ {
+		switch c := body[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			if depth--; depth < 0 {
+				return fmt.Errorf("unbalanced parentheses at %d", i)
+			}
+		}
+	}
+
+	if quote != 0 {
+		return fmt.Errorf("unterminated quoted literal")
+	} else if depth != 0 {
+		return fmt.Errorf("unbalanced parentheses")
+	}
+
+	return nil
+}
+
+func tableRefs(s schema, body string) ([]tableRef, error) {
+	refs := []tableRef{}
+
+	// the alias is matched apart, a keyword following the table (e.g. `FROM a
+	// JOIN b`) must not be consumed as the alias of the previous match
+	text := stripLiterals(body)
+	for _, loc := range reTableRef.FindAllStringSubmatchIndex(text, -1) {
+		name := text[loc[2]:loc[3]]
+
+		t, ok := s.table(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown table %s", name)
+		}
+
+		alias := ""
+		if m := reTableAlias.FindStringSubmatch(text[loc[1]:]); m != nil {
+			alias = strings.ToLower(m[1])
+		}
+
+		if _, ok := aliasKeywords[strings.ToUpper(alias)]; ok {
+			alias = ""
+		}
+
+		refs = append(refs, tableRef{alias, t})
+	}
+
+	return refs, nil
+}
+
+// resolve a column reference of `column` or `alias.column`.
+func resolve(refs []tableRef, ref string) (column, error) {
+	ref = unquote(ref)
+	qualifier, name := "", ref
+
+	if i := strings.LastIndex(ref, "."); i > -1 {
+		qualifier, name = ref[:i], ref[i+1:]
+	}
+
+	found := []column{}
+
+	for _, r := range refs {
+		if qualifier != "" && qualifier != r.alias && qualifier != r.table.name &&
+			!strings.HasSuffix(r.table.name, "."+qualifier) {
+			continue
+		}
+
+		if c, ok := r.table.column(name); ok {
+			found = append(found, c)
+		}
+	}
+
+	switch {
+	case len(found) < 1:
+		return column{}, fmt.Errorf("unknown column %s", ref)
+	case len(found) > 1 && qualifier == "":
+		return column{}, fmt.Errorf("ambiguous column %s", ref)
+	}
+
+	return found[0], nil
+}
+
+// inferParams of positional body, names is not empty when the original query
+// use named parameters.
+func inferParams(refs []tableRef, body string, names []string, explicit map[int]field) ([]field, error) {
+	text := stripLiterals(body)
+	inferred, casts := map[int]column{}, map[int]string{}
+	set := func(i string, c column) {
+		n, _ := strconv.Atoi(i)
+		if _, ok := inferred[n]; !ok {
+			inferred[n] = c
+		}
+	}
+
+	for _, m := range reCast.FindAllStringSubmatch(text, -1) {
+		n, _ := strconv.Atoi(m[1])
+		casts[n] = strings.ToLower(strings.Join(strings.Fields(m[2]), " "))
+	}
+
+	// INSERT INTO t (a, b) VALUES ($1, $2)
+	if upper := strings.ToUpper(text); strings.HasPrefix(upper, "INSERT") && len(refs) > 0 {
+		cols, rest := enclosed(text, strings.Index(text, "("))
+		if i := strings.Index(strings.ToUpper(rest), "VALUES"); i > -1 && cols != "" {
+			values, _ := enclosed(rest, i+strings.Index(rest[i:], "("))
+			names, exprs := splitTopLevel(cols, ','), splitTopLevel(values, ',')
+
+			for j := 0; j < len(names) && j < len(exprs); j++ {
+				if expr := strings.TrimSpace(exprs[j]); strings.HasPrefix(expr, "$") {
+					name := unquote(strings.TrimSpace(names[j]))
+
+					c, ok := refs[0].table.column(name)
+					if !ok {
+						return nil, fmt.Errorf("unknown column %s of %s", name, refs[0].table.name)
+					}
+
+					set(strings.SplitN(expr[1:], ":", 2)[0], c)
+				}
+			}
+		}
+	}
+
+	// a slice of named `IN (:ids)` is expanded by SQL.Named, while a positional
+	// `IN ($1)` is bound as a single array and compared as `scalar IN (array)`
+	for _, m := range reIn.FindAllStringSubmatch(text, -1) {
+		if len(names) < 1 {
+			return nil, fmt.Errorf("$%s: `IN ($%s)` is unable to bind a slice, use `= ANY($%s)`", m[2], m[2], m[2])
+		}
+
+		c, err := resolve(refs, m[1])
+		if err != nil {
+			return nil, err
+		}
+
+		c.typ += "[]"
+		set(m[2], c)
+	}
+
+	for _, m := range reAny.FindAllStringSubmatch(text, -1) {
+		c, err := resolve(refs, m[1])
+		if err != nil {
+			return nil, err
+		}
+
+		c.typ += "[]"
+		set(m[2], c)
+	}
+
+	for _, m := range reCompareL.FindAllStringSubmatch(text, -1) {
+		if c, err := resolve(refs, m[1]); err == nil {
+			set(m[2], c)
+		} else if !isKeyword(m[1]) {
+			return nil, err
+		}
+	}
+
+	for _, m := range reCompareR.FindAllStringSubmatch(text, -1) {
+		if c, err := resolve(refs, m[2]); err == nil {
+			set(m[1], c)
+		} else if !isKeyword(m[2]) {
+			return nil, err
+		}
+	}
+
+	for _, m := range reLimit.FindAllStringSubmatch(text, -1) {
+		set(m[2], column{strings.ToLower(m[1]), "bigint", false})
+	}
+
+	max := 0
+	for _, m := range regexp.MustCompile(`\$(\d+)`).FindAllStringSubmatch(text, -1) {
+		if n, _ := strconv.Atoi(m[1]); n > max {
+			max = n
+		}
+	}
+
+	params, seen := make([]field, 0, max), map[string]int{}
+
+	for i := 1; i <= max; i++ {
+		label := "$" + strconv.Itoa(i)
+		c, ok := inferred[i]
+
+		if typ, found := casts[i]; found {
+			c.typ, c.nullable, ok = typ, false, true
+		}
+
+		if i <= len(names) {
+			label, c.name = ":"+names[i-1], names[i-1]
+		}
+
+		if e, found := explicit[i]; found {
+			c, ok = column{e.Name, e.GoType, false}, true
+		}
+
+		if !regexp.MustCompile(`\$` + strconv.Itoa(i) + `\b`).MatchString(text) {
+			return nil, fmt.Errorf("parameter %s is not used", label)
+		} else if !ok {
+			return nil, fmt.Errorf("unable to infer type of %s, declare `-- param: %s <name> <type>`", label, label)
+		} else if c.name == "" {
+			return nil, fmt.Errorf("unable to infer name of %s, declare `-- param: %s <name> <type>`", label, label)
+		}
+
+		f, err := newField(c)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", label, err)
+		}
+
+		if seen[f.GoName]++; seen[f.GoName] > 1 {
+			f.GoName += strconv.Itoa(seen[f.GoName])
+		}
+
+		params = append(params, f)
+	}
+
+	return params, nil
+}
+
+func inferColumns(refs []tableRef, body string, explicit map[string]string) ([]field, error) {
+	text := stripLiterals(body)
+	upper := strings.ToUpper(text)
+	start := strings.Index(upper, "SELECT") + len("SELECT")
+	end := topLevelIndex(upper, "FROM", start)
+
+	if end < 0 {
+		end = len(text)
+	}
+
+	list := body[start:end]
+	if strings.HasPrefix(strings.ToUpper(strings.TrimSpace(list)), "DISTINCT ") {
+		list = strings.TrimSpace(list)[len("DISTINCT "):]
+	}
+
+	columns := []field{}
+	add := func(c column) error {
+		if typ, ok := explicit[c.name]; ok {
+			c.typ = typ
+		}
+
+		f, err := newField(c)
+		if err != nil {
+			return fmt.Errorf("column %s: %w", c.name, err)
+		}
+
+		columns = append(columns, f)
+
+		return nil
+	}
+
+	for _, item := range splitTopLevel(list, ',') {
+		expr, alias := splitAlias(strings.TrimSpace(item))
+
+		switch {
+		case expr == "*" || strings.HasSuffix(expr, ".*"):
+			for _, r := range refs {
+				q := strings.TrimSuffix(expr, "*")
+				if q != "" && unquote(q) != r.alias+"." && unquote(q) != r.table.name+"." {
+					continue
+				}
+
+				for _, c := range r.table.columns {
+					if err := add(c); err != nil {
+						return nil, err
+					}
+				}
+			}
+		case strings.Contains(expr, "::"):
+			i := strings.LastIndex(expr, "::")
+			name := alias
+			if name == "" && reIdentifier.MatchString(strings.TrimSpace(expr[:i])) {
+				name = unquote(strings.TrimSpace(expr[:i]))
+				name = name[strings.LastIndex(name, ".")+1:]
+			}
+
+			if err := add(column{name, strings.ToLower(strings.TrimSpace(expr[i+2:])), false}); err != nil {
+				return nil, err
+			}
+		case reIdentifier.MatchString(expr):
+			c, err := resolve(refs, expr)
+			if err != nil {
+				return nil, err
+			}
+
+			if alias != "" {
+				c.name = alias
+			}
+
+			if err = add(c); err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(strings.ToUpper(expr), "COUNT("):
+			if err := add(column{alias, "bigint", false}); err != nil {
+				return nil, err
+			}
+		default:
+			if _, ok := explicit[alias]; !ok || alias == "" {
+				return nil, fmt.Errorf("unable to infer type of %q, use `expr::type AS name` or `-- column: <name> <type>`", item)
+			}
+
+			if err := add(column{alias, "", false}); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return columns, nil
+}
+
+func newField(c column) (field, error) {
+	if c.name == "" {
+		return field{}, fmt.Errorf("unnamed, use `AS <name>`")
+	}
+
+	typ, array, err := goType(c.typ)
+	if err != nil {
+		return field{}, err
+	}
+
+	if c.nullable && !strings.HasPrefix(typ, "[]") {
+		typ = "*" + typ
+	}
+
+	return field{c.name, goName(c.name), typ, array}, nil
+}
+
+// goType map PostgreSQL type into Go type, array is true for PostgreSQL array.
+func goType(pg string) (typ string, array bool, err error) {
+	pg = strings.TrimSpace(strings.ToLower(pg))
+	if i := strings.Index(pg, "("); i > -1 {
+		if j := strings.Index(pg, ")"); j > i {
+			pg = strings.TrimSpace(pg[:i] + pg[j+1:])
+		}
+	}
+
+	if strings.HasSuffix(pg, "[]") {
+		elem, _, err := goType(strings.TrimSuffix(pg, "[]"))
+
+		return "[]" + elem, true, err
+	}
+
+	switch pg {
+	case "bytea", "json", "jsonb":
+		return "[]byte", false, nil
+	case "text", "varchar", "character varying", "char", "character", "citext", "name", "uuid",
+		"numeric", "decimal", "interval", "inet", "cidr":
+		return "string", false, nil
+	case "smallint", "int2", "smallserial":
+		return "int16", false, nil
+	case "integer", "int", "int4", "serial":
+		return "int32", false, nil
+	case "bigint", "int8", "bigserial":
+		return "int64", false, nil
+	case "boolean", "bool":
+		return "bool", false, nil
+	case "real", "float4":
+		return "float32", false, nil
+	case "double precision", "float8":
+		return "float64", false, nil
+	case "timestamptz", "timestamp", "timestamp with time zone", "timestamp without time zone",
+		"date", "time", "timetz", "time with time zone", "time without time zone":
+		return "time.Time", false, nil
+	}
+
+	return "", false, fmt.Errorf("unsupported type %q", pg)
+}
+
+func goName(name string) string {
+	b := new(strings.Builder)
+
+	for _, part := range strings.Split(strings.ToLower(name), "_") {
+		if part == "" {
+			continue
+		} else if v, ok := initialisms[part]; ok {
+			_, _ = b.WriteString(v)
+		} else {
+			_, _ = b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+		}
+	}
+
+	return b.String()
+}
+
+// splitAlias split `expr AS alias` or `expr alias` of a select list item.
+func splitAlias(item string) (expr, alias string) {
+	f := strings.Fields(item)
+
+	switch l := len(f); {
+	case l >= 3 && strings.EqualFold(f[l-2], "AS"):
+		return strings.Join(f[:l-2], " "), unquote(f[l-1])
+	case l >= 2 && reIdentifier.MatchString(f[l-1]) && !strings.Contains(f[l-1], ".") &&
+		(strings.HasSuffix(f[l-2], ")") || reIdentifier.MatchString(f[l-2])):
+		return strings.Join(f[:l-1], " "), unquote(f[l-1])
+	}
+
+	return item, ""
+}
+
+// enclosed return the content between the parenthesis at start and its pair,
+// and the rest after it.
+func enclosed(s string, start int) (inner, rest string) {
+	if start < 0 || start >= len(s) || s[start] != '(' {
+		return "", s
+	}
+
+	for i, depth := start, 0; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth == 0 {
+				return s[start+1 : i], s[i+1:]
+			}
+		}
+	}
+
+	return "", s
+}
+
+// topLevelIndex find keyword in s outside parentheses, starting from start.
+func topLevelIndex(s, keyword string, start int) int {
+	re := regexp.MustCompile(`\b` + keyword + `\b`)
+
+	for _, loc := range re.FindAllStringIndex(s, -1) {
+		if loc[0] < start {
+			continue
+		}
+
+		if strings.Count(s[start:loc[0]], "(") == strings.Count(s[start:loc[0]], ")") {
+			return loc[0]
+		}
+	}
+
+	return -1
+}
+
+// stripLiterals replace the content of quoted literals with spaces, keeping
+// the position of every other character.
+func stripLiterals(s string) string {
+	b, quote := []byte(s), byte(0)
+
+	for i := range b {
+		switch c := b[i]; {
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+			b[i] = ' '
+		case c == '\'':
+			quote = c
+		}
+	}
+
+	return string(b)
+}
+
+// isKeyword is true when s is a keyword or a literal instead of a column.
+func isKeyword(s string) bool {
+	_, ok := aliasKeywords[strings.ToUpper(s)]
+	_, errN := strconv.ParseFloat(s, 64)
+
+	return ok || errN == nil || strings.EqualFold(s, "AND") || strings.EqualFold(s, "OR") ||
+		strings.EqualFold(s, "NOT") || strings.EqualFold(s, "NULL")
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gunawanwijaya/forest/sdk"
+)
+
+// nolint: gochecknoglobals
+var (
+	reCreateTable = regexp.MustCompile(`(?is)^CREATE\s+(?:UNLOGGED\s+)?TABLE\s+(?:IF\s+NOT\s+EXISTS\s+)?([\w."]+)\s*\((.*)\)$`)
+	reAlterAdd    = regexp.MustCompile(`(?is)^ALTER\s+TABLE\s+(?:IF\s+EXISTS\s+)?(?:ONLY\s+)?([\w."]+)\s+ADD\s+(?:COLUMN\s+)?(?:IF\s+NOT\s+EXISTS\s+)?(.*)$`)
+	reDropTable   = regexp.MustCompile(`(?is)^DROP\s+TABLE\s+(?:IF\s+EXISTS\s+)?([\w."]+)`)
+	reVersion     = regexp.MustCompile(`^(\d+)_`)
+
+	// constraintKeywords end the type of a column definition.
+	constraintKeywords = map[string]struct{}{
+		"NOT": {}, "NULL": {}, "PRIMARY": {}, "UNIQUE": {}, "REFERENCES": {}, "DEFAULT": {},
+		"CHECK": {}, "CONSTRAINT": {}, "GENERATED": {}, "COLLATE": {},
+	}
+
+	// tableConstraints start a table constraint instead of a column definition.
+	tableConstraints = map[string]struct{}{
+		"PRIMARY": {}, "UNIQUE": {}, "FOREIGN": {}, "CONSTRAINT": {}, "CHECK": {}, "EXCLUDE": {}, "LIKE": {},
+	}
+)
+
+type column struct {
+	name     string
+	typ      string
+	nullable bool
+}
+
+type table struct {
+	name    string
+	columns []column
+}
+
+func (t *table) column(name string) (column, bool) {
+	for _, c := range t.columns {
+		if c.name == name {
+			return c, true
+		}
+	}
+
+	return column{}, false
+}
+
+// schema holds tables by both qualified (`core.users`) and unqualified name.
+type schema map[string]*table
+
+func (s schema) table(name string) (*table, bool) {
+	t, ok := s[unquote(name)]
+
+	return t, ok
+}
+
+func (s schema) put(t *table) {
+	s[t.name] = t
+	if i := strings.LastIndex(t.name, "."); i > -1 {
+		s[t.name[i+1:]] = t
+	}
+}
+
+func (s schema) drop(name string) {
+	name = unquote(name)
+	if t, ok := s[name]; ok {
+		for k, v := range s {
+			if v == t {
+				delete(s, k)
+			}
+		}
+	}
+}
+
+// parseSchemaDir read all `*.up.sql` in order of its version.
+func parseSchemaDir(dir string) (schema, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.up.sql"))
+	if err != nil {
+		return nil, err
+	}
+
+	version := func(file string) int64 {
+		m := reVersion.FindStringSubmatch(filepath.Base(file))
+		if m == nil {
+			return 0
+		}
+
+		v, _ := strconv.ParseInt(m[1], 10, 64)
+
+		return v
+	}
+
+	sort.Slice(files, func(i, j int) bool { return version(files[i]) < version(files[j]) })
+
+	s := schema{}
+
+	for _, file := range files {
+		p, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		if err = s.parse(string(p)); err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+	}
+
+	return s, nil
+}
+
+func (s schema) parse(src string) error {
+	for _, stmt := range splitTopLevel(sdk.SQL{}.RemoveComment(src), ';') {
+		stmt = strings.TrimSpace(stmt)
+
+		if m := reCreateTable.FindStringSubmatch(stmt); m != nil {
+			t := &table{name: unquote(m[1])}
+
+			for _, def := range splitTopLevel(m[2], ',') {
+				c, ok, err := parseColumn(def)
+				if err != nil {
+					return fmt.Errorf("table %s: %w", t.name, err)
+				} else if ok {
+					t.columns = append(t.columns, c)
+				}
+			}
+
+			s.put(t)
+		} else if m := reAlterAdd.FindStringSubmatch(stmt); m != nil {
+			t, ok := s.table(m[1])
+			if !ok {
+				return fmt.Errorf("alter unknown table %s", m[1])
+			}
+
+			if c, ok, err := parseColumn(m[2]); err != nil {
+				return fmt.Errorf("table %s: %w", t.name, err)
+			} else if ok {
+				t.columns = append(t.columns, c)
+			}
+		} else if m := reDropTable.FindStringSubmatch(stmt); m != nil {
+			s.drop(m[1])
+		}
+	}
+
+	return nil
+}
+
+// parseColumn parse a column definition, ok is false when def is a table
+// constraint.
+func parseColumn(def string) (c column, ok bool, err error) {
+	f := strings.Fields(def)
+	if len(f) < 1 {
+		return c, false, nil
+	} else if _, ok := tableConstraints[strings.ToUpper(f[0])]; ok {
+		return c, false, nil
+	} else if len(f) < 2 {
+		return c, false, fmt.Errorf("invalid column definition %q", def)
+	}
+
+	typ := []string{}
+	for _, w := range f[1:] {
+		if _, ok := constraintKeywords[strings.ToUpper(w)]; ok {
+			break
+		}
+
+		typ = append(typ, w)
+	}
+
+	upper := strings.ToUpper(strings.Join(f, " "))
+	nullable := !strings.Contains(upper, "NOT NULL") && !strings.Contains(upper, "PRIMARY KEY")
+
+	return column{unquote(f[0]), strings.ToLower(strings.Join(typ, " ")), nullable}, true, nil
+}
+
+// splitTopLevel split s by sep, outside of parentheses and quoted literals.
+func splitTopLevel(s string, sep byte) (parts []string) {
+	depth, quote, start := 0, byte(0), 0
+
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == sep && depth == 0:
+			parts, start = append(parts, s[start:i]), i+1
+		}
+	}
+
+	if rest := strings.TrimSpace(s[start:]); rest != "" {
+		parts = append(parts, s[start:])
+	}
+
+	return parts
+}
+
+func unquote(name string) string { return strings.ToLower(strings.ReplaceAll(name, `"`, "")) }
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gunawanwijaya/forest/sdk"
+	. "github.com/onsi/gomega"
+)
+
+// nolint: gochecknoglobals
+var update = flag.Bool("update", false, "update the golden files of testdata")
+
+func Test_Suite_SQLGen(t *testing.T) {
+	t.Run("Generate", test_Generate)
+	t.Run("InferColumns", test_InferColumns)
+	t.Run("InferParams", test_InferParams)
+	t.Run("ParseQuery", test_ParseQuery)
+	t.Run("Validate", test_Validate)
+}
+
+// testSchema is the schema of testdata/schema.
+func testSchema(t *testing.T) schema {
+	s, err := parseSchemaDir(filepath.Join("testdata", "schema"))
+	NewWithT(t).Expect(err).To(Succeed())
+
+	return s
+}
+
+func test_Generate(t *testing.T) {
+	t.Parallel()
+
+	Expect := NewWithT(t).Expect
+	out := filepath.Join(t.TempDir(), "repository_gen.go")
+	golden := filepath.Join("testdata", "repository_gen.go.golden")
+
+	Expect(run(filepath.Join("testdata", "schema"), filepath.Join("testdata", "query"),
+		"example.com/app/query", "repository", out)).To(Succeed())
+
+	p, err := os.ReadFile(out)
+	Expect(err).To(Succeed())
+
+	if *update {
+		Expect(os.WriteFile(golden, p, 0o644)).To(Succeed())
+	}
+
+	expect, err := os.ReadFile(golden)
+	Expect(err).To(Succeed())
+	Expect(string(p)).To(Equal(string(expect)), "run `go test -run Test_Suite_SQLGen/Generate -update` after changing the template")
+
+	// every query file must be embedded by the package of -query
+	dir := t.TempDir()
+	Expect(os.WriteFile(filepath.Join(dir, "query_x.sql"), []byte("-- name: X :one\nSELECT 1 AS x"), 0o644)).To(Succeed())
+	Expect(run(filepath.Join("testdata", "schema"), dir, "example.com/app/query", "repository", out)).
+		To(MatchError(ContainSubstring("not embedded as SQL_query_x")))
+}
+
+func test_ParseQuery(t *testing.T) {
+	t.Parallel()
+
+	Expect := NewWithT(t).Expect
+	s := testSchema(t)
+
+	for _, c := range []struct {
+		name   string
+		src    string
+		expect *query
+		err    string
+	}{
+		{
+			name: "named",
+			src:  "-- name: GetUser :one\nSELECT id, email FROM core.users WHERE email = :email",
+			expect: &query{file: "named", name: "GetUser", arity: "one", named: true,
+				params:  []field{{"email", "Email", "string", false}},
+				columns: []field{{"id", "ID", "[]byte", false}, {"email", "Email", "string", false}},
+			},
+		},
+		{
+			name: "positional",
+			src:  "-- name: DeleteUser :exec\n-- delete a user\nDELETE FROM core.users WHERE id = $1;",
+			expect: &query{file: "positional", name: "DeleteUser", arity: "exec",
+				params: []field{{"id", "ID", "[]byte", false}},
+			},
+		},
+		{
+			name: "annotation",
+			src: "-- name: SearchUsers :many\n-- param: :keyword text\n-- column: label text\n" +
+				"SELECT coalesce(name, email) AS label FROM core.users WHERE lower(email) LIKE lower(:keyword)",
+			expect: &query{file: "annotation", name: "SearchUsers", arity: "many", named: true,
+				params:  []field{{"keyword", "Keyword", "string", false}},
+				columns: []field{{"label", "Label", "string", false}},
+			},
+		},
+		{
+			name: "annotation-positional",
+			src:  "-- name: SearchUsers :many\n-- param: $1 keyword text\nSELECT id FROM core.users WHERE strpos(email, $1) > 0",
+			expect: &query{file: "annotation-positional", name: "SearchUsers", arity: "many",
+				params:  []field{{"keyword", "Keyword", "string", false}},
+				columns: []field{{"id", "ID", "[]byte", false}},
+			},
+		},
+		{name: "missing-annotation", src: "SELECT id FROM core.users", err: "missing annotation"},
+		{name: "invalid-arity", src: "-- name: X :exec\nSELECT id FROM core.users", err: sdk.ErrInvalidCommand.Error()},
+		{name: "unknown-table", src: "-- name: X :many\nSELECT id FROM core.nope", err: "unknown table core.nope"},
+	} {
+		q, err := parseQuery(s, c.name, c.src)
+		if c.err != "" {
+			Expect(err).To(MatchError(ContainSubstring(c.err)), c.name)
+			continue
+		}
+
+		Expect(err).To(Succeed(), c.name)
+		Expect(q).To(Equal(c.expect), c.name)
+	}
+}
+
+func test_Validate(t *testing.T) {
+	t.Parallel()
+
+	Expect := NewWithT(t).Expect
+
+	for _, c := range []struct {
+		arity, body, err string
+	}{
+		{"one", "SELECT id FROM t WHERE name = '(' AND (a OR b)", ""},
+		{"exec", "UPDATE t SET a = 1", ""},
+		{"exec", "CREATE INDEX t_a ON t (a)", ""},
+		{"one", "", "empty query"},
+		{"one", "SELECT 1; SELECT 2", sdk.ErrMultipleCommands.Error()},
+		{"exec", "VACUUM t", sdk.ErrInvalidCommand.Error()},
+		{"exec", "SELECT 1", ":exec expect INSERT, UPDATE, DELETE or DDL"},
+		{"many", "DELETE FROM t", ":many expect SELECT"},
+		{"one", "SELECT (1", "unbalanced parentheses"},
+		{"one", "SELECT 1)", "unbalanced parentheses at 8"},
+		{"one", "SELECT 'a", "unterminated quoted literal"},
+	} {
+		err := validate(c.arity, c.body)
+		if c.err == "" {
+			Expect(err).To(Succeed(), c.body)
+		} else {
+			Expect(err).To(MatchError(ContainSubstring(c.err)), c.body)
+		}
+	}
+}
+
+func test_InferParams(t *testing.T) {
+	t.Parallel()
+
+	Expect := NewWithT(t).Expect
+	s := testSchema(t)
+
+	for _, c := range []struct {
+		body     string
+		names    []string
+		explicit map[int]field
+		expect   []field
+		err      string
+	}{
+		{
+			body:   "SELECT id FROM core.users WHERE email = $1",
+			expect: []field{{"email", "Email", "string", false}},
+		},
+		{
+			body:   "SELECT id FROM core.users WHERE email = $1",
+			names:  []string{"address"},
+			expect: []field{{"address", "Address", "string", false}},
+		},
+		{
+			body:   "INSERT INTO core.users (id, email, name) VALUES ($1, $2, $3)",
+			expect: []field{{"id", "ID", "[]byte", false}, {"email", "Email", "string", false}, {"name", "Name", "*string", false}},
+		},
+		{
+			body:   "UPDATE core.users SET name = $1::text WHERE $2 > created_at",
+			expect: []field{{"name", "Name", "string", false}, {"created_at", "CreatedAt", "time.Time", false}},
+		},
+		{
+			body:   "SELECT id FROM core.users u WHERE u.id = ANY($1) OR u.email <> ANY($2)",
+			expect: []field{{"id", "ID", "[][]byte", true}, {"email", "Email", "[]string", true}},
+		},
+		{
+			// named `IN (:ids)` is expanded by SQL.Named
+			body:   "SELECT id FROM core.users u WHERE u.id IN ($1)",
+			names:  []string{"ids"},
+			expect: []field{{"ids", "Ids", "[][]byte", true}},
+		},
+		{body: "SELECT id FROM core.users u WHERE u.id IN ($1)", err: "use `= ANY($1)`"},
+		{
+			body:   "SELECT id FROM core.users LIMIT $1 OFFSET $2",
+			expect: []field{{"limit", "Limit", "int64", false}, {"offset", "Offset", "int64", false}},
+		},
+		{
+			body:   "SELECT s.id FROM core.sessions s JOIN core.users u ON u.id = s.user_id WHERE u.id = $1 OR s.id = $2",
+			expect: []field{{"id", "ID", "[]byte", false}, {"id", "ID2", "[]byte", false}},
+		},
+		{
+			body:     "SELECT id FROM core.users WHERE lower(email) = lower($1)",
+			explicit: map[int]field{1: {Name: "keyword", GoType: "text"}},
+			expect:   []field{{"keyword", "Keyword", "string", false}},
+		},
+		{body: "SELECT id FROM core.users WHERE lower(email) = lower($1)", err: "unable to infer type of $1"},
+		{body: "SELECT id FROM core.users WHERE email = $2", err: "parameter $1 is not used"},
+		{body: "SELECT id FROM core.users WHERE nope = $1", err: "unknown column nope"},
+		{body: "SELECT 1 FROM core.users JOIN core.sessions ON true WHERE id = $1", err: "ambiguous column id"},
+		{body: "INSERT INTO core.users (id, nope) VALUES ($1, $2)", err: "unknown column nope of core.users"},
+		{
+			body:   "SELECT id FROM core.users WHERE created_at > $1::timestamp  with time zone AND score < $2::INT[]",
+			expect: []field{{"created_at", "CreatedAt", "time.Time", false}, {"score", "Score", "[]int32", true}},
+		},
+		{body: "SELECT id FROM core.users WHERE $1::text IS NULL", err: "unable to infer name of $1"},
+		{body: "SELECT id FROM core.users WHERE score > $1::money", err: `$1: unsupported type "money"`},
+	} {
+		refs, err := tableRefs(s, c.body)
+		Expect(err).To(Succeed(), c.body)
+
+		params, err := inferParams(refs, c.body, c.names, c.explicit)
+		if c.err != "" {
+			Expect(err).To(MatchError(ContainSubstring(c.err)), c.body)
+			continue
+		}
+
+		Expect(err).To(Succeed(), c.body)
+		Expect(params).To(Equal(c.expect), c.body)
+	}
+}
+
+func test_InferColumns(t *testing.T) {
+	t.Parallel()
+
+	Expect := NewWithT(t).Expect
+	s := testSchema(t)
+
+	for _, c := range []struct {
+		body     string
+		explicit map[string]string
+		expect   []field
+		err      string
+	}{
+		{
+			body: "SELECT * FROM core.users",
+			expect: []field{
+				{"id", "ID", "[]byte", false}, {"email", "Email", "string", false}, {"name", "Name", "*string", false},
+				{"created_at", "CreatedAt", "time.Time", false}, {"score", "Score", "int32", false},
+			},
+		},
+		{
+			body: "SELECT s.*, u.email FROM core.sessions s JOIN core.users u ON u.id = s.user_id",
+			expect: []field{
+				{"id", "ID", "[]byte", false}, {"user_id", "UserID", "[]byte", false},
+				{"expired_at", "ExpiredAt", "time.Time", false}, {"email", "Email", "string", false},
+			},
+		},
+		{
+			body: "SELECT DISTINCT u.id, s.expired_at AS until, s.user_id owner FROM core.users u JOIN core.sessions s ON s.user_id = u.id",
+			expect: []field{
+				{"id", "ID", "[]byte", false}, {"until", "Until", "time.Time", false}, {"owner", "Owner", "[]byte", false},
+			},
+		},
+		{
+			body:   "SELECT name::text, score::bigint AS points, count(*) AS total FROM core.users GROUP BY name, score",
+			expect: []field{{"name", "Name", "string", false}, {"points", "Points", "int64", false}, {"total", "Total", "int64", false}},
+		},
+		{
+			body:     "SELECT coalesce(name, email) AS label, id FROM core.users",
+			explicit: map[string]string{"label": "text", "id": "uuid"},
+			expect:   []field{{"label", "Label", "string", false}, {"id", "ID", "string", false}},
+		},
+		{body: "SELECT coalesce(name, email) AS label FROM core.users", err: "unable to infer type of"},
+		{body: "SELECT count(*) FROM core.users", err: "unnamed, use `AS <name>`"},
+		{body: "SELECT id::money AS x FROM core.users", err: `column x: unsupported type "money"`},
+		{body: "SELECT nope FROM core.users", err: "unknown column nope"},
+	} {
+		refs, err := tableRefs(s, c.body)
+		Expect(err).To(Succeed(), c.body)
+
+		columns, err := inferColumns(refs, c.body, c.explicit)
+		if c.err != "" {
+			Expect(err).To(MatchError(ContainSubstring(c.err)), c.body)
+			continue
+		}
+
+		Expect(err).To(Succeed(), c.body)
+		Expect(columns).To(Equal(c.expect), c.body)
+	}
+}
@@ -0,0 +1,2 @@
+-- name: ExpireSessions :exec
+UPDATE core.sessions SET expired_at = now() WHERE user_id = ANY($1)
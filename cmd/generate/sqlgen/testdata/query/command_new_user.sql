@@ -0,0 +1,2 @@
+-- name: NewUser :exec
+INSERT INTO core.users (id, email, name) VALUES (:id, :email, :name)
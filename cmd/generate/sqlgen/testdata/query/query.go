@@ -0,0 +1,17 @@
+package query
+
+import _ "embed"
+
+var (
+	//go:embed command_expire_sessions.sql
+	SQL_command_expire_sessions string
+
+	//go:embed command_new_user.sql
+	SQL_command_new_user string
+
+	//go:embed query_get_user.sql
+	SQL_query_get_user string
+
+	//go:embed query_list_sessions.sql
+	SQL_query_list_sessions string
+)
@@ -0,0 +1,2 @@
+-- name: GetUser :one
+SELECT id, email, name, created_at FROM core.users WHERE email = $1
@@ -0,0 +1,6 @@
+-- name: ListSessions :many
+-- column: total bigint
+SELECT s.id, u.email, s.expired_at AS until, count(*) OVER () AS total
+FROM core.sessions s JOIN core.users u ON u.id = s.user_id
+WHERE u.id = :user_id AND s.expired_at > :after
+ORDER BY s.expired_at DESC LIMIT :limit
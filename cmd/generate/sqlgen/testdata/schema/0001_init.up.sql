@@ -0,0 +1,8 @@
+CREATE SCHEMA IF NOT EXISTS core;
+
+CREATE TABLE IF NOT EXISTS core.users (
+    id         BYTEA       NOT NULL PRIMARY KEY,
+    email      TEXT        NOT NULL UNIQUE,
+    name       TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS core.sessions (
+    id         BYTEA       NOT NULL PRIMARY KEY,
+    user_id    BYTEA       NOT NULL REFERENCES core.users (id),
+    expired_at TIMESTAMPTZ NOT NULL
+);
+
+ALTER TABLE core.users ADD COLUMN IF NOT EXISTS score INTEGER NOT NULL DEFAULT 0;
@@ -1 +1,3 @@
--- insert when login
\ No newline at end of file
+-- name: NewSession :exec
+-- insert when login
+INSERT INTO core.sessions (id, user_id, expired_at) VALUES (:id, :user_id, :expired_at)
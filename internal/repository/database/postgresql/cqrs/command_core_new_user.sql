@@ -1 +1,3 @@
--- insert when register
\ No newline at end of file
+-- name: NewUser :exec
+-- insert when register
+INSERT INTO core.users (id, email) VALUES (:id, :email)
@@ -2,6 +2,8 @@ package cqrs
 
 import (
 	"embed"
+
+	"github.com/gunawanwijaya/forest/sdk"
 )
 
 var (
@@ -16,3 +18,10 @@ var (
 	//go:embed query_core_get_product.sql
 	SQL_query_core_get_product string
 )
+
+// init register the name of each query for the span & metrics of sdk.SQL.WithTelemetry.
+func init() {
+	new(sdk.SQL).RegisterQueryName("command_core_new_session", SQL_command_core_new_session)
+	new(sdk.SQL).RegisterQueryName("command_core_new_user", SQL_command_core_new_user)
+	new(sdk.SQL).RegisterQueryName("query_core_get_product", SQL_query_core_get_product)
+}
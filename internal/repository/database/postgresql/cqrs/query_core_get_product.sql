@@ -1 +1,3 @@
--- select product
\ No newline at end of file
+-- name: GetProduct :many
+-- select product
+SELECT id, name FROM core.products ORDER BY id
@@ -0,0 +1,5 @@
+-- drop core schema
+DROP TABLE IF EXISTS core.products;
+DROP TABLE IF EXISTS core.sessions;
+DROP TABLE IF EXISTS core.users;
+DROP SCHEMA IF EXISTS core;
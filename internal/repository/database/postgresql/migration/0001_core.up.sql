@@ -0,0 +1,21 @@
+-- core schema of users, sessions and products
+CREATE SCHEMA IF NOT EXISTS core;
+
+CREATE TABLE IF NOT EXISTS core.users (
+    id         BYTEA       NOT NULL PRIMARY KEY,
+    email      TEXT        NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS core.sessions (
+    id         BYTEA       NOT NULL PRIMARY KEY,
+    user_id    BYTEA       NOT NULL REFERENCES core.users (id),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expired_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS core.products (
+    id         BYTEA       NOT NULL PRIMARY KEY,
+    name       TEXT        NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
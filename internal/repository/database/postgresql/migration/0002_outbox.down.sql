@@ -0,0 +1,2 @@
+-- drop core outbox
+DROP TABLE IF EXISTS core.outbox;
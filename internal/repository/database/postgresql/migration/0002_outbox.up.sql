@@ -0,0 +1,17 @@
+-- transactional outbox of core events, see sdk.SQLOutbox
+CREATE TABLE IF NOT EXISTS core.outbox (
+    id           BIGSERIAL   NOT NULL PRIMARY KEY,
+    topic        TEXT        NOT NULL,
+    key          BYTEA,
+    payload      BYTEA       NOT NULL,
+    headers      JSONB       NOT NULL DEFAULT '{}',
+    attempts     INT         NOT NULL DEFAULT 0,
+    last_error   TEXT,
+    available_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    published_at TIMESTAMPTZ,
+    dead_at      TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS outbox_pending ON core.outbox (id)
+    WHERE published_at IS NULL AND dead_at IS NULL;
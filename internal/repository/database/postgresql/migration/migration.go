@@ -0,0 +1,10 @@
+package migration
+
+import (
+	"embed"
+)
+
+var (
+	//go:embed *.sql
+	FS embed.FS
+)
@@ -0,0 +1,70 @@
+// Code generated by sqlgen. DO NOT EDIT.
+
+package postgresql_core
+
+import (
+	"context"
+	"time"
+
+	"github.com/gunawanwijaya/forest/internal/repository/database/postgresql/cqrs"
+	"github.com/gunawanwijaya/forest/sdk"
+)
+
+type PostgreSQLCore interface {
+	NewSession(ctx context.Context, req NewSessionRequest) (res NewSessionResponse, err error)
+	NewUser(ctx context.Context, req NewUserRequest) (res NewUserResponse, err error)
+	GetProduct(ctx context.Context, req GetProductRequest) (res GetProductResponse, err error)
+}
+
+type NewSessionRequest struct {
+	ID        []byte    `db:"id"`
+	UserID    []byte    `db:"user_id"`
+	ExpiredAt time.Time `db:"expired_at"`
+}
+type NewSessionResponse struct {
+	RowsAffected int
+}
+
+func (x *instance) NewSession(ctx context.Context, req NewSessionRequest) (res NewSessionResponse, err error) {
+	err = new(sdk.SQL).
+		BoxExec(new(sdk.SQL).NamedExecContext(ctx, x.SQLConn, sdk.SQLDialectPostgreSQL, cqrs.SQL_command_core_new_session, req)).
+		Scan(&res.RowsAffected, nil)
+	return res, err
+}
+
+type NewUserRequest struct {
+	ID    []byte `db:"id"`
+	Email string `db:"email"`
+}
+type NewUserResponse struct {
+	RowsAffected int
+}
+
+func (x *instance) NewUser(ctx context.Context, req NewUserRequest) (res NewUserResponse, err error) {
+	err = new(sdk.SQL).
+		BoxExec(new(sdk.SQL).NamedExecContext(ctx, x.SQLConn, sdk.SQLDialectPostgreSQL, cqrs.SQL_command_core_new_user, req)).
+		Scan(&res.RowsAffected, nil)
+	return res, err
+}
+
+type GetProductRequest struct {
+}
+type GetProductResponse struct {
+	List []GetProductResponse
+
+	ID   []byte
+	Name string
+}
+
+func (x *instance) GetProduct(ctx context.Context, req GetProductRequest) (res GetProductResponse, err error) {
+	err = new(sdk.SQL).
+		BoxQuery(x.SQLConn.QueryContext(ctx, cqrs.SQL_query_core_get_product)).
+		Scan(func(i int) sdk.List {
+			res.List = append(res.List, GetProductResponse{})
+			return sdk.List{
+				&res.List[i].ID,
+				&res.List[i].Name,
+			}
+		})
+	return res, err
+}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 	"time"
 
@@ -38,7 +39,13 @@ func Run() {
 	// ===========================================================================
 	// PREREQUISITE ==============================================================
 	{
-		logFile, err := os.CreateTemp(os.TempDir(), "")
+		logFile, err := sdk.OTel.NewFileWriter(&sdk.FileWriterConfiguration{
+			Filename:   filepath.Join(os.TempDir(), "app1", "app1.log"),
+			MaxSize:    100 << 20,
+			MaxAge:     24 * time.Hour,
+			MaxBackups: 7,
+			Compress:   true,
+		})
 		sdk.PanicIf(err != nil, err)
 		sdk.PanicIf(logFile == nil, "logFile is nil")
 
@@ -54,21 +61,30 @@ func Run() {
 
 	// ===========================================================================
 	// REPOSITORY ================================================================
+	conns := make([]sdk.SQLConn, 0)
+	for i, v := range s.Connection.Database.PostgreSQLCore {
+		conn, err := new(sdk.SQL).OpenWithDSN(ctx, v)
+		if err != nil && i == 0 {
+			log.Err(err).Msg("[app1] primary database is unavailable")
+
+			return
+		} else if err != nil {
+			log.Warn().Err(err).Int("replica", i).Msg("[app1] replica database is skipped")
+
+			continue
+		}
+		conns = append(conns, conn)
+	}
+	if len(conns) < 1 {
+		log.Error().Msg("[app1] no database is configured")
+
+		return
+	}
+
 	postgresql_core := postgresql_core.Must(ctx,
 		c.Repository.PostgreSqlCore,
 		postgresql_core.Dependency{
-			SQLConn: func() sdk.SQLConn {
-				conns := make([]sdk.SQLConn, 0)
-				for i, v := range s.Connection.Database.PostgreSQLCore {
-					conn, err := new(sdk.SQL).OpenWithDSN(ctx, v)
-					if i == 0 && (err != nil || conn == nil) {
-						break
-					}
-					conns = append(conns, conn)
-				}
-
-				return new(sdk.SQL).NewRoundRobin(ctx, conns...)
-			}(),
+			SQLConn: new(sdk.SQL).NewRoundRobin(ctx, conns...),
 		},
 	)
 
@@ -85,7 +101,11 @@ func Run() {
 	// ===========================================================================
 	// BUILD =====================================================================
 	mux := new(sdk.Mux).
-		Handle(http.MethodGet, "/", app1_http_get_homepage)
+		Handle(http.MethodGet, "/", sdk.Middleware(
+			sdk.MuxLogFields(nil),
+			sdk.OTel.NewOperation(ctx, "app1_http_get_homepage").Handler(app1_http_get_homepage),
+		))
+	mux.Middleware = sdk.MuxProfileLabels
 
 	srv := &http.Server{
 		Addr:    ":10001",
@@ -173,7 +193,7 @@ func Run() {
 type Secret struct {
 	Connection struct {
 		Database struct {
-			PostgreSQLCore []string
+			PostgreSQLCore []string `secret:""`
 		}
 	}
 }
@@ -13,6 +13,11 @@ var (
 
 	ErrTracerServiceNameRequired = errors.New("tracer: service name required")
 	ErrTracerEndpointRequired    = errors.New("tracer: endpoint required")
+	ErrMeterNameRequired         = errors.New("meter: name required")
+	ErrMeterEndpointRequired     = errors.New("meter: endpoint required")
+
+	ErrLogExporterNameRequired     = errors.New("log exporter: name required")
+	ErrLogExporterEndpointRequired = errors.New("log exporter: endpoint required")
 )
 
 func PanicIf(cond bool, v interface{}) {
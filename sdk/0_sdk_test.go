@@ -14,7 +14,29 @@ func Test_Suite_SDK(t *testing.T) {
 	t.Run("List", test_List)
 	t.Run("ListError", test_ListError)
 	t.Run("OpenTelemetry", test_OpenTelemetry)
+	t.Run("OpenTelemetryAdmin", test_OpenTelemetryAdmin)
+	t.Run("OpenTelemetryFile", test_OpenTelemetryFile)
+	t.Run("OpenTelemetryLogger", test_OpenTelemetryLogger)
+	t.Run("OpenTelemetryLogs", test_OpenTelemetryLogs)
+	t.Run("OpenTelemetryMeter", test_OpenTelemetryMeter)
+	t.Run("OpenTelemetrySetup", test_OpenTelemetrySetup)
+	t.Run("OpenTelemetryTracer", test_OpenTelemetryTracer)
 	t.Run("Parser", test_Parser)
+	t.Run("Redact", test_Redact)
+	t.Run("SQL", test_SQL)
+	t.Run("SQLKeyset", test_SQLKeyset)
+	t.Run("SQLMigration", test_SQLMigration)
+	t.Run("SQLMock", test_SQLMock)
+	t.Run("SQLNamed", test_SQLNamed)
+	t.Run("SQLOpen", test_SQLOpen)
+	t.Run("SQLOutbox", test_SQLOutbox)
+	t.Run("SQLSlowLog", test_SQLSlowLog)
+	t.Run("SQLTenant", test_SQLTenant)
+	t.Run("SQLPostgreSQL", test_SQLPostgreSQL)
+	t.Run("SQLPostgreSQLCopy", test_SQLPostgreSQLCopy)
+	t.Run("SQLPostgreSQLListener", test_SQLPostgreSQLListener)
+	t.Run("SQLPostgreSQLTypes", test_SQLPostgreSQLTypes)
+	t.Run("SQLTelemetry", test_SQLTelemetry)
 	// t.Run("PhoneNumber", test_PhoneNumber)
 	// t.Run("SourceError", test_SourceError)
 }
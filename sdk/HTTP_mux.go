@@ -523,6 +523,10 @@ func (m *muxMatcherPattern) Match(r *http.Request) bool {
 		set(r, ctxKeyNamedArgs{}, u)
 	}
 
+	if match {
+		set(r, ctxKeyPattern{}, m.Pattern)
+	}
+
 	return match
 }
 
@@ -573,6 +577,14 @@ func NamedArgsFromRequest(r *http.Request) url.Values {
 	return u
 }
 
+// PatternFromRequest is a helper function that extract the pattern of the
+// MuxMatcherPattern that matched the *http.Request, e.g. `/users/:id`.
+func PatternFromRequest(r *http.Request) string {
+	p, _ := get(r, ctxKeyPattern{}).(string)
+
+	return p
+}
+
 // PanicRecoveryFromRequest is a helper function that extract error value
 // when panic occurred, the value is saved to *http.Request after recovery
 // process and right before calling mux.PanicHandler.
@@ -616,4 +628,6 @@ func get(r *http.Request, key interface{}) interface{} {
 
 type ctxKeyNamedArgs struct{}
 
+type ctxKeyPattern struct{}
+
 type ctxKeyPanicRecovery struct{}
@@ -2,36 +2,43 @@ package sdk
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
-	"net"
 	"net/http"
 	"os"
-	"runtime"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	prometheus_client "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rs/zerolog"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/jaeger"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
 	"go.opentelemetry.io/otel/exporters/prometheus"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
 	"go.opentelemetry.io/otel/metric"
-	"go.opentelemetry.io/otel/metric/global"
 	"go.opentelemetry.io/otel/propagation"
-	"go.opentelemetry.io/otel/sdk/export/metric/aggregation"
-	metric_aggregator_histogram "go.opentelemetry.io/otel/sdk/metric/aggregator/histogram"
-	metric_controller_basic "go.opentelemetry.io/otel/sdk/metric/controller/basic"
-	metric_processor_basic "go.opentelemetry.io/otel/sdk/metric/processor/basic"
-	metric_selector_simple "go.opentelemetry.io/otel/sdk/metric/selector/simple"
+	sdk_metric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/exemplar"
 	sdk_resource "go.opentelemetry.io/otel/sdk/resource"
 	sdk_trace "go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
 	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/credentials"
 )
 
 type open_telemetry struct{}
@@ -49,86 +56,260 @@ var (
 // -----------------------------------------------------------------------------
 
 type TracerConfiguration struct {
+	// Name of the service & the tracer, default to OTEL_SERVICE_NAME.
 	Name   string
 	Jaeger struct{ URL string }
 	OTLP   struct {
 		GRPC struct{ URL string }
 		HTTP struct{ URL string }
+
+		// Insecure disable TLS, otherwise TLS (default to the system roots) is
+		// used to connect to the collector.
+		Insecure bool
+		TLS      *tls.Config
+
+		// Headers sent on each export, e.g. the API key of the collector.
+		Headers map[string]string
 	}
+
+	// Stdout write the spans as JSON into the writer, e.g. os.Stdout.
+	Stdout io.Writer
+
+	// Exporters in addition to the above, e.g. tracetest.NewInMemoryExporter().
+	Exporters []sdk_trace.SpanExporter
+
+	// SampleRatio of the root spans, a child span follow the sampling decision
+	// of its parent. Default to OTEL_TRACES_SAMPLER (and its argument) or always
+	// sample, a negative value will never sample the root spans. The ratio is
+	// changeable at runtime, see Tracer.SetSampleRatio.
+	SampleRatio float64
+
+	// Attributes of the resource in addition to the detected process, container,
+	// host, os and OTEL_RESOURCE_ATTRIBUTES.
+	Attributes []attribute.KeyValue
+
+	// DisableScrub will export the spans as is, otherwise the dsn passwords,
+	// bearer tokens & emails are masked, see ScrubSpanProcessor.
+	DisableScrub bool
 }
 
-func (open_telemetry) NewTracer(ctx context.Context, c *TracerConfiguration) *Tracer {
+// NewTracer create a Tracer that export into all configured exporters; when
+// none is configured, OTEL_TRACES_EXPORTER (otlp, console or none) is used
+// and the OTLP exporters also read OTEL_EXPORTER_OTLP_* environment variables.
+// The Tracer in the context is returned as is, without c being validated.
+func (open_telemetry) NewTracer(ctx context.Context, c *TracerConfiguration) (*Tracer, error) {
 	if t, ok := ctx.Value(tracerCtxKey{}).(*Tracer); ok && t != nil {
-		t.tracerProviderWrap.sdk = nil
+		// a copy without the provider, so that it is shutdown by its creator only
+		return &Tracer{t.Tracer, tracerProviderWrap{}, t.sampler}, nil
+	}
+
+	if c == nil {
+		return nil, fmt.Errorf("otel: %w", ErrTracerServiceNameRequired)
+	}
 
-		return t
+	name := c.Name
+	if name == "" {
+		name = os.Getenv("OTEL_SERVICE_NAME")
 	}
 
-	var spanExporter sdk_trace.SpanExporter
+	if name == "" {
+		return nil, fmt.Errorf("otel: %w", ErrTracerServiceNameRequired)
+	}
 
-	if c == nil {
-		return nil
-	} else if c.OTLP.GRPC.URL != "" {
-		spanExporter = otlptrace.NewUnstarted(
-			otlptracegrpc.NewClient(
-				otlptracegrpc.WithInsecure(),
-				otlptracegrpc.WithEndpoint(c.OTLP.GRPC.URL),
-			),
-		)
-	} else if c.OTLP.HTTP.URL != "" {
-		spanExporter = otlptrace.NewUnstarted(
-			otlptracehttp.NewClient(
-				otlptracehttp.WithInsecure(),
-				otlptracehttp.WithEndpoint(c.OTLP.HTTP.URL),
-				otlptracehttp.WithCompression(otlptracehttp.GzipCompression),
-			),
-		)
-	} else if c.Jaeger.URL != "" {
-		var err error
-
-		spanExporter, err = jaeger.New(jaeger.WithCollectorEndpoint(jaeger.WithEndpoint(c.Jaeger.URL)))
-		if err != nil {
-			return nil
+	spanExporters, err := otelSpanExporters(ctx, c)
+	if err != nil {
+		return nil, fmt.Errorf("otel: tracer: %w", err)
+	} else if len(spanExporters) < 1 {
+		return nil, fmt.Errorf("otel: %w", ErrTracerEndpointRequired)
+	}
+
+	opts := []sdk_trace.TracerProviderOption{
+		sdk_trace.WithResource(otelResource(ctx, name, c.Attributes...)),
+	}
+	for _, spanExporter := range spanExporters {
+		if c.DisableScrub {
+			opts = append(opts, sdk_trace.WithBatcher(spanExporter))
+		} else {
+			opts = append(opts, sdk_trace.WithSpanProcessor(ScrubSpanProcessor(sdk_trace.NewBatchSpanProcessor(spanExporter))))
 		}
-	} else {
-		return nil
-	}
-
-	resource := sdk_resource.NewWithAttributes(
-		semconv.SchemaURL,
-		semconv.TelemetrySDKLanguageGo,
-		semconv.HostArchKey.String(runtime.GOARCH),
-		semconv.ServiceNameKey.String(c.Name),
-		semconv.NetHostNameKey.String(func() string {
-			hostname, _ := os.Hostname()
-
-			return hostname
-		}()),
-		semconv.NetHostIPKey.StringSlice(func() []string {
-			ipList := make([]string, 0)
-			addrs, _ := net.InterfaceAddrs()
-
-			for _, addr := range addrs {
-				ipnet, ok := addr.(*net.IPNet)
-				if ok && !ipnet.IP.IsLoopback() && ipnet.IP.To4() != nil {
-					ipList = append(ipList, ipnet.IP.String())
+	}
+
+	sampler := new(tracerSampler)
+	sampler.set(otelSampleRatio(c.SampleRatio))
+	opts = append(opts, sdk_trace.WithSampler(sampler))
+
+	tp := sdk_trace.NewTracerProvider(opts...)
+
+	otel.SetTextMapPropagator(otelPropagator())
+	otel.SetTracerProvider(tp)
+
+	return &Tracer{tp.Tracer(name), tracerProviderWrap{tp}, sampler}, nil
+}
+
+// otelSampleRatio of the configuration, or OTEL_TRACES_SAMPLER & its argument.
+func otelSampleRatio(ratio float64) float64 {
+	if ratio < 0 {
+		return 0
+	} else if ratio > 0 {
+		return ratio
+	}
+
+	switch os.Getenv("OTEL_TRACES_SAMPLER") {
+	case "always_off", "parentbased_always_off":
+		return 0
+	case "traceidratio", "parentbased_traceidratio":
+		if ratio, err := strconv.ParseFloat(os.Getenv("OTEL_TRACES_SAMPLER_ARG"), 64); err == nil {
+			return ratio
+		}
+	}
+
+	return 1
+}
+
+// tracerSampler is a parent based ratio sampler, the ratio is changeable at
+// runtime via Tracer.SetSampleRatio.
+type tracerSampler struct {
+	state atomic.Pointer[tracerSamplerState]
+}
+
+type tracerSamplerState struct {
+	ratio   float64
+	sampler sdk_trace.Sampler
+}
+
+func (s *tracerSampler) set(ratio float64) {
+	ratio = max(0, min(1, ratio))
+	s.state.Store(&tracerSamplerState{ratio, sdk_trace.ParentBased(sdk_trace.TraceIDRatioBased(ratio))})
+}
+
+func (s *tracerSampler) ShouldSample(p sdk_trace.SamplingParameters) sdk_trace.SamplingResult {
+	return s.state.Load().sampler.ShouldSample(p)
+}
+
+func (s *tracerSampler) Description() string {
+	return "RuntimeSampler{" + s.state.Load().sampler.Description() + "}"
+}
+
+// otelPropagator of the W3C trace context & baggage.
+func otelPropagator() propagation.TextMapPropagator {
+	return propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+}
+
+func otelSpanExporters(ctx context.Context, c *TracerConfiguration) (spanExporters []sdk_trace.SpanExporter, err error) {
+	spanExporters = append(spanExporters, c.Exporters...)
+	grpcURL, httpURL, stdout := c.OTLP.GRPC.URL, c.OTLP.HTTP.URL, c.Stdout
+
+	if len(spanExporters) < 1 && grpcURL == "" && httpURL == "" && stdout == nil && c.Jaeger.URL == "" {
+		protocol := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL")
+		if protocol == "" {
+			protocol = os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL")
+		}
+
+		for _, v := range strings.Split(os.Getenv("OTEL_TRACES_EXPORTER"), ",") {
+			switch strings.TrimSpace(v) {
+			case "otlp":
+				if protocol == "grpc" {
+					grpcURL = "-"
+				} else {
+					httpURL = "-"
 				}
+			case "console":
+				stdout = os.Stdout
 			}
+		}
+	}
 
-			return ipList
-		}()),
-		semconv.OSNameKey.String(runtime.GOOS),
-	)
+	if grpcURL != "" {
+		opts := []otlptracegrpc.Option{otlptracegrpc.WithHeaders(c.OTLP.Headers)}
+		if grpcURL != "-" {
+			opts = append(opts, otlptracegrpc.WithEndpoint(grpcURL))
+		}
 
-	tp := sdk_trace.NewTracerProvider(
-		sdk_trace.WithBatcher(spanExporter),
-		sdk_trace.WithResource(resource),
-	)
+		if c.OTLP.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		} else if c.OTLP.TLS != nil {
+			opts = append(opts, otlptracegrpc.WithTLSCredentials(credentials.NewTLS(c.OTLP.TLS)))
+		}
 
-	otel.SetTextMapPropagator(propagation.TraceContext{})
-	otel.SetTracerProvider(tp)
+		spanExporter, err := otlptrace.New(ctx, otlptracegrpc.NewClient(opts...))
+		if err != nil {
+			return nil, err
+		}
+
+		spanExporters = append(spanExporters, spanExporter)
+	}
+
+	if httpURL != "" {
+		opts := []otlptracehttp.Option{
+			otlptracehttp.WithHeaders(c.OTLP.Headers),
+			otlptracehttp.WithCompression(otlptracehttp.GzipCompression),
+		}
+		if httpURL != "-" {
+			opts = append(opts, otlptracehttp.WithEndpoint(httpURL))
+		}
+
+		if c.OTLP.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		} else if c.OTLP.TLS != nil {
+			opts = append(opts, otlptracehttp.WithTLSClientConfig(c.OTLP.TLS))
+		}
+
+		spanExporter, err := otlptrace.New(ctx, otlptracehttp.NewClient(opts...))
+		if err != nil {
+			return nil, err
+		}
+
+		spanExporters = append(spanExporters, spanExporter)
+	}
+
+	if c.Jaeger.URL != "" {
+		spanExporter, err := jaeger.New(jaeger.WithCollectorEndpoint(jaeger.WithEndpoint(c.Jaeger.URL)))
+		if err != nil {
+			return nil, err
+		}
+
+		spanExporters = append(spanExporters, spanExporter)
+	}
+
+	if stdout != nil {
+		spanExporter, err := stdouttrace.New(stdouttrace.WithWriter(stdout))
+		if err != nil {
+			return nil, err
+		}
+
+		spanExporters = append(spanExporters, spanExporter)
+	}
+
+	return spanExporters, nil
+}
+
+// otelResource describe the service of the tracer & meter, the process command
+// arguments are not detected as they might carry secrets.
+func otelResource(ctx context.Context, name string, attrs ...attribute.KeyValue) *sdk_resource.Resource {
+	if name != "" {
+		attrs = append(attrs, semconv.ServiceName(name))
+	}
+
+	// a partial resource is still returned on the error of a detector
+	resource, _ := sdk_resource.New(ctx,
+		sdk_resource.WithSchemaURL(semconv.SchemaURL),
+		sdk_resource.WithTelemetrySDK(),
+		sdk_resource.WithHost(),
+		sdk_resource.WithOS(),
+		sdk_resource.WithContainer(),
+		sdk_resource.WithProcessPID(),
+		sdk_resource.WithProcessExecutableName(),
+		sdk_resource.WithProcessOwner(),
+		sdk_resource.WithProcessRuntimeName(),
+		sdk_resource.WithProcessRuntimeVersion(),
+		sdk_resource.WithFromEnv(),
+		sdk_resource.WithAttributes(attrs...),
+	)
+	if resource == nil {
+		resource = sdk_resource.Default()
+	}
 
-	return &Tracer{tp.Tracer(c.Name), tracerProviderWrap{tp}}
+	return resource
 }
 
 type tracerCtxKey struct{}
@@ -166,6 +347,26 @@ func (x tracerProviderWrap) Shutdown(ctx context.Context) error {
 type Tracer struct {
 	trace.Tracer
 	tracerProviderWrap
+
+	sampler *tracerSampler
+}
+
+// SampleRatio of the root spans, see TracerConfiguration.SampleRatio.
+func (t *Tracer) SampleRatio() float64 {
+	if t.sampler == nil {
+		return 1
+	}
+
+	return t.sampler.state.Load().ratio
+}
+
+// SetSampleRatio of the root spans, the ratio is clamped into [0, 1].
+func (t *Tracer) SetSampleRatio(ratio float64) *Tracer {
+	if t.sampler != nil {
+		t.sampler.set(ratio)
+	}
+
+	return t
 }
 
 func (t *Tracer) WithContext(ctx context.Context) context.Context {
@@ -185,49 +386,171 @@ func (t *Tracer) WithContext(ctx context.Context) context.Context {
 type MeterConfiguration struct {
 	Name       string
 	Prometheus struct {
+		// HTTPHandlerCallback receive the handler of the metrics in OpenMetrics
+		// format, e.g. mux.Handle(http.MethodGet, "/metrics", h).
 		HTTPHandlerCallback func(http.Handler)
 	}
+	OTLP struct {
+		GRPC struct{ URL string }
+		HTTP struct{ URL string }
+
+		// Insecure disable TLS, otherwise TLS (default to the system roots) is
+		// used to connect to the collector.
+		Insecure bool
+		TLS      *tls.Config
+
+		// Headers sent on each export, e.g. the API key of the collector.
+		Headers map[string]string
+
+		// Interval of the export, default to 60s.
+		Interval time.Duration
+	}
+
+	// Buckets of the histogram by instrument name, the name could use `*` and
+	// `?` wildcard, e.g. {"db.client.*": {.001, .005, .01, .05, .1, .5, 1}}.
+	Buckets map[string][]float64
+
+	// Exemplar filter, one of trace_based (default), always_on or always_off.
+	// A trace_based exemplar is recorded when the measurement is made within a
+	// sampled span, linking the metric into its trace_id & span_id.
+	Exemplar string
+
+	// DisableRuntime will not register the Go runtime & process metrics.
+	DisableRuntime bool
 }
 
-func (open_telemetry) NewMeter(ctx context.Context, c *MeterConfiguration) *Meter {
+// NewMeter create a Meter that is read by Prometheus and/or exported over
+// OTLP, at least one of them is required. The Meter in the context is returned
+// without c being validated.
+func (open_telemetry) NewMeter(ctx context.Context, c *MeterConfiguration) (*Meter, error) {
 	if m, ok := ctx.Value(meterCtxKey{}).(*Meter); ok && m != nil {
-		return m
-	}
-
-	config := prometheus.Config{}
-	ctrl := metric_controller_basic.New(
-		metric_processor_basic.NewFactory(
-			metric_selector_simple.NewWithHistogramDistribution(
-				metric_aggregator_histogram.WithExplicitBoundaries(config.DefaultHistogramBoundaries),
-			),
-			aggregation.CumulativeTemporalitySelector(),
-			metric_processor_basic.WithMemory(true),
-		),
-	)
+		// a copy without the provider, so that it is shutdown by its creator only
+		return &Meter{m.Meter, meterProviderWrap{}}, nil
+	}
 
-	exporter, err := prometheus.New(config, ctrl)
-	if err != nil {
-		return nil
+	if c == nil || c.Name == "" {
+		return nil, fmt.Errorf("otel: %w", ErrMeterNameRequired)
+	} else if c.Prometheus.HTTPHandlerCallback == nil && c.OTLP.GRPC.URL == "" && c.OTLP.HTTP.URL == "" {
+		return nil, fmt.Errorf("otel: %w", ErrMeterEndpointRequired)
 	}
 
-	if c == nil {
-		return nil
-	} else if c.Prometheus.HTTPHandlerCallback != nil {
-		c.Prometheus.HTTPHandlerCallback(exporter)
+	opts := []sdk_metric.Option{sdk_metric.WithResource(otelResource(ctx, c.Name))}
+
+	var handler http.Handler
+	if c.Prometheus.HTTPHandlerCallback != nil {
+		registry := prometheus_client.NewRegistry()
+
+		exporter, err := prometheus.New(prometheus.WithRegisterer(registry))
+		if err != nil {
+			return nil, fmt.Errorf("otel: meter: %w", err)
+		}
+
+		// OpenMetrics is required to expose the exemplars
+		handler = promhttp.HandlerFor(registry, promhttp.HandlerOpts{EnableOpenMetrics: true})
+		opts = append(opts, sdk_metric.WithReader(exporter))
 	}
 
-	mp := exporter.MeterProvider()
+	interval := 60 * time.Second
+	if c.OTLP.Interval > 0 {
+		interval = c.OTLP.Interval
+	}
 
-	global.SetMeterProvider(mp)
+	if c.OTLP.GRPC.URL != "" {
+		exporterOpts := []otlpmetricgrpc.Option{
+			otlpmetricgrpc.WithEndpoint(c.OTLP.GRPC.URL),
+			otlpmetricgrpc.WithHeaders(c.OTLP.Headers),
+		}
+		if c.OTLP.Insecure {
+			exporterOpts = append(exporterOpts, otlpmetricgrpc.WithInsecure())
+		} else if c.OTLP.TLS != nil {
+			exporterOpts = append(exporterOpts, otlpmetricgrpc.WithTLSCredentials(credentials.NewTLS(c.OTLP.TLS)))
+		}
+
+		exporter, err := otlpmetricgrpc.New(ctx, exporterOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("otel: meter: %w", err)
+		}
+
+		opts = append(opts, sdk_metric.WithReader(sdk_metric.NewPeriodicReader(exporter, sdk_metric.WithInterval(interval))))
+	}
+
+	if c.OTLP.HTTP.URL != "" {
+		exporterOpts := []otlpmetrichttp.Option{
+			otlpmetrichttp.WithEndpoint(c.OTLP.HTTP.URL),
+			otlpmetrichttp.WithHeaders(c.OTLP.Headers),
+			otlpmetrichttp.WithCompression(otlpmetrichttp.GzipCompression),
+		}
+		if c.OTLP.Insecure {
+			exporterOpts = append(exporterOpts, otlpmetrichttp.WithInsecure())
+		} else if c.OTLP.TLS != nil {
+			exporterOpts = append(exporterOpts, otlpmetrichttp.WithTLSClientConfig(c.OTLP.TLS))
+		}
+
+		exporter, err := otlpmetrichttp.New(ctx, exporterOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("otel: meter: %w", err)
+		}
+
+		opts = append(opts, sdk_metric.WithReader(sdk_metric.NewPeriodicReader(exporter, sdk_metric.WithInterval(interval))))
+	}
+
+	for name, boundaries := range c.Buckets {
+		opts = append(opts, sdk_metric.WithView(sdk_metric.NewView(
+			sdk_metric.Instrument{Name: name},
+			sdk_metric.Stream{Aggregation: sdk_metric.AggregationExplicitBucketHistogram{Boundaries: boundaries}},
+		)))
+	}
+
+	switch c.Exemplar {
+	case "always_on":
+		opts = append(opts, sdk_metric.WithExemplarFilter(exemplar.AlwaysOnFilter))
+	case "always_off":
+		opts = append(opts, sdk_metric.WithExemplarFilter(exemplar.AlwaysOffFilter))
+	default:
+		opts = append(opts, sdk_metric.WithExemplarFilter(exemplar.TraceBasedFilter))
+	}
+
+	mp := sdk_metric.NewMeterProvider(opts...)
+	if !c.DisableRuntime {
+		if err := meterRuntime(mp); err != nil {
+			_ = mp.Shutdown(ctx)
 
-	return &Meter{mp.Meter(c.Name), nil}
+			return nil, fmt.Errorf("otel: meter: %w", err)
+		}
+	}
+
+	if handler != nil {
+		c.Prometheus.HTTPHandlerCallback(handler)
+	}
+
+	otel.SetMeterProvider(mp)
+
+	return &Meter{mp.Meter(c.Name), meterProviderWrap{mp}}, nil
 }
 
 type meterCtxKey struct{}
 
+type meterProviderWrap struct{ sdk *sdk_metric.MeterProvider }
+
+func (x meterProviderWrap) ForceFlush(ctx context.Context) error {
+	if x.sdk != nil {
+		return x.sdk.ForceFlush(ctx)
+	}
+
+	return nil
+}
+
+func (x meterProviderWrap) Shutdown(ctx context.Context) error {
+	if x.sdk != nil {
+		return x.sdk.Shutdown(ctx)
+	}
+
+	return nil
+}
+
 type Meter struct {
 	metric.Meter
-	_ interface{}
+	meterProviderWrap
 }
 
 func (m *Meter) WithContext(ctx context.Context) context.Context {
@@ -240,16 +563,36 @@ func (m *Meter) WithContext(ctx context.Context) context.Context {
 	return context.WithValue(ctx, meterCtxKey{}, m)
 }
 
-func (m *Meter) Must() metric.MeterMust { return metric.Must(m.Meter) }
-
 // -----------------------------------------------------------------------------
 // Logger
 // -----------------------------------------------------------------------------
 
+// NewLogger create a Logger that write into c, without c the Logger in the
+// context is returned as is.
 func (open_telemetry) NewLogger(ctx context.Context, c ...io.Writer) *Logger {
-	ws, z := make([]io.Writer, 0), new(zerolog.Logger)
+	if l, ok := ctx.Value(loggerCtxKey{}).(*Logger); ok && l != nil && len(c) < 1 {
+		return l
+	}
+
+	ws, z, spanEvent, scrub := make([]io.Writer, 0), new(zerolog.Logger), new(atomic.Bool), new(atomic.Bool)
+	scrub.Store(true)
+
 	hook := zerolog.HookFunc(func(e *zerolog.Event, level zerolog.Level, message string) {
-		//
+		span := trace.SpanFromContext(e.GetCtx())
+		if sc := span.SpanContext(); sc.IsValid() {
+			e.Str("trace_id", sc.TraceID().String()).Str("span_id", sc.SpanID().String())
+		}
+
+		if spanEvent.Load() && level >= zerolog.ErrorLevel && level <= zerolog.PanicLevel && span.IsRecording() {
+			if scrub.Load() {
+				message = Scrub(message)
+			}
+
+			span.AddEvent("log", trace.WithAttributes(
+				attribute.String("log.severity", level.String()),
+				attribute.String("log.message", message),
+			))
+		}
 	})
 
 	for j := 0; j < len(c); j++ {
@@ -263,30 +606,42 @@ func (open_telemetry) NewLogger(ctx context.Context, c ...io.Writer) *Logger {
 		case 0:
 			*z = zerolog.Nop()
 		case 1:
-			*z = zerolog.New(ws[0]).Hook(hook)
+			*z = zerolog.New(logScrubWriter{ws[0], scrub}).Hook(hook)
 		default:
-			*z = zerolog.New(zerolog.MultiLevelWriter(ws...)).Hook(hook)
+			*z = zerolog.New(logScrubWriter{zerolog.MultiLevelWriter(ws...), scrub}).Hook(hook)
 		}
 	} else if zz := zerolog.Ctx(ctx); zz != nil {
 		*z = *zz
 	}
 
-	*z = z.With().Timestamp().Logger()
+	levels := &logLevels{components: map[string]*zerolog.Level{}}
+	levels.global.Store(int32(zerolog.TraceLevel))
 
-	dir := os.TempDir()
-	tempOUT, _ := os.Create(dir + "/temp-" + logID + "-out.log")
-	tempERR, _ := os.Create(dir + "/temp-" + logID + "-err.log")
+	*z = z.With().Timestamp().Logger().Sample(logLevelSampler{levels, ""})
 
-	return &Logger{log.New(z, "", 0), z, tempOUT, tempERR}
+	return &Logger{
+		standard:  log.New(z, "", 0),
+		zerolog:   z,
+		levels:    levels,
+		spanEvent: spanEvent,
+		scrub:     scrub,
+		temp:      new(sync.Once),
+	}
 }
 
 type loggerCtxKey struct{}
 
 type Logger struct {
-	standard *log.Logger
-	zerolog  *zerolog.Logger
-
+	standard  *log.Logger
+	zerolog   *zerolog.Logger
+	levels    *logLevels
+	spanEvent *atomic.Bool
+	scrub     *atomic.Bool
+
+	// temp files of Swap, created on the first Swap
+	temp             *sync.Once
 	tempOUT, tempERR *os.File
+	tempErr          error
 }
 
 func (l *Logger) WithContext(ctx context.Context) context.Context {
@@ -299,7 +654,26 @@ func (l *Logger) WithContext(ctx context.Context) context.Context {
 	return context.WithValue(ctx, loggerCtxKey{}, l)
 }
 
+// Swap os.Stdout & os.Stderr with the temp files, the readers will return the
+// error of creating the temp files when they can not be created.
 func (l *Logger) Swap() (readOUT, readERR func() ([]byte, error)) {
+	l.temp.Do(func() {
+		dir := os.TempDir()
+		if l.tempOUT, l.tempErr = os.Create(dir + "/temp-" + logID + "-out.log"); l.tempErr != nil {
+			return
+		}
+
+		if l.tempERR, l.tempErr = os.Create(dir + "/temp-" + logID + "-err.log"); l.tempErr != nil {
+			_ = l.tempOUT.Close()
+		}
+	})
+
+	if l.tempErr != nil {
+		read := func() ([]byte, error) { return nil, l.tempErr }
+
+		return read, read
+	}
+
 	os.Stdout, os.Stderr = l.tempOUT, l.tempERR
 
 	log.SetOutput(os.Stderr)
@@ -325,16 +699,360 @@ func (l *Logger) Unswap() {
 
 func (l *Logger) S() *log.Logger     { return l.standard }
 func (l *Logger) Z() *zerolog.Logger { return l.zerolog }
+
+// Ctx return the logger of the request, the events carry the trace_id & span_id
+// of the active span in ctx and the fields of OTel.ContextWithLogFields.
+//
+//	log.Ctx(r.Context()).Info().Msg("done")
+//	// {"level":"info","request_id":"...","route":"GET /","trace_id":"...","span_id":"...","message":"done"}
+func (l *Logger) Ctx(ctx context.Context) *zerolog.Logger {
+	zc := l.zerolog.With().Ctx(ctx)
+	if fields, ok := ctx.Value(logFieldsCtxKey{}).(map[string]interface{}); ok {
+		zc = zc.Fields(fields)
+	}
+
+	z := zc.Logger()
+
+	return &z
+}
+
+// SpanEvent will record the error (and above) logs as an event of the active
+// span, see Logger.Ctx.
+func (l *Logger) SpanEvent(enable bool) *Logger {
+	if l.spanEvent != nil {
+		l.spanEvent.Store(enable)
+	}
+
+	return l
+}
+
+// Scrub will mask the dsn passwords, bearer tokens & emails of the events before
+// they are written (default to true), see Scrub.
+func (l *Logger) Scrub(enable bool) *Logger {
+	if l.scrub != nil {
+		l.scrub.Store(enable)
+	}
+
+	return l
+}
+
+// logScrubWriter Scrub the events on write, as a zerolog hook is unable to
+// rewrite the fields that are already encoded.
+type logScrubWriter struct {
+	w      io.Writer
+	enable *atomic.Bool
+}
+
+func (x logScrubWriter) Write(p []byte) (int, error) { return x.WriteLevel(zerolog.NoLevel, p) }
+
+func (x logScrubWriter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	n := len(p)
+	if x.enable.Load() {
+		p = []byte(Scrub(string(p)))
+	}
+
+	var err error
+	if lw, ok := x.w.(zerolog.LevelWriter); ok {
+		_, err = lw.WriteLevel(level, p)
+	} else {
+		_, err = x.w.Write(p)
+	}
+
+	return n, err
+}
+
+// Level of the logger, it is safe to be changed at runtime; see also
+// Logger.ComponentLevel & OTel.NewAdminHandler.
 func (l *Logger) Level(level string) *Logger {
 	lv, err := zerolog.ParseLevel(strings.ToLower(level))
 	if err == nil {
-		*l.zerolog = l.zerolog.Level(lv)
-		l.standard.SetOutput(l.zerolog)
+		l.levels.global.Store(int32(lv))
+	}
+
+	return l
+}
+
+// Component return the logger of a component, e.g. "sql"; the events carry the
+// component field and its level could be set apart from the Logger.Level.
+func (l *Logger) Component(name string) *zerolog.Logger {
+	l.levels.mu.Lock()
+	if _, ok := l.levels.components[name]; !ok {
+		l.levels.components[name] = nil
+	}
+	l.levels.mu.Unlock()
+
+	z := l.zerolog.With().Str("component", name).Logger().Sample(logLevelSampler{l.levels, name})
+
+	return &z
+}
+
+// ComponentLevel of the component, an empty level will follow the Logger.Level.
+func (l *Logger) ComponentLevel(name, level string) *Logger {
+	l.levels.mu.Lock()
+	defer l.levels.mu.Unlock()
+
+	if level == "" {
+		l.levels.components[name] = nil
+	} else if lv, err := zerolog.ParseLevel(strings.ToLower(level)); err == nil {
+		l.levels.components[name] = &lv
 	}
 
 	return l
 }
 
+type logLevels struct {
+	global  atomic.Int32
+	sampler atomic.Pointer[logSampler]
+
+	mu         sync.RWMutex
+	components map[string]*zerolog.Level
+}
+
+type logSampler struct{ zerolog.Sampler }
+
+// level of the component, or the global level.
+func (x *logLevels) level(component string) zerolog.Level {
+	if component != "" {
+		x.mu.RLock()
+		lv := x.components[component]
+		x.mu.RUnlock()
+
+		if lv != nil {
+			return *lv
+		}
+	}
+
+	return zerolog.Level(x.global.Load())
+}
+
+// logLevelSampler is the runtime level of the logger, as the sampler is consulted
+// before an event is created (unlike a hook) & the levels are read atomically.
+type logLevelSampler struct {
+	levels    *logLevels
+	component string
+}
+
+func (x logLevelSampler) Sample(lvl zerolog.Level) bool {
+	if lvl < x.levels.level(x.component) {
+		return false
+	}
+
+	if s := x.levels.sampler.Load(); s != nil && s.Sampler != nil {
+		return s.Sample(lvl)
+	}
+
+	return true
+}
+
+type LogSampleConfiguration struct {
+	// Burst of the events that are logged every Period (default to 1s), the
+	// events after the burst are sampled by Levels.
+	Burst  uint32
+	Period time.Duration
+
+	// Levels log 1 of every N events of the level, e.g. {"debug": 10}; a level
+	// not listed is not sampled and a zero N will drop every events of the level.
+	Levels map[string]uint32
+}
+
+// Sample the events of the logger, a nil c will disable the sampling.
+//
+//	log.Sample(&sdk.LogSampleConfiguration{
+//	  Burst: 100, Period: time.Second, Levels: map[string]uint32{"debug": 10, "info": 2},
+//	})
+func (l *Logger) Sample(c *LogSampleConfiguration) *Logger {
+	var sampler zerolog.Sampler
+	if c != nil {
+		levels := &zerolog.LevelSampler{}
+		for level, n := range c.Levels {
+			s := &zerolog.BasicSampler{N: n}
+
+			switch lv, _ := zerolog.ParseLevel(strings.ToLower(level)); lv {
+			case zerolog.TraceLevel:
+				levels.TraceSampler = s
+			case zerolog.DebugLevel:
+				levels.DebugSampler = s
+			case zerolog.InfoLevel:
+				levels.InfoSampler = s
+			case zerolog.WarnLevel:
+				levels.WarnSampler = s
+			case zerolog.ErrorLevel:
+				levels.ErrorSampler = s
+			}
+		}
+
+		sampler = levels
+		if c.Burst > 0 {
+			period := time.Second
+			if c.Period > 0 {
+				period = c.Period
+			}
+
+			sampler = &zerolog.BurstSampler{Burst: c.Burst, Period: period, NextSampler: levels}
+		}
+	}
+
+	l.levels.sampler.Store(&logSampler{sampler})
+
+	return l
+}
+
 func (open_telemetry) NewConsoleWriter(w io.Writer) *zerolog.ConsoleWriter {
 	return &zerolog.ConsoleWriter{Out: w}
 }
+
+// -----------------------------------------------------------------------------
+// Setup
+// -----------------------------------------------------------------------------
+
+type OTelConfiguration struct {
+	// Tracer, Meter & Logs are optional, a nil configuration is not created.
+	Tracer *TracerConfiguration
+	Meter  *MeterConfiguration
+	Logs   *LogExporterConfiguration
+
+	// Logger writers, see NewLogger; the LogExporter of Logs is appended.
+	Logger []io.Writer
+}
+
+// Setup create the tracer, meter & logger of c, install the W3C trace context
+// & baggage propagator and return the context carrying them. The shutdown will
+// flush & shutdown the tracer, the log exporter, then the meter, so that the
+// metrics recorded while the spans & logs are exported (e.g. the dropped log
+// records) are exported as well.
+//
+//	ctx, shutdown, err := sdk.OTel.Setup(ctx, &sdk.OTelConfiguration{...})
+//	if err != nil {
+//	  return err
+//	}
+//	defer shutdown(context.Background())
+func (otel_ open_telemetry) Setup(ctx context.Context, c *OTelConfiguration) (_ context.Context, shutdown func(context.Context) error, err error) {
+	var (
+		tracer *Tracer
+		meter  *Meter
+		logs   *LogExporter
+	)
+
+	shutdown = func(ctx context.Context) error {
+		errs := new(ListError)
+		if tracer != nil {
+			errs = errs.Add(tracer.Shutdown(ctx))
+		}
+
+		if logs != nil {
+			errs = errs.Add(logs.Shutdown(ctx))
+		}
+
+		if meter != nil {
+			errs = errs.Add(meter.Shutdown(ctx))
+		}
+
+		return errs.Err()
+	}
+
+	if c == nil {
+		c = new(OTelConfiguration)
+	}
+
+	otel.SetTextMapPropagator(otelPropagator())
+
+	if c.Tracer != nil {
+		if tracer, err = otel_.NewTracer(ctx, c.Tracer); err != nil {
+			return ctx, shutdown, err
+		}
+
+		ctx = tracer.WithContext(ctx)
+	}
+
+	if c.Meter != nil {
+		if meter, err = otel_.NewMeter(ctx, c.Meter); err != nil {
+			_ = shutdown(ctx)
+
+			return ctx, shutdown, err
+		}
+
+		ctx = meter.WithContext(ctx)
+	}
+
+	writers := append([]io.Writer{}, c.Logger...)
+	if c.Logs != nil {
+		if logs, err = otel_.NewLogExporter(ctx, c.Logs); err != nil {
+			_ = shutdown(ctx)
+
+			return ctx, shutdown, err
+		}
+
+		writers = append(writers, logs)
+	}
+
+	return otel_.NewLogger(ctx, writers...).WithContext(ctx), shutdown, nil
+}
+
+// -----------------------------------------------------------------------------
+// Log fields
+// -----------------------------------------------------------------------------
+
+type logFieldsCtxKey struct{}
+
+// ContextWithLogFields return a context carrying the fields in addition to the
+// existing one, e.g. the user of the request; see Logger.Ctx.
+func (open_telemetry) ContextWithLogFields(ctx context.Context, fields map[string]interface{}) context.Context {
+	prev, _ := ctx.Value(logFieldsCtxKey{}).(map[string]interface{})
+	next := make(map[string]interface{}, len(prev)+len(fields))
+
+	for k, v := range prev {
+		next[k] = v
+	}
+
+	for k, v := range fields {
+		next[k] = v
+	}
+
+	return context.WithValue(ctx, logFieldsCtxKey{}, next)
+}
+
+type MuxLogFieldsConfiguration struct {
+	// Header carrying the request ID, default to X-Request-ID. A request
+	// without the ID is given a random one, the ID is also set on the response.
+	Header string
+
+	// MaxLength of the request ID sent by the client, default to 128. A longer
+	// ID is replaced by a random one instead of being logged & echoed.
+	MaxLength int
+}
+
+// MuxLogFields is a middleware that set the request_id & route log fields of the
+// request, see Logger.Ctx. The route is the pattern of the Mux (e.g. `GET
+// /users/:id`) to keep it low-cardinality, or the path when there is none.
+func MuxLogFields(c *MuxLogFieldsConfiguration) http.Handler {
+	cfg := MuxLogFieldsConfiguration{Header: "X-Request-ID", MaxLength: 128}
+	if c != nil {
+		if c.Header != "" {
+			cfg.Header = c.Header
+		}
+
+		if c.MaxLength > 0 {
+			cfg.MaxLength = c.MaxLength
+		}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(cfg.Header)
+		if requestID == "" || len(requestID) > cfg.MaxLength {
+			p := make([]byte, 16)
+			_, _ = rand.Read(p)
+			requestID = hex.EncodeToString(p)
+		}
+
+		route := PatternFromRequest(r)
+		if route == "" {
+			route = r.URL.Path
+		}
+
+		w.Header().Set(cfg.Header, requestID)
+		*r = *(r.WithContext(OTel.ContextWithLogFields(r.Context(), map[string]interface{}{
+			"request_id": requestID,
+			"route":      r.Method + " " + route,
+		})))
+	})
+}
@@ -0,0 +1,353 @@
+package sdk
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	runtime_pprof "runtime/pprof"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type AdminConfiguration struct {
+	// Logger whose levels are managed, the changes are also audit-logged into
+	// the Logger.
+	Logger *Logger
+
+	// Tracer whose sample ratio is managed.
+	Tracer *Tracer
+
+	// Actor of the request in the audit log, default to the remote address.
+	Actor func(r *http.Request) string
+
+	// Token of NewAdminMux, the requests are required to carry the header
+	// `Authorization: Bearer <token>`.
+	Token Secret[string]
+
+	// Authenticate the requests of NewAdminMux in place of the Token, without
+	// both of them every request is rejected.
+	Authenticate func(r *http.Request) bool
+}
+
+// NewAdminHandler create a handler that read (GET) and change (PUT or POST) the
+// log levels & the trace sample ratio at runtime, a change with a ttl will be
+// reverted after the ttl is elapsed. The handler is not authenticated, it
+// should be mounted behind an authentication middleware, see NewAdminMux.
+//
+//	h := sdk.OTel.NewAdminHandler(&sdk.AdminConfiguration{Logger: log, Tracer: tracer})
+//	mux := new(sdk.Mux).
+//	  Handle(http.MethodGet, "/admin/telemetry", h).
+//	  Handle(http.MethodPut, "/admin/telemetry", h)
+//
+//	// PUT {"level":"debug","ttl":"5m"}
+//	// PUT {"component":"sql","level":"trace","ttl":"1m"}
+//	// PUT {"sample_ratio":1,"ttl":"30s"}
+func (open_telemetry) NewAdminHandler(c *AdminConfiguration) http.Handler {
+	h := &adminHandler{actor: func(r *http.Request) string { return r.RemoteAddr }, reverts: map[string]*adminRevert{}}
+	if c != nil {
+		h.logger, h.tracer = c.Logger, c.Tracer
+		if c.Actor != nil {
+			h.actor = c.Actor
+		}
+	}
+
+	return h
+}
+
+type adminHandler struct {
+	logger *Logger
+	tracer *Tracer
+	actor  func(r *http.Request) string
+
+	mu      sync.Mutex
+	reverts map[string]*adminRevert
+}
+
+type adminRevert struct {
+	timer *time.Timer
+	from  string
+	undo  func()
+}
+
+type adminState struct {
+	Level       string            `json:"level,omitempty"`
+	Components  map[string]string `json:"components,omitempty"`
+	SampleRatio *float64          `json:"sample_ratio,omitempty"`
+}
+
+type adminChange struct {
+	Level       *string  `json:"level"`
+	Component   string   `json:"component"`
+	SampleRatio *float64 `json:"sample_ratio"`
+	TTL         string   `json:"ttl"`
+}
+
+func (h *adminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		if err := h.change(r); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, PUT, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(h.state())
+}
+
+func (h *adminHandler) state() adminState {
+	var state adminState
+	if h.logger != nil {
+		state.Level = zerolog.Level(h.logger.levels.global.Load()).String()
+		state.Components = map[string]string{}
+
+		h.logger.levels.mu.RLock()
+		for name, lv := range h.logger.levels.components {
+			if state.Components[name] = ""; lv != nil {
+				state.Components[name] = lv.String()
+			}
+		}
+		h.logger.levels.mu.RUnlock()
+	}
+
+	if h.tracer != nil {
+		ratio := h.tracer.SampleRatio()
+		state.SampleRatio = &ratio
+	}
+
+	return state
+}
+
+func (h *adminHandler) change(r *http.Request) error {
+	var c adminChange
+	if err := json.NewDecoder(http.MaxBytesReader(nil, r.Body, 1<<12)).Decode(&c); err != nil {
+		return fmt.Errorf("admin: %w: %w", ErrInvalidValue, err)
+	}
+
+	var ttl time.Duration
+	if c.TTL != "" {
+		var err error
+		if ttl, err = time.ParseDuration(c.TTL); err != nil || ttl < 0 {
+			return fmt.Errorf("admin: %w: ttl %q", ErrInvalidValue, c.TTL)
+		}
+	}
+
+	switch {
+	case c.Level != nil && h.logger == nil, c.SampleRatio != nil && h.tracer == nil:
+		return fmt.Errorf("admin: %w: not configured", ErrInvalidValue)
+	case c.Level == nil && c.SampleRatio == nil:
+		return fmt.Errorf("admin: %w: level or sample_ratio required", ErrInvalidValue)
+	}
+
+	// every value is validated before any of them is applied, so that a bad
+	// request never leave a partial change
+	level := ""
+	if c.Level != nil {
+		level = strings.ToLower(*c.Level)
+		if _, err := zerolog.ParseLevel(level); err != nil || (level == "" && c.Component == "") {
+			return fmt.Errorf("admin: %w: level %q", ErrInvalidValue, *c.Level)
+		}
+	}
+
+	if c.SampleRatio != nil && (*c.SampleRatio < 0 || *c.SampleRatio > 1) {
+		return fmt.Errorf("admin: %w: sample_ratio %v", ErrInvalidValue, *c.SampleRatio)
+	}
+
+	if c.Level != nil {
+		if c.Component == "" {
+			from := zerolog.Level(h.logger.levels.global.Load()).String()
+			h.apply(r, "level", from, level, ttl, func(v string) { h.logger.Level(v) })
+		} else {
+			h.logger.levels.mu.RLock()
+			from := ""
+			if lv := h.logger.levels.components[c.Component]; lv != nil {
+				from = lv.String()
+			}
+			h.logger.levels.mu.RUnlock()
+
+			h.apply(r, "component:"+c.Component, from, level, ttl, func(v string) { h.logger.ComponentLevel(c.Component, v) })
+		}
+	}
+
+	if c.SampleRatio != nil {
+		from := strconv.FormatFloat(h.tracer.SampleRatio(), 'g', -1, 64)
+		to := strconv.FormatFloat(*c.SampleRatio, 'g', -1, 64)
+		h.apply(r, "sample_ratio", from, to, ttl, func(v string) {
+			ratio, _ := strconv.ParseFloat(v, 64)
+			h.tracer.SetSampleRatio(ratio)
+		})
+	}
+
+	return nil
+}
+
+// apply the change of the target, a pending revert of the target is replaced
+// but it still revert into the value before the first change.
+func (h *adminHandler) apply(r *http.Request, target, from, to string, ttl time.Duration, set func(string)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	original := from
+	if prev, ok := h.reverts[target]; ok {
+		prev.timer.Stop()
+		original = prev.from
+		delete(h.reverts, target)
+	}
+
+	set(to)
+	h.audit(h.actor(r), target, from, to, ttl)
+
+	if ttl <= 0 {
+		return
+	}
+
+	revert := &adminRevert{from: original}
+	revert.undo = func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		if h.reverts[target] != revert {
+			return
+		}
+
+		delete(h.reverts, target)
+		set(original)
+		h.audit("ttl", target, to, original, 0)
+	}
+	revert.timer = time.AfterFunc(ttl, revert.undo)
+	h.reverts[target] = revert
+}
+
+func (h *adminHandler) audit(actor, target, from, to string, ttl time.Duration) {
+	if h.logger == nil {
+		return
+	}
+
+	// the audit skip the levels & the sampler of the logger (see Logger.Sample),
+	// so that a change is never dropped, e.g. after the level is set to disabled
+	z := h.logger.Z().Sample(nil)
+	e := z.Log().Str("audit", "telemetry").Str("actor", actor).Str("target", target).
+		Str("from", from).Str("to", to)
+	if ttl > 0 {
+		e = e.Str("ttl", ttl.String())
+	}
+
+	e.Msg("[admin] telemetry changed")
+}
+
+// -----------------------------------------------------------------------------
+// Mux
+// -----------------------------------------------------------------------------
+
+// NewAdminMux create an authenticated Mux of NewAdminHandler, net/http/pprof and
+// the goroutine dump, it should be served apart from the public Mux.
+//
+//	GET,PUT,POST /telemetry              see NewAdminHandler
+//	GET          /debug/pprof/           the index of the profiles
+//	GET          /debug/pprof/:name      a profile, e.g. heap, goroutine, allocs
+//	GET          /debug/pprof/profile    a CPU profile of ?seconds=N (default 30)
+//	GET          /debug/pprof/trace      a runtime trace of ?seconds=N (default 1)
+//	GET          /debug/goroutines       the stack traces of every goroutine
+//
+//	mux := sdk.OTel.NewAdminMux(&sdk.AdminConfiguration{Logger: log, Tracer: tracer, Token: token})
+//	go http.ListenAndServe("localhost:6060", mux)
+func (otel_ open_telemetry) NewAdminMux(c *AdminConfiguration) *Mux {
+	authenticate := func(*http.Request) bool { return false }
+	if c != nil && c.Authenticate != nil {
+		authenticate = c.Authenticate
+	} else if c != nil && c.Token.Value() != "" {
+		token := []byte("Bearer " + c.Token.Value())
+		authenticate = func(r *http.Request) bool {
+			return subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), token) == 1
+		}
+	}
+
+	admin := otel_.NewAdminHandler(c)
+	mux := new(Mux).
+		Handle(http.MethodGet, "/telemetry", admin).
+		Handle(http.MethodPut, "/telemetry", admin).
+		Handle(http.MethodPost, "/telemetry", admin).
+		Handle(http.MethodGet, "/debug/pprof/", http.HandlerFunc(pprof.Index)).
+		Handle(http.MethodGet, "/debug/pprof/:name", http.HandlerFunc(pprof.Index)).
+		Handle(http.MethodGet, "/debug/pprof/cmdline", http.HandlerFunc(pprof.Cmdline)).
+		Handle(http.MethodGet, "/debug/pprof/profile", http.HandlerFunc(pprof.Profile)).
+		Handle(http.MethodGet, "/debug/pprof/symbol", http.HandlerFunc(pprof.Symbol)).
+		Handle(http.MethodPost, "/debug/pprof/symbol", http.HandlerFunc(pprof.Symbol)).
+		Handle(http.MethodGet, "/debug/pprof/trace", http.HandlerFunc(pprof.Trace)).
+		Handle(http.MethodGet, "/debug/goroutines", http.HandlerFunc(adminGoroutines))
+	mux.Middleware = func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !authenticate(r) {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+
+	return mux
+}
+
+func adminGoroutines(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Goroutine-Count", strconv.Itoa(runtime.NumGoroutine()))
+
+	_ = runtime_pprof.Lookup("goroutine").WriteTo(w, 2)
+}
+
+// -----------------------------------------------------------------------------
+// Profile
+// -----------------------------------------------------------------------------
+
+// MuxProfileLabels is a Mux.Middleware that serve next with the pprof labels of
+// the matched route (http.route, see PatternFromRequest) and the trace_id, so a
+// CPU profile could be sliced per endpoint, e.g. `go tool pprof -tagfocus
+// http.route=/users/:id`. The trace_id is read from the span in the context or
+// the propagated headers.
+//
+//	mux := new(sdk.Mux).Handle(http.MethodGet, "/users/:id", h)
+//	mux.Middleware = sdk.MuxProfileLabels
+func MuxProfileLabels(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		labels := []string{"http.request.method", r.Method}
+
+		if route := PatternFromRequest(r); route != "" {
+			labels = append(labels, "http.route", route)
+		}
+
+		sc := trace.SpanContextFromContext(ctx)
+		if !sc.IsValid() {
+			sc = trace.SpanContextFromContext(otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(r.Header)))
+		}
+
+		if sc.IsValid() {
+			labels = append(labels, "trace_id", sc.TraceID().String())
+		}
+
+		runtime_pprof.Do(ctx, runtime_pprof.Labels(labels...), func(ctx context.Context) {
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	})
+}
@@ -0,0 +1,315 @@
+package sdk_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime/pprof"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/gunawanwijaya/forest/sdk"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func test_OpenTelemetryAdmin(t *testing.T) {
+	t.Parallel()
+
+	g := NewWithT(t)
+	Expect := g.Expect
+	ctx := context.Background()
+
+	type state struct {
+		Level       string            `json:"level"`
+		Components  map[string]string `json:"components"`
+		SampleRatio float64           `json:"sample_ratio"`
+	}
+
+	serve := func(h http.Handler, method, body string) (int, state) {
+		var s state
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, "/admin/telemetry", strings.NewReader(body)))
+
+		if w.Code == http.StatusOK {
+			Expect(json.Unmarshal(w.Body.Bytes(), &s)).To(Succeed())
+		}
+
+		return w.Code, s
+	}
+
+	t.Run("level", func(t *testing.T) {
+		buf := new(syncBuffer)
+		log := OTel.NewLogger(ctx, buf).Level("info")
+		sql := log.Component("sql")
+		h := OTel.NewAdminHandler(&AdminConfiguration{
+			Logger: log,
+			Actor:  func(*http.Request) string { return "alice" },
+		})
+
+		code, s := serve(h, http.MethodGet, "")
+		Expect(code).To(Equal(http.StatusOK))
+		Expect(s.Level).To(Equal("info"))
+		Expect(s.Components).To(Equal(map[string]string{"sql": ""}))
+
+		log.Z().Debug().Msg("hidden")
+		sql.Debug().Msg("hidden")
+		Expect(buf.String()).To(BeEmpty())
+
+		code, s = serve(h, http.MethodPut, `{"component":"sql","level":"debug"}`)
+		Expect(code).To(Equal(http.StatusOK))
+		Expect(s.Level).To(Equal("info"))
+		Expect(s.Components).To(Equal(map[string]string{"sql": "debug"}))
+
+		log.Z().Debug().Msg("hidden")
+		sql.Debug().Msg("visible")
+		Expect(buf.String()).NotTo(ContainSubstring("hidden"))
+		Expect(buf.String()).To(ContainSubstring(`"component":"sql"`))
+		Expect(buf.String()).To(ContainSubstring(`"message":"visible"`))
+		Expect(buf.String()).To(MatchRegexp(`"audit":"telemetry","actor":"alice","target":"component:sql","from":"","to":"debug"`))
+
+		code, s = serve(h, http.MethodPut, `{"level":"ERROR"}`)
+		Expect(code).To(Equal(http.StatusOK))
+		Expect(s.Level).To(Equal("error"))
+
+		buf.Reset()
+		log.Z().Warn().Msg("hidden")
+		Expect(buf.String()).To(BeEmpty())
+	})
+	t.Run("audit", func(t *testing.T) {
+		// the info events are dropped after the burst, yet every change is audited
+		buf := new(syncBuffer)
+		log := OTel.NewLogger(ctx, buf).Sample(&LogSampleConfiguration{
+			Burst: 1, Period: time.Hour, Levels: map[string]uint32{"info": 0},
+		})
+		h := OTel.NewAdminHandler(&AdminConfiguration{Logger: log})
+
+		log.Z().Info().Msg("visible")
+		log.Z().Info().Msg("hidden")
+
+		for _, level := range []string{"warn", "disabled", "info"} {
+			code, _ := serve(h, http.MethodPut, `{"level":"`+level+`"}`)
+			Expect(code).To(Equal(http.StatusOK))
+		}
+
+		Expect(buf.String()).To(ContainSubstring(`"message":"visible"`))
+		Expect(buf.String()).NotTo(ContainSubstring(`"message":"hidden"`))
+		Expect(strings.Count(buf.String(), `"audit":"telemetry"`)).To(Equal(3))
+		Expect(buf.String()).To(ContainSubstring(`"from":"warn","to":"disabled"`))
+		Expect(buf.String()).To(ContainSubstring(`"from":"disabled","to":"info"`))
+	})
+	t.Run("sample_ratio", func(t *testing.T) {
+		tracer, err := OTel.NewTracer(ctx, &TracerConfiguration{Name: "test", Stdout: new(bytes.Buffer), SampleRatio: .25})
+		Expect(err).To(Succeed())
+
+		defer func() { Expect(tracer.Shutdown(ctx)).To(Succeed()) }()
+
+		h := OTel.NewAdminHandler(&AdminConfiguration{Tracer: tracer})
+
+		code, s := serve(h, http.MethodGet, "")
+		Expect(code).To(Equal(http.StatusOK))
+		Expect(s.SampleRatio).To(Equal(.25))
+
+		code, s = serve(h, http.MethodPost, `{"sample_ratio":1}`)
+		Expect(code).To(Equal(http.StatusOK))
+		Expect(s.SampleRatio).To(Equal(1.))
+		Expect(tracer.SampleRatio()).To(Equal(1.))
+
+		_, span := tracer.Start(ctx, "test")
+		Expect(span.SpanContext().IsSampled()).To(BeTrue())
+		span.End()
+	})
+	t.Run("ttl", func(t *testing.T) {
+		buf := new(syncBuffer)
+		log := OTel.NewLogger(ctx, buf).Level("info")
+		tracer, err := OTel.NewTracer(ctx, &TracerConfiguration{Name: "test", Stdout: new(bytes.Buffer), SampleRatio: .5})
+		Expect(err).To(Succeed())
+
+		defer func() { Expect(tracer.Shutdown(ctx)).To(Succeed()) }()
+
+		h := OTel.NewAdminHandler(&AdminConfiguration{Logger: log, Tracer: tracer})
+
+		code, _ := serve(h, http.MethodPut, `{"level":"debug","sample_ratio":0,"ttl":"1h"}`)
+		Expect(code).To(Equal(http.StatusOK))
+
+		// a superseding change still revert into the value before the first change
+		code, s := serve(h, http.MethodPut, `{"level":"trace","sample_ratio":0.1,"ttl":"50ms"}`)
+		Expect(code).To(Equal(http.StatusOK))
+		Expect(s.Level).To(Equal("trace"))
+		Expect(s.SampleRatio).To(Equal(.1))
+
+		g.Eventually(func() float64 { return tracer.SampleRatio() }, time.Second).Should(Equal(.5))
+		g.Eventually(func() string { _, s := serve(h, http.MethodGet, ""); return s.Level }, time.Second).Should(Equal("info"))
+		Expect(buf.String()).To(ContainSubstring(`"actor":"ttl","target":"level","from":"trace","to":"info"`))
+		Expect(buf.String()).To(ContainSubstring(`"actor":"ttl","target":"sample_ratio","from":"0.1","to":"0.5"`))
+
+		// a change without ttl cancel the pending revert
+		code, _ = serve(h, http.MethodPut, `{"level":"warn","ttl":"50ms"}`)
+		Expect(code).To(Equal(http.StatusOK))
+		code, _ = serve(h, http.MethodPut, `{"level":"error"}`)
+		Expect(code).To(Equal(http.StatusOK))
+		g.Consistently(func() string { _, s := serve(h, http.MethodGet, ""); return s.Level }, 150*time.Millisecond).Should(Equal("error"))
+	})
+	t.Run("invalid", func(t *testing.T) {
+		h := OTel.NewAdminHandler(&AdminConfiguration{Logger: OTel.NewLogger(ctx, new(syncBuffer))})
+
+		for _, body := range []string{
+			``,
+			`{}`,
+			`{"level":"loud"}`,
+			`{"level":""}`,
+			`{"level":"debug","ttl":"soon"}`,
+			`{"level":"debug","ttl":"-1s"}`,
+			`{"sample_ratio":0.5}`,
+		} {
+			code, _ := serve(h, http.MethodPut, body)
+			Expect(code).To(Equal(http.StatusBadRequest), body)
+		}
+
+		code, _ := serve(h, http.MethodDelete, "")
+		Expect(code).To(Equal(http.StatusMethodNotAllowed))
+
+		h = OTel.NewAdminHandler(nil)
+		code, _ = serve(h, http.MethodPut, `{"sample_ratio":0.5}`)
+		Expect(code).To(Equal(http.StatusBadRequest))
+		// nothing is applied when any of the values is invalid
+		buf := new(syncBuffer)
+		tracer, err := OTel.NewTracer(ctx, &TracerConfiguration{Name: "test", Stdout: new(bytes.Buffer), SampleRatio: .5})
+		Expect(err).To(Succeed())
+
+		defer func() { Expect(tracer.Shutdown(ctx)).To(Succeed()) }()
+
+		h = OTel.NewAdminHandler(&AdminConfiguration{Logger: OTel.NewLogger(ctx, buf).Level("info"), Tracer: tracer})
+		for _, body := range []string{
+			`{"level":"trace","sample_ratio":5}`,
+			`{"level":"trace","component":"sql","sample_ratio":-1,"ttl":"1h"}`,
+		} {
+			code, _ = serve(h, http.MethodPut, body)
+			Expect(code).To(Equal(http.StatusBadRequest), body)
+		}
+
+		code, s := serve(h, http.MethodGet, "")
+		Expect(code).To(Equal(http.StatusOK))
+		Expect(s.Level).To(Equal("info"))
+		Expect(s.Components).To(BeEmpty())
+		Expect(s.SampleRatio).To(Equal(.5))
+		Expect(buf.String()).NotTo(ContainSubstring(`"audit"`))
+	})
+	t.Run("mux", func(t *testing.T) {
+		get := func(h http.Handler, path, authorization string) *httptest.ResponseRecorder {
+			r := httptest.NewRequest(http.MethodGet, path, nil)
+			if authorization != "" {
+				r.Header.Set("Authorization", authorization)
+			}
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			return w
+		}
+
+		// without a Token nor Authenticate every request is rejected
+		Expect(get(OTel.NewAdminMux(nil), "/debug/pprof/", "Bearer ").Code).To(Equal(http.StatusUnauthorized))
+
+		mux := OTel.NewAdminMux(&AdminConfiguration{
+			Logger: OTel.NewLogger(ctx, new(syncBuffer)),
+			Token:  SecretOf("s3cr3t"),
+		})
+
+		for _, authorization := range []string{"", "Bearer", "Bearer wrong", "s3cr3t"} {
+			w := get(mux, "/debug/pprof/", authorization)
+			Expect(w.Code).To(Equal(http.StatusUnauthorized), authorization)
+			Expect(w.Header().Get("WWW-Authenticate")).To(Equal("Bearer"))
+		}
+
+		w := get(mux, "/debug/pprof/", "Bearer s3cr3t")
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(w.Body.String()).To(ContainSubstring("goroutine"))
+
+		w = get(mux, "/debug/pprof/heap?debug=1", "Bearer s3cr3t")
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(w.Body.String()).To(ContainSubstring("heap profile"))
+
+		w = get(mux, "/debug/goroutines", "Bearer s3cr3t")
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(w.Header().Get("X-Goroutine-Count")).NotTo(BeEmpty())
+		Expect(w.Body.String()).To(ContainSubstring("goroutine "))
+
+		w = get(mux, "/telemetry", "Bearer s3cr3t")
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(w.Body.String()).To(ContainSubstring(`"level":"trace"`))
+
+		mux = OTel.NewAdminMux(&AdminConfiguration{
+			Authenticate: func(r *http.Request) bool { return r.Header.Get("Authorization") == "Basic ok" },
+		})
+		Expect(get(mux, "/debug/pprof/cmdline", "Basic ok").Code).To(Equal(http.StatusOK))
+		Expect(get(mux, "/debug/pprof/cmdline", "Basic no").Code).To(Equal(http.StatusUnauthorized))
+	})
+	t.Run("profile", func(t *testing.T) {
+		labels := map[string]string{}
+		mux := new(Mux).Handle(http.MethodGet, "/users/:id", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, key := range []string{"http.request.method", "http.route", "trace_id"} {
+				labels[key], _ = pprof.Label(r.Context(), key)
+			}
+		}))
+		mux.Middleware = MuxProfileLabels
+
+		traceID, spanID := "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7"
+		sc := trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    trace.TraceID(must(hex.DecodeString(traceID))),
+			SpanID:     trace.SpanID(must(hex.DecodeString(spanID))),
+			TraceFlags: trace.FlagsSampled,
+		})
+
+		r := httptest.NewRequest(http.MethodGet, "/users/42", nil)
+		r = r.WithContext(trace.ContextWithSpanContext(r.Context(), sc))
+		mux.ServeHTTP(httptest.NewRecorder(), r)
+
+		Expect(labels).To(Equal(map[string]string{
+			"http.request.method": http.MethodGet,
+			"http.route":          "/users/:id",
+			"trace_id":            traceID,
+		}))
+		Expect(PatternFromRequest(r)).To(Equal("/users/:id"))
+	})
+}
+
+func must[T any](v T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+
+	return v
+}
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (x *syncBuffer) Write(p []byte) (int, error) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	return x.buf.Write(p)
+}
+
+func (x *syncBuffer) String() string {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	return x.buf.String()
+}
+
+func (x *syncBuffer) Reset() {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	x.buf.Reset()
+}
@@ -0,0 +1,284 @@
+package sdk
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const fileWriterTimeLayout = "2006-01-02T15-04-05.000"
+
+type FileWriterConfiguration struct {
+	// Filename of the active segment, the rotated segments are kept in the same
+	// directory as `<name>-<UTC time><ext>`, e.g. app-2006-01-02T15-04-05.000.log.
+	Filename string
+
+	// MaxSize in bytes of a segment before it is rotated, default to 100MB.
+	MaxSize int64
+
+	// MaxAge of a segment before it is rotated, default to 0 (never).
+	MaxAge time.Duration
+
+	// MaxBackups of the rotated segments to keep, default to 0 (keep all).
+	MaxBackups int
+
+	// Compress the rotated segments with gzip.
+	Compress bool
+}
+
+// NewFileWriter create a writer of NewLogger that rotate the file by size and
+// age; the rotated segments are compressed & pruned in the background.
+//
+//	w, err := sdk.OTel.NewFileWriter(&sdk.FileWriterConfiguration{
+//	  Filename: "/var/log/app.log", MaxSize: 10 << 20, MaxBackups: 7, Compress: true,
+//	})
+//	log := sdk.OTel.NewLogger(ctx, w)
+//	defer w.Close()
+func (open_telemetry) NewFileWriter(c *FileWriterConfiguration) (*FileWriter, error) {
+	if c == nil || c.Filename == "" {
+		return nil, fmt.Errorf("otel: file: %w: filename", ErrInvalidValue)
+	}
+
+	cfg := *c
+	if cfg.MaxSize <= 0 {
+		cfg.MaxSize = 100 << 20
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cfg.Filename), 0o755); err != nil {
+		return nil, fmt.Errorf("otel: file: %w", err)
+	}
+
+	w := &FileWriter{cfg: cfg}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// FileWriter is a rotating file writer, see OTel.NewFileWriter.
+type FileWriter struct {
+	cfg FileWriterConfiguration
+
+	mu     sync.Mutex
+	file   *os.File // nil after a failed rotation, until it is reopened
+	size   int64
+	opened time.Time
+	closed bool
+
+	// cleanup of the rotated segments
+	cleanupMu sync.Mutex
+	wg        sync.WaitGroup
+}
+
+func (w *FileWriter) open() error {
+	f, err := os.OpenFile(w.cfg.Filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("otel: file: %w", err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+
+		return fmt.Errorf("otel: file: %w", err)
+	}
+
+	w.file, w.size, w.opened = f, info.Size(), time.Now()
+
+	return nil
+}
+
+// Write p into the active segment, the segment is rotated before p when it
+// would exceed MaxSize or is older than MaxAge, and reopened when a previous
+// rotation failed.
+func (w *FileWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return 0, fmt.Errorf("otel: file: %w", ErrAlreadyClosed)
+	} else if w.file == nil {
+		if err := w.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	if w.size > 0 && (w.size+int64(len(p)) > w.cfg.MaxSize ||
+		(w.cfg.MaxAge > 0 && time.Since(w.opened) >= w.cfg.MaxAge)) {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+
+	return n, err
+}
+
+// Rotate the active segment regardless of its size & age.
+func (w *FileWriter) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return fmt.Errorf("otel: file: %w", ErrAlreadyClosed)
+	}
+
+	return w.rotate()
+}
+
+func (w *FileWriter) rotate() error {
+	if w.file != nil {
+		err := w.file.Close()
+		w.file = nil
+
+		if err != nil {
+			return fmt.Errorf("otel: file: %w", err)
+		}
+	}
+
+	// the time of the backup is unique, so that the backups are sorted by name
+	ext, now := filepath.Ext(w.cfg.Filename), time.Now().UTC()
+	backup := ""
+
+	for ; backup == "" || fileExists(backup) || fileExists(backup+".gz"); now = now.Add(time.Millisecond) {
+		backup = strings.TrimSuffix(w.cfg.Filename, ext) + "-" + now.Format(fileWriterTimeLayout) + ext
+	}
+
+	if err := os.Rename(w.cfg.Filename, backup); err != nil {
+		return w.reopen(fmt.Errorf("otel: file: %w", err))
+	}
+
+	if err := w.open(); err != nil {
+		// move the backup back as the active segment
+		_ = os.Rename(backup, w.cfg.Filename)
+
+		return w.reopen(err)
+	}
+
+	w.wg.Add(1)
+
+	go w.cleanup(backup)
+
+	return nil
+}
+
+// reopen the active segment after a failed rotation, so that the next Write is
+// not lost on a closed file. When it fail too, the file is left nil to be
+// reopened by the next Write.
+func (w *FileWriter) reopen(err error) error {
+	return new(ListError).Add(err, w.open()).Err()
+}
+
+// cleanup compress the backup and remove the oldest segments over MaxBackups.
+func (w *FileWriter) cleanup(backup string) {
+	defer w.wg.Done()
+
+	w.cleanupMu.Lock()
+	defer w.cleanupMu.Unlock()
+
+	if w.cfg.Compress {
+		_ = fileGzip(backup)
+	}
+
+	if w.cfg.MaxBackups < 1 {
+		return
+	}
+
+	backups := w.Backups()
+	for i := 0; i < len(backups)-w.cfg.MaxBackups; i++ {
+		_ = os.Remove(backups[i])
+	}
+}
+
+// Backups return the rotated segments, from the oldest.
+func (w *FileWriter) Backups() []string {
+	ext := filepath.Ext(w.cfg.Filename)
+	prefix := strings.TrimSuffix(w.cfg.Filename, ext) + "-"
+
+	matches, _ := filepath.Glob(prefix + "*")
+	backups := make([]string, 0, len(matches))
+
+	for _, match := range matches {
+		s := strings.TrimSuffix(strings.TrimSuffix(strings.TrimPrefix(match, prefix), ".gz"), ext)
+		if _, err := time.Parse(fileWriterTimeLayout, s); err != nil {
+			continue
+		}
+
+		backups = append(backups, match)
+	}
+
+	sort.Strings(backups)
+
+	return backups
+}
+
+// Close the active segment and wait for the cleanup of the rotated segments.
+func (w *FileWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return nil
+	}
+
+	w.closed = true
+
+	var err error
+	if w.file != nil {
+		err = w.file.Close()
+		w.file = nil
+	}
+
+	w.wg.Wait()
+
+	if err != nil {
+		return fmt.Errorf("otel: file: %w", err)
+	}
+
+	return nil
+}
+
+func fileExists(name string) bool {
+	_, err := os.Stat(name)
+
+	return err == nil
+}
+
+func fileGzip(name string) (err error) {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(name+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(dst)
+	if _, err = io.Copy(gz, src); err == nil {
+		err = gz.Close()
+	}
+
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		_ = os.Remove(name + ".gz")
+
+		return err
+	}
+
+	return os.Remove(name)
+}
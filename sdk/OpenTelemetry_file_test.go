@@ -0,0 +1,142 @@
+package sdk_test
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/gunawanwijaya/forest/sdk"
+	. "github.com/onsi/gomega"
+)
+
+func test_OpenTelemetryFile(t *testing.T) {
+	t.Parallel()
+
+	Expect := NewWithT(t).Expect
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := OTel.NewFileWriter(nil)
+		Expect(err).To(MatchError(ErrInvalidValue))
+	})
+	t.Run("size", func(t *testing.T) {
+		name := filepath.Join(t.TempDir(), "log", "app.log")
+		w, err := OTel.NewFileWriter(&FileWriterConfiguration{Filename: name, MaxSize: 10, MaxBackups: 2})
+		Expect(err).To(Succeed())
+
+		// a write over MaxSize is kept whole in a segment
+		for _, line := range []string{"0123456789abc\n", "1234\n", "5678\n", "90\n", "abcdefgh\n"} {
+			n, err := w.Write([]byte(line))
+			Expect(err).To(Succeed())
+			Expect(n).To(Equal(len(line)))
+		}
+
+		Expect(w.Close()).To(Succeed())
+		_, err = w.Write([]byte("closed\n"))
+		Expect(err).To(MatchError(ErrAlreadyClosed))
+
+		p, err := os.ReadFile(name)
+		Expect(err).To(Succeed())
+		Expect(string(p)).To(Equal("abcdefgh\n"))
+
+		// the oldest segment "0123456789abc" is removed by MaxBackups
+		backups := w.Backups()
+		Expect(backups).To(HaveLen(2))
+		for i, expect := range []string{"1234\n5678\n", "90\n"} {
+			p, err := os.ReadFile(backups[i])
+			Expect(err).To(Succeed())
+			Expect(string(p)).To(Equal(expect))
+		}
+	})
+	t.Run("age-compress", func(t *testing.T) {
+		name := filepath.Join(t.TempDir(), "app.log")
+		w, err := OTel.NewFileWriter(&FileWriterConfiguration{Filename: name, MaxAge: 20 * time.Millisecond, Compress: true})
+		Expect(err).To(Succeed())
+
+		_, _ = w.Write([]byte("old\n"))
+		time.Sleep(30 * time.Millisecond)
+		_, _ = w.Write([]byte("new\n"))
+		Expect(w.Close()).To(Succeed())
+
+		backups := w.Backups()
+		Expect(backups).To(HaveLen(1))
+		Expect(backups[0]).To(HaveSuffix(".log.gz"))
+
+		f, err := os.Open(backups[0])
+		Expect(err).To(Succeed())
+		defer f.Close()
+
+		gz, err := gzip.NewReader(f)
+		Expect(err).To(Succeed())
+		p, err := io.ReadAll(gz)
+		Expect(err).To(Succeed())
+		Expect(string(p)).To(Equal("old\n"))
+
+		p, err = os.ReadFile(name)
+		Expect(err).To(Succeed())
+		Expect(string(p)).To(Equal("new\n"))
+	})
+	t.Run("logger", func(t *testing.T) {
+		name := filepath.Join(t.TempDir(), "app.log")
+		w, err := OTel.NewFileWriter(&FileWriterConfiguration{Filename: name})
+		Expect(err).To(Succeed())
+
+		OTel.NewLogger(t.Context(), w).Z().Info().Msg("test")
+		Expect(w.Rotate()).To(Succeed())
+		Expect(w.Close()).To(Succeed())
+
+		Expect(w.Backups()).To(HaveLen(1))
+		p, err := os.ReadFile(w.Backups()[0])
+		Expect(err).To(Succeed())
+		Expect(strings.TrimSpace(string(p))).To(HaveSuffix(`"message":"test"}`))
+	})
+	t.Run("rotate-failed", func(t *testing.T) {
+		// the active segment is removed by someone else, the rename fail and the
+		// segment is reopened instead of writing into a closed file
+		name := filepath.Join(t.TempDir(), "app.log")
+		w, err := OTel.NewFileWriter(&FileWriterConfiguration{Filename: name})
+		Expect(err).To(Succeed())
+
+		_, _ = w.Write([]byte("lost\n"))
+		Expect(os.Remove(name)).To(Succeed())
+		Expect(w.Rotate()).To(MatchError(os.ErrNotExist))
+
+		_, err = w.Write([]byte("kept\n"))
+		Expect(err).To(Succeed())
+		Expect(w.Close()).To(Succeed())
+		Expect(w.Backups()).To(BeEmpty())
+
+		p, err := os.ReadFile(name)
+		Expect(err).To(Succeed())
+		Expect(string(p)).To(Equal("kept\n"))
+
+		// the directory is removed too, so the segment is unable to be reopened
+		// until it is created again
+		dir := filepath.Join(t.TempDir(), "logs")
+		Expect(os.Mkdir(dir, 0o755)).To(Succeed())
+
+		name = filepath.Join(dir, "app.log")
+		w, err = OTel.NewFileWriter(&FileWriterConfiguration{Filename: name})
+		Expect(err).To(Succeed())
+
+		Expect(os.RemoveAll(dir)).To(Succeed())
+		Expect(w.Rotate()).To(MatchError(os.ErrNotExist))
+		_, err = w.Write([]byte("lost\n"))
+		Expect(err).To(MatchError(os.ErrNotExist))
+
+		Expect(os.Mkdir(dir, 0o755)).To(Succeed())
+		_, err = w.Write([]byte("kept\n"))
+		Expect(err).To(Succeed())
+		Expect(w.Close()).To(Succeed())
+
+		_, err = w.Write([]byte("lost\n"))
+		Expect(err).To(MatchError(ErrAlreadyClosed))
+
+		p, err = os.ReadFile(name)
+		Expect(err).To(Succeed())
+		Expect(string(p)).To(Equal("kept\n"))
+	})
+}
@@ -0,0 +1,433 @@
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
+	otel_log "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/metric"
+	sdk_log "go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/credentials"
+)
+
+type LogExporterConfiguration struct {
+	// Name of the service & the logger.
+	Name string
+	OTLP struct {
+		GRPC struct{ URL string }
+
+		Insecure bool
+		TLS      *tls.Config
+		Headers  map[string]string
+	}
+
+	// Exporters in addition to the above.
+	Exporters []sdk_log.Exporter
+
+	// QueueSize of the records waiting to be exported, default to 2048. When the
+	// queue is full, Write will wait up to BlockTimeout (default to 0) before
+	// the record is dropped and counted by the otel.sdk.log.dropped metric.
+	QueueSize    int
+	BlockTimeout time.Duration
+
+	// BatchSize of an export, default to 512; a partial batch is exported every
+	// Interval, default to 1s.
+	BatchSize int
+	Interval  time.Duration
+
+	// ExportTimeout of a batch, default to 30s.
+	ExportTimeout time.Duration
+
+	// Attributes of the resource, see TracerConfiguration.Attributes.
+	Attributes []attribute.KeyValue
+}
+
+// NewLogExporter create a writer of NewLogger that export the records over the
+// OpenTelemetry logs SDK; the level is mapped into the severity, the fields into
+// the attributes and the trace_id & span_id (see Logger.Ctx) into the trace
+// context of the record.
+//
+//	logs, err := sdk.OTel.NewLogExporter(ctx, &sdk.LogExporterConfiguration{...})
+//	log := sdk.OTel.NewLogger(ctx, os.Stdout, logs)
+//	defer logs.Shutdown(context.Background())
+func (open_telemetry) NewLogExporter(ctx context.Context, c *LogExporterConfiguration) (*LogExporter, error) {
+	if c == nil || c.Name == "" {
+		return nil, fmt.Errorf("otel: %w", ErrLogExporterNameRequired)
+	}
+
+	exporters := append([]sdk_log.Exporter{}, c.Exporters...)
+	if c.OTLP.GRPC.URL != "" {
+		opts := []otlploggrpc.Option{
+			otlploggrpc.WithEndpoint(c.OTLP.GRPC.URL),
+			otlploggrpc.WithHeaders(c.OTLP.Headers),
+		}
+		if c.OTLP.Insecure {
+			opts = append(opts, otlploggrpc.WithInsecure())
+		} else if c.OTLP.TLS != nil {
+			opts = append(opts, otlploggrpc.WithTLSCredentials(credentials.NewTLS(c.OTLP.TLS)))
+		}
+
+		exporter, err := otlploggrpc.New(ctx, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("otel: log: %w", err)
+		}
+
+		exporters = append(exporters, exporter)
+	}
+
+	if len(exporters) < 1 {
+		return nil, fmt.Errorf("otel: %w", ErrLogExporterEndpointRequired)
+	}
+
+	meter := otel.Meter(c.Name)
+	if m, ok := ctx.Value(meterCtxKey{}).(*Meter); ok && m != nil && m.Meter != nil {
+		meter = m.Meter
+	}
+
+	dropped, err := meter.Int64Counter("otel.sdk.log.dropped",
+		metric.WithDescription("The number of log records dropped as the export queue is full."),
+		metric.WithUnit("{log_record}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("otel: log: %w", err)
+	}
+
+	p := &logBatchProcessor{
+		exporters:     exporters,
+		queueSize:     2048,
+		batchSize:     512,
+		interval:      time.Second,
+		blockTimeout:  c.BlockTimeout,
+		exportTimeout: 30 * time.Second,
+		dropped:       dropped,
+		flush:         make(chan chan struct{}),
+		done:          make(chan struct{}),
+	}
+	if c.QueueSize > 0 {
+		p.queueSize = c.QueueSize
+	}
+
+	if c.BatchSize > 0 {
+		p.batchSize = c.BatchSize
+	}
+
+	if c.Interval > 0 {
+		p.interval = c.Interval
+	}
+
+	if c.ExportTimeout > 0 {
+		p.exportTimeout = c.ExportTimeout
+	}
+
+	p.ctx, p.cancel = context.WithCancel(context.WithoutCancel(ctx))
+	p.queue = make(chan sdk_log.Record, p.queueSize)
+	p.wg.Add(1)
+
+	go p.run()
+
+	lp := sdk_log.NewLoggerProvider(
+		sdk_log.WithResource(otelResource(ctx, c.Name, c.Attributes...)),
+		sdk_log.WithProcessor(p),
+	)
+
+	return &LogExporter{lp.Logger(c.Name), lp, p}, nil
+}
+
+// LogExporter is a zerolog writer, see OTel.NewLogExporter.
+type LogExporter struct {
+	logger    otel_log.Logger
+	provider  *sdk_log.LoggerProvider
+	processor *logBatchProcessor
+}
+
+// Write a zerolog JSON event as a record, the record is queued and Write will
+// never fail.
+func (x *LogExporter) Write(p []byte) (int, error) {
+	fields := map[string]interface{}{}
+
+	dec := json.NewDecoder(bytes.NewReader(p))
+	dec.UseNumber()
+
+	if err := dec.Decode(&fields); err != nil {
+		fields = map[string]interface{}{zerolog.MessageFieldName: string(bytes.TrimSpace(p))}
+	}
+
+	ctx, r := context.Background(), otel_log.Record{}
+	r.SetObservedTimestamp(time.Now())
+
+	if v, ok := fields[zerolog.TimestampFieldName].(string); ok {
+		if ts, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			r.SetTimestamp(ts)
+		}
+	}
+
+	if v, ok := fields[zerolog.LevelFieldName].(string); ok {
+		r.SetSeverity(logSeverity(v))
+		r.SetSeverityText(v)
+	}
+
+	if v, ok := fields[zerolog.MessageFieldName].(string); ok {
+		r.SetBody(otel_log.StringValue(v))
+	}
+
+	traceID, _ := fields["trace_id"].(string)
+	spanID, _ := fields["span_id"].(string)
+
+	var sc trace.SpanContextConfig
+	if b, err := hex.DecodeString(traceID); err == nil && len(b) == len(sc.TraceID) {
+		copy(sc.TraceID[:], b)
+	}
+
+	if b, err := hex.DecodeString(spanID); err == nil && len(b) == len(sc.SpanID) {
+		copy(sc.SpanID[:], b)
+	}
+
+	if sc := trace.NewSpanContext(sc); sc.IsValid() {
+		ctx = trace.ContextWithSpanContext(ctx, sc)
+	}
+
+	for k, v := range fields {
+		switch k {
+		case zerolog.TimestampFieldName, zerolog.LevelFieldName, zerolog.MessageFieldName, "trace_id", "span_id":
+			continue
+		}
+
+		r.AddAttributes(otel_log.KeyValue{Key: k, Value: logValue(v)})
+	}
+
+	x.logger.Emit(ctx, r)
+
+	return len(p), nil
+}
+
+// Dropped return the number of the dropped records.
+func (x *LogExporter) Dropped() int64 { return x.processor.count.Load() }
+
+func (x *LogExporter) ForceFlush(ctx context.Context) error { return x.provider.ForceFlush(ctx) }
+
+func (x *LogExporter) Shutdown(ctx context.Context) error { return x.provider.Shutdown(ctx) }
+
+func logSeverity(level string) otel_log.Severity {
+	switch level {
+	case zerolog.LevelTraceValue:
+		return otel_log.SeverityTrace
+	case zerolog.LevelDebugValue:
+		return otel_log.SeverityDebug
+	case zerolog.LevelInfoValue:
+		return otel_log.SeverityInfo
+	case zerolog.LevelWarnValue:
+		return otel_log.SeverityWarn
+	case zerolog.LevelErrorValue:
+		return otel_log.SeverityError
+	case zerolog.LevelFatalValue:
+		return otel_log.SeverityFatal
+	case zerolog.LevelPanicValue:
+		return otel_log.SeverityFatal4
+	}
+
+	return otel_log.SeverityUndefined
+}
+
+func logValue(v interface{}) otel_log.Value {
+	switch v := v.(type) {
+	case string:
+		return otel_log.StringValue(v)
+	case bool:
+		return otel_log.BoolValue(v)
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return otel_log.Int64Value(i)
+		}
+
+		f, _ := v.Float64()
+
+		return otel_log.Float64Value(f)
+	case nil:
+		return otel_log.Value{}
+	}
+
+	p, _ := json.Marshal(v)
+
+	return otel_log.StringValue(string(p))
+}
+
+// logBatchProcessor is a sdk_log.Processor that export the records in batches,
+// unlike sdk_log.BatchProcessor the dropped records are counted as a metric.
+type logBatchProcessor struct {
+	exporters     []sdk_log.Exporter
+	queue         chan sdk_log.Record
+	queueSize     int
+	batchSize     int
+	interval      time.Duration
+	blockTimeout  time.Duration
+	exportTimeout time.Duration
+
+	// ctx of the exports, cancelled on Shutdown
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	dropped metric.Int64Counter
+	count   atomic.Int64
+
+	flush    chan chan struct{}
+	done     chan struct{}
+	stopped  atomic.Bool
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+func (p *logBatchProcessor) Enabled(context.Context, sdk_log.EnabledParameters) bool {
+	return !p.stopped.Load()
+}
+
+func (p *logBatchProcessor) OnEmit(ctx context.Context, r *sdk_log.Record) error {
+	if p.stopped.Load() {
+		return nil
+	}
+
+	select {
+	case p.queue <- r.Clone():
+		return nil
+	default:
+	}
+
+	if p.blockTimeout > 0 {
+		t := time.NewTimer(p.blockTimeout)
+		defer t.Stop()
+
+		select {
+		case p.queue <- r.Clone():
+			return nil
+		case <-t.C:
+		case <-p.done:
+		}
+	}
+
+	p.count.Add(1)
+	p.dropped.Add(context.WithoutCancel(ctx), 1)
+
+	return nil
+}
+
+func (p *logBatchProcessor) run() {
+	defer p.wg.Done()
+
+	t := time.NewTicker(p.interval)
+	defer t.Stop()
+
+	batch := make([]sdk_log.Record, 0, p.batchSize)
+	export := func() {
+		if len(batch) > 0 {
+			for _, exporter := range p.exporters {
+				ctx, cancel := context.WithTimeout(p.ctx, p.exportTimeout)
+				_ = exporter.Export(ctx, batch)
+
+				cancel()
+			}
+
+			batch = batch[:0]
+		}
+	}
+	drain := func() {
+		for {
+			select {
+			case r := <-p.queue:
+				if batch = append(batch, r); len(batch) >= p.batchSize {
+					export()
+				}
+			default:
+				export()
+
+				return
+			}
+		}
+	}
+
+	for {
+		select {
+		case r := <-p.queue:
+			if batch = append(batch, r); len(batch) >= p.batchSize {
+				export()
+			}
+		case <-t.C:
+			export()
+		case ack := <-p.flush:
+			drain()
+			close(ack)
+		case <-p.done:
+			drain()
+
+			return
+		}
+	}
+}
+
+func (p *logBatchProcessor) ForceFlush(ctx context.Context) error {
+	if p.stopped.Load() {
+		return nil
+	}
+
+	ack := make(chan struct{})
+	select {
+	case p.flush <- ack:
+	case <-p.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-ack:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	errs := new(ListError)
+	for _, exporter := range p.exporters {
+		errs = errs.Add(exporter.ForceFlush(ctx))
+	}
+
+	return errs.Err()
+}
+
+// Shutdown export the records left in the queue, when ctx is done before then
+// the export in progress is cancelled and the remaining records are lost.
+func (p *logBatchProcessor) Shutdown(ctx context.Context) error {
+	errs := new(ListError)
+	p.stopOnce.Do(func() {
+		p.stopped.Store(true)
+		close(p.done)
+
+		stopped := make(chan struct{})
+		go func() { p.wg.Wait(); close(stopped) }()
+
+		var err error
+		select {
+		case <-stopped:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+
+		p.cancel()
+
+		for _, exporter := range p.exporters {
+			errs = errs.Add(exporter.Shutdown(ctx))
+		}
+
+		errs = errs.Add(err)
+	})
+
+	return errs.Err()
+}
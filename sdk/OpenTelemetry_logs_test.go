@@ -0,0 +1,180 @@
+package sdk_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/gunawanwijaya/forest/sdk"
+	. "github.com/onsi/gomega"
+	otel_log "go.opentelemetry.io/otel/log"
+	sdk_log "go.opentelemetry.io/otel/sdk/log"
+	sdk_trace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func test_OpenTelemetryLogs(t *testing.T) {
+	t.Parallel()
+
+	Expect := NewWithT(t).Expect
+	ctx := context.Background()
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := OTel.NewLogExporter(ctx, nil)
+		Expect(err).To(MatchError(ErrLogExporterNameRequired))
+		_, err = OTel.NewLogExporter(ctx, &LogExporterConfiguration{Name: "test"})
+		Expect(err).To(MatchError(ErrLogExporterEndpointRequired))
+	})
+	t.Run("export", func(t *testing.T) {
+		exp := new(logExporterMock)
+		logs, err := OTel.NewLogExporter(ctx, &LogExporterConfiguration{
+			Name:      "test",
+			Exporters: []sdk_log.Exporter{exp},
+			Interval:  time.Hour,
+		})
+		Expect(err).To(Succeed())
+
+		defer func() { Expect(logs.Shutdown(ctx)).To(Succeed()) }()
+
+		tp := sdk_trace.NewTracerProvider()
+		sctx, span := tp.Tracer("test").Start(ctx, "test")
+		defer span.End()
+
+		log := OTel.NewLogger(ctx, logs)
+		log.Ctx(sctx).Warn().Str("user", "alice").Int("attempt", 3).Bool("retry", true).Msg("slow")
+		log.Z().Error().Float64("ratio", .5).Msg("failed")
+
+		Expect(exp.Records()).To(BeEmpty())
+		Expect(logs.ForceFlush(ctx)).To(Succeed())
+
+		records := exp.Records()
+		Expect(records).To(HaveLen(2))
+		Expect(records[0].Severity()).To(Equal(otel_log.SeverityWarn))
+		Expect(records[0].SeverityText()).To(Equal("warn"))
+		Expect(records[0].Body().AsString()).To(Equal("slow"))
+		Expect(records[0].Timestamp().IsZero()).To(BeFalse())
+		Expect(records[0].TraceID()).To(Equal(span.SpanContext().TraceID()))
+		Expect(records[0].SpanID()).To(Equal(span.SpanContext().SpanID()))
+
+		attrs := map[string]otel_log.Value{}
+		records[0].WalkAttributes(func(kv otel_log.KeyValue) bool {
+			attrs[kv.Key] = kv.Value
+
+			return true
+		})
+		Expect(attrs).To(HaveLen(3))
+		Expect(attrs["user"].AsString()).To(Equal("alice"))
+		Expect(attrs["attempt"].AsInt64()).To(Equal(int64(3)))
+		Expect(attrs["retry"].AsBool()).To(BeTrue())
+
+		Expect(records[1].Severity()).To(Equal(otel_log.SeverityError))
+		Expect(records[1].TraceID().IsValid()).To(BeFalse())
+	})
+	t.Run("dropped", func(t *testing.T) {
+		var handler http.Handler
+
+		c := &MeterConfiguration{Name: "test"}
+		c.Prometheus.HTTPHandlerCallback = func(h http.Handler) { handler = h }
+
+		m, err := OTel.NewMeter(ctx, c)
+		Expect(err).To(Succeed())
+
+		defer func() { Expect(m.Shutdown(ctx)).To(Succeed()) }()
+
+		// the export is blocked, so only a record in the queue & a record being
+		// exported are kept
+		exp := &logExporterMock{block: make(chan struct{})}
+		logs, err := OTel.NewLogExporter(m.WithContext(ctx), &LogExporterConfiguration{
+			Name:      "test",
+			Exporters: []sdk_log.Exporter{exp},
+			QueueSize: 1,
+			BatchSize: 1,
+		})
+		Expect(err).To(Succeed())
+
+		log := OTel.NewLogger(ctx, logs)
+		for i := 0; i < 10; i++ {
+			log.Z().Info().Int("i", i).Msg("test")
+		}
+
+		Expect(logs.Dropped()).To(BeNumerically(">=", 8))
+		close(exp.block)
+		Expect(logs.Shutdown(ctx)).To(Succeed())
+		Expect(int64(len(exp.Records())) + logs.Dropped()).To(Equal(int64(10)))
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		Expect(w.Body.String()).To(MatchRegexp(`otel_sdk_log_dropped_total{[^}]*} %d\b`, logs.Dropped()))
+	})
+	t.Run("timeout", func(t *testing.T) {
+		// the export is blocked until its ctx is done, a batch is abandoned after
+		// ExportTimeout & the export in progress is cancelled on Shutdown
+		exp := &logExporterMock{block: make(chan struct{})}
+		logs, err := OTel.NewLogExporter(ctx, &LogExporterConfiguration{
+			Name:          "test",
+			Exporters:     []sdk_log.Exporter{exp},
+			BatchSize:     1,
+			ExportTimeout: 20 * time.Millisecond,
+		})
+		Expect(err).To(Succeed())
+
+		log := OTel.NewLogger(ctx, logs)
+		log.Z().Info().Msg("first")
+		Expect(logs.ForceFlush(ctx)).To(Succeed())
+
+		logs2, err := OTel.NewLogExporter(ctx, &LogExporterConfiguration{
+			Name:      "test",
+			Exporters: []sdk_log.Exporter{exp},
+			BatchSize: 1,
+		})
+		Expect(err).To(Succeed())
+		OTel.NewLogger(ctx, logs2).Z().Info().Msg("second")
+
+		sctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		Expect(logs2.Shutdown(sctx)).To(MatchError(context.DeadlineExceeded))
+		Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+		Expect(logs.Shutdown(ctx)).To(Succeed())
+		Expect(exp.Records()).To(BeEmpty())
+	})
+}
+
+type logExporterMock struct {
+	mu      sync.Mutex
+	block   chan struct{}
+	records []sdk_log.Record
+}
+
+func (x *logExporterMock) Export(ctx context.Context, records []sdk_log.Record) error {
+	if x.block != nil {
+		select {
+		case <-x.block:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	for _, r := range records {
+		x.records = append(x.records, r.Clone())
+	}
+
+	return nil
+}
+
+func (x *logExporterMock) Records() []sdk_log.Record {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	return append([]sdk_log.Record{}, x.records...)
+}
+
+func (x *logExporterMock) Shutdown(context.Context) error { return nil }
+
+func (x *logExporterMock) ForceFlush(context.Context) error { return nil }
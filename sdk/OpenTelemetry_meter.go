@@ -0,0 +1,232 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/metrics"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/runtime"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// -----------------------------------------------------------------------------
+// Runtime
+// -----------------------------------------------------------------------------
+
+// meterRuntime register the Go runtime metrics (go.memory.*, go.goroutine.count,
+// go.processor.limit, go.config.gogc & go.gc.count) and the process metrics
+// (process.uptime & process.cpu.time) into mp.
+func meterRuntime(mp metric.MeterProvider) error {
+	if err := runtime.Start(runtime.WithMeterProvider(mp)); err != nil {
+		return err
+	}
+
+	meter, start := mp.Meter(otelScope), time.Now()
+
+	gc, err := meter.Int64ObservableCounter("go.gc.count",
+		metric.WithDescription("The number of completed GC cycles."),
+		metric.WithUnit("{gc_cycle}"),
+	)
+	if err != nil {
+		return err
+	}
+
+	uptime, err := meter.Float64ObservableGauge("process.uptime",
+		metric.WithDescription("The time the process has been running."),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return err
+	}
+
+	cpu, err := meter.Float64ObservableCounter("process.cpu.time",
+		metric.WithDescription("The CPU time consumed by the process, by cpu.mode."),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return err
+	}
+
+	user := metric.WithAttributeSet(attribute.NewSet(attribute.String("cpu.mode", "user")))
+	system := metric.WithAttributeSet(attribute.NewSet(attribute.String("cpu.mode", "system")))
+
+	var mu sync.Mutex
+
+	samples := []metrics.Sample{{Name: "/gc/cycles/total:gc-cycles"}}
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if metrics.Read(samples); samples[0].Value.Kind() == metrics.KindUint64 {
+			o.ObserveInt64(gc, int64(samples[0].Value.Uint64()))
+		}
+
+		o.ObserveFloat64(uptime, time.Since(start).Seconds())
+
+		if u, s, ok := processCPUTime(); ok {
+			o.ObserveFloat64(cpu, u.Seconds(), user)
+			o.ObserveFloat64(cpu, s.Seconds(), system)
+		}
+
+		return nil
+	}, gc, uptime, cpu)
+
+	return err
+}
+
+// -----------------------------------------------------------------------------
+// Operation
+// -----------------------------------------------------------------------------
+
+// NewOperation create the rate, error & duration (RED) instruments of a named
+// operation from the Meter in the context, or the global meter provider. The
+// instruments are shared by the operations and set apart by the attributes:
+//
+//	operation.requests  {request}  counter    operation.name
+//	operation.errors    {error}    counter    operation.name, error.type
+//	operation.duration  s          histogram  operation.name, error.type
+//
+// The HTTP operations also carry the http.request.method &
+// http.response.status_code attributes, a status code >= 500 is an error.
+//
+//	mux.Handle(http.MethodGet, "/", sdk.OTel.NewOperation(ctx, "app1_http_get_homepage").Handler(h))
+//	err := sdk.OTel.NewOperation(ctx, "postgresql_core.get_user").Do(ctx, func(ctx context.Context) error { ... })
+func (open_telemetry) NewOperation(ctx context.Context, name string) *Operation {
+	meter := otel.Meter(otelScope)
+	if m, ok := ctx.Value(meterCtxKey{}).(*Meter); ok && m != nil && m.Meter != nil {
+		meter = m.Meter
+	}
+
+	o := &Operation{name: attribute.String("operation.name", name)}
+	errs := new(ListError)
+
+	var err error
+	o.requests, err = meter.Int64Counter("operation.requests",
+		metric.WithDescription("The number of the operations started."),
+		metric.WithUnit("{request}"),
+	)
+	errs = errs.Add(err)
+	o.errors, err = meter.Int64Counter("operation.errors",
+		metric.WithDescription("The number of the operations failed."),
+		metric.WithUnit("{error}"),
+	)
+	errs = errs.Add(err)
+	o.duration, err = meter.Float64Histogram("operation.duration",
+		metric.WithDescription("The duration of the operations."),
+		metric.WithUnit("s"),
+	)
+	errs = errs.Add(err)
+
+	// the instruments are usable regardless of err, see metric.Meter
+	if err = errs.Err(); err != nil {
+		otel.Handle(fmt.Errorf("otel: operation: %w", err))
+	}
+
+	return o
+}
+
+// otelScope is the instrumentation scope of the instruments created by the sdk.
+const otelScope = "github.com/gunawanwijaya/forest/sdk"
+
+// Operation record the RED metrics of a named operation, see OTel.NewOperation.
+type Operation struct {
+	name     attribute.KeyValue
+	requests metric.Int64Counter
+	errors   metric.Int64Counter
+	duration metric.Float64Histogram
+}
+
+// Start the operation, the returned func record the operation with err.
+//
+//	end := op.Start(ctx)
+//	defer func() { end(err) }()
+func (o *Operation) Start(ctx context.Context, attrs ...attribute.KeyValue) func(err error) {
+	start := time.Now()
+	attrs = append([]attribute.KeyValue{o.name}, attrs...)
+	o.requests.Add(ctx, 1, metric.WithAttributes(attrs...))
+
+	return func(err error) {
+		if err != nil {
+			attrs = append(attrs, attribute.String("error.type", operationErrorType(err)))
+			o.errors.Add(ctx, 1, metric.WithAttributes(attrs...))
+		}
+
+		o.duration.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(attrs...))
+	}
+}
+
+// Do record fn as the operation.
+func (o *Operation) Do(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	end := o.Start(ctx)
+	defer func() { end(err) }()
+
+	return fn(ctx)
+}
+
+// Handler record next as the operation.
+func (o *Operation) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var (
+			ctx    = r.Context()
+			method = attribute.String("http.request.method", r.Method)
+			start  = time.Now()
+			sw     = &operationResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		)
+
+		o.requests.Add(ctx, 1, metric.WithAttributes(o.name, method))
+
+		defer func() {
+			attrs := []attribute.KeyValue{o.name, method, attribute.Int("http.response.status_code", sw.status)}
+			if sw.status >= http.StatusInternalServerError {
+				attrs = append(attrs, attribute.String("error.type", strconv.Itoa(sw.status)))
+				o.errors.Add(ctx, 1, metric.WithAttributes(attrs...))
+			}
+
+			o.duration.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(attrs...))
+		}()
+
+		next.ServeHTTP(sw, r)
+	})
+}
+
+// operationErrorType is the error.type of err, the known errors are kept
+// low-cardinality and the rest fall back into the type of err.
+func operationErrorType(err error) string {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	}
+
+	return fmt.Sprintf("%T", err)
+}
+
+type operationResponseWriter struct {
+	http.ResponseWriter
+	status  int
+	written bool
+}
+
+func (w *operationResponseWriter) WriteHeader(status int) {
+	if !w.written {
+		w.status, w.written = status, true
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *operationResponseWriter) Write(p []byte) (int, error) {
+	w.written = true
+
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *operationResponseWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
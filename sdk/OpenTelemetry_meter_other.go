@@ -0,0 +1,8 @@
+//go:build !unix
+
+package sdk
+
+import "time"
+
+// processCPUTime is unavailable, the process.cpu.time is not observed.
+func processCPUTime() (user, system time.Duration, ok bool) { return 0, 0, false }
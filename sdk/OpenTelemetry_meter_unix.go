@@ -0,0 +1,18 @@
+//go:build unix
+
+package sdk
+
+import (
+	"syscall"
+	"time"
+)
+
+// processCPUTime return the user & system CPU time of the process.
+func processCPUTime() (user, system time.Duration, ok bool) {
+	var u syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &u); err != nil {
+		return 0, 0, false
+	}
+
+	return time.Duration(u.Utime.Nano()), time.Duration(u.Stime.Nano()), true
+}
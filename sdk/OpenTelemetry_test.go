@@ -7,12 +7,26 @@ import (
 	"encoding/base64"
 	"io"
 	"io/fs"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	. "github.com/gunawanwijaya/forest/sdk"
 	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	sdk_trace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
 )
 
 func test_OpenTelemetry(t *testing.T) {
@@ -110,3 +124,461 @@ func test_OpenTelemetry(t *testing.T) {
 		Expect(buf2.Bytes()).Should(Equal(exp))
 	}(t)
 }
+
+func test_OpenTelemetryMeter(t *testing.T) {
+	t.Parallel()
+
+	Expect := NewWithT(t).Expect
+	ctx := context.Background()
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := OTel.NewMeter(ctx, nil)
+		Expect(err).To(MatchError(ErrMeterNameRequired))
+		_, err = OTel.NewMeter(ctx, &MeterConfiguration{Name: "test"})
+		Expect(err).To(MatchError(ErrMeterEndpointRequired))
+	})
+	t.Run("prometheus", func(t *testing.T) {
+		var handler http.Handler
+
+		c := &MeterConfiguration{Name: "test", Buckets: map[string][]float64{"test.*": {.1, .5, 1}}}
+		c.Prometheus.HTTPHandlerCallback = func(h http.Handler) { handler = h }
+
+		m, err := OTel.NewMeter(ctx, c)
+		Expect(err).To(Succeed())
+		Expect(handler).NotTo(BeNil())
+		Expect(m.WithContext(ctx).Value(struct{}{})).To(BeNil())
+		defer func() { Expect(m.Shutdown(ctx)).To(Succeed()) }()
+
+		// the Meter in the context is copied without its provider, shutting the
+		// copy down is a no-op
+		m2, err := OTel.NewMeter(m.WithContext(ctx), nil)
+		Expect(err).To(Succeed())
+		Expect(m2).NotTo(BeIdenticalTo(m))
+		Expect(m2.Meter).To(Equal(m.Meter))
+		Expect(m2.Shutdown(ctx)).To(Succeed())
+
+		h, err := m.Float64Histogram("test.duration", metric.WithUnit("s"))
+		Expect(err).To(Succeed())
+
+		// a measurement within a sampled span is recorded as an exemplar
+		tp := sdk_trace.NewTracerProvider(sdk_trace.WithSampler(sdk_trace.AlwaysSample()))
+		sctx, span := tp.Tracer("test").Start(ctx, "test")
+		h.Record(sctx, .3)
+		span.End()
+
+		r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		r.Header.Set("Accept", "application/openmetrics-text; version=1.0.0")
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		body := w.Body.String()
+		Expect(body).To(ContainSubstring(`test_duration_seconds_bucket{otel_scope_name="test"`))
+		Expect(body).To(MatchRegexp(`le="0.5"} 1 # {(span_id="%s",trace_id="%s"|trace_id="%[2]s",span_id="%[1]s")} 0.3`,
+			span.SpanContext().SpanID(), span.SpanContext().TraceID()))
+		Expect(body).NotTo(ContainSubstring(`le="0.25"`))
+	})
+	t.Run("runtime", func(t *testing.T) {
+		var handler http.Handler
+
+		c := &MeterConfiguration{Name: "test"}
+		c.Prometheus.HTTPHandlerCallback = func(h http.Handler) { handler = h }
+
+		m, err := OTel.NewMeter(ctx, c)
+		Expect(err).To(Succeed())
+
+		defer func() { Expect(m.Shutdown(ctx)).To(Succeed()) }()
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+		body := w.Body.String()
+		Expect(body).To(MatchRegexp(`(?m)^go_goroutine_count{[^}]*} [1-9]`))
+		Expect(body).To(MatchRegexp(`(?m)^go_memory_used_bytes{[^}]*} [1-9]`))
+		Expect(body).To(MatchRegexp(`(?m)^go_gc_count_total{[^}]*} \d`))
+		Expect(body).To(MatchRegexp(`(?m)^process_uptime_seconds{[^}]*} \d`))
+
+		c.DisableRuntime = true
+		m2, err := OTel.NewMeter(ctx, c)
+		Expect(err).To(Succeed())
+
+		defer func() { Expect(m2.Shutdown(ctx)).To(Succeed()) }()
+
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		Expect(w.Body.String()).NotTo(ContainSubstring(`go_goroutine_count`))
+	})
+	t.Run("otlp", func(t *testing.T) {
+		export := func(insecure bool) http.Header {
+			headers := make(chan http.Header, 1)
+			srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case headers <- r.Header.Clone():
+				default:
+				}
+			}))
+			defer srv.Close()
+
+			c := &MeterConfiguration{Name: "test", DisableRuntime: true}
+			c.OTLP.Insecure = insecure
+			c.OTLP.Headers = map[string]string{"api-key": "s3cr3t"}
+			c.OTLP.Interval = time.Hour
+
+			if insecure {
+				srv.Start()
+			} else {
+				// TLS by default, using the given config to trust the collector
+				srv.StartTLS()
+				c.OTLP.TLS = srv.Client().Transport.(*http.Transport).TLSClientConfig
+			}
+
+			c.OTLP.HTTP.URL = srv.Listener.Addr().String()
+
+			m, err := OTel.NewMeter(ctx, c)
+			Expect(err).To(Succeed())
+
+			counter, err := m.Int64Counter("test.count")
+			Expect(err).To(Succeed())
+			counter.Add(ctx, 1)
+
+			// the pending measurements are exported on shutdown
+			Expect(m.Shutdown(ctx)).To(Succeed())
+
+			var h http.Header
+			Expect(headers).To(Receive(&h))
+
+			return h
+		}
+
+		Expect(export(false).Get("api-key")).To(Equal("s3cr3t"))
+		Expect(export(true).Get("api-key")).To(Equal("s3cr3t"))
+	})
+	t.Run("operation", func(t *testing.T) {
+		var handler http.Handler
+
+		c := &MeterConfiguration{Name: "test", DisableRuntime: true}
+		c.Prometheus.HTTPHandlerCallback = func(h http.Handler) { handler = h }
+
+		m, err := OTel.NewMeter(ctx, c)
+		Expect(err).To(Succeed())
+
+		defer func() { Expect(m.Shutdown(ctx)).To(Succeed()) }()
+
+		mctx := m.WithContext(ctx)
+		op := OTel.NewOperation(mctx, "test_do")
+		Expect(op.Do(mctx, func(context.Context) error { return nil })).To(Succeed())
+		Expect(op.Do(mctx, func(context.Context) error { return context.Canceled })).To(MatchError(context.Canceled))
+
+		h := OTel.NewOperation(mctx, "test_http").Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Has("fail") {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		}))
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/?fail", nil))
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+		body := w.Body.String()
+		Expect(body).To(MatchRegexp(`operation_requests_total{[^}]*operation_name="test_do"[^}]*} 2`))
+		Expect(body).To(MatchRegexp(`operation_errors_total{error_type="canceled",[^}]*operation_name="test_do"[^}]*} 1`))
+		Expect(body).To(MatchRegexp(`operation_duration_seconds_count{[^}]*operation_name="test_do"[^}]*} 1`))
+		Expect(body).To(MatchRegexp(`operation_requests_total{http_request_method="GET",[^}]*operation_name="test_http"[^}]*} 2`))
+		Expect(body).To(MatchRegexp(`operation_errors_total{error_type="503",http_request_method="GET",http_response_status_code="503",[^}]*operation_name="test_http"[^}]*} 1`))
+		Expect(body).To(MatchRegexp(`operation_duration_seconds_count{http_request_method="GET",http_response_status_code="200",[^}]*operation_name="test_http"[^}]*} 1`))
+	})
+}
+
+func test_OpenTelemetryTracer(t *testing.T) {
+	t.Parallel()
+
+	Expect := NewWithT(t).Expect
+	ctx := context.Background()
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := OTel.NewTracer(ctx, nil)
+		Expect(err).To(MatchError(ErrTracerServiceNameRequired))
+		_, err = OTel.NewTracer(ctx, &TracerConfiguration{Stdout: io.Discard})
+		Expect(err).To(MatchError(ErrTracerServiceNameRequired))
+	})
+	t.Run("exporters", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		exp := tracetest.NewInMemoryExporter()
+		tr, err := OTel.NewTracer(ctx, &TracerConfiguration{
+			Name:       "test",
+			Stdout:     buf,
+			Exporters:  []sdk_trace.SpanExporter{exp},
+			Attributes: []attribute.KeyValue{attribute.String("deployment.environment.name", "test")},
+		})
+		Expect(err).To(Succeed())
+
+		defer func() { Expect(tr.Shutdown(ctx)).To(Succeed()) }()
+
+		_, span := tr.Start(ctx, "test-span")
+		span.End()
+		Expect(tr.ForceFlush(ctx)).To(Succeed())
+
+		// every span is exported into each exporter
+		Expect(exp.GetSpans()).To(HaveLen(1))
+		Expect(exp.GetSpans()[0].Name).To(Equal("test-span"))
+		Expect(buf.String()).To(ContainSubstring(`"Name":"test-span"`))
+
+		res := exp.GetSpans()[0].Resource
+		Expect(res.Attributes()).To(ContainElements(
+			attribute.String("service.name", "test"),
+			attribute.String("deployment.environment.name", "test"),
+		))
+		Expect(res.Set().HasValue("process.pid")).To(BeTrue())
+	})
+	t.Run("otlp", func(t *testing.T) {
+		// the OTLP exporters are started, so that the spans are exported on flush
+		grpcSrv, grpcStub := grpc.NewServer(), &otlpTraceStub{spans: make(chan string, 1)}
+		coltracepb.RegisterTraceServiceServer(grpcSrv, grpcStub)
+
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).To(Succeed())
+
+		go func() { _ = grpcSrv.Serve(lis) }()
+		defer grpcSrv.Stop()
+
+		headers := make(chan http.Header, 1)
+		httpSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case headers <- r.Header.Clone():
+			default:
+			}
+		}))
+		defer httpSrv.Close()
+
+		c := &TracerConfiguration{Name: "test"}
+		c.OTLP.GRPC.URL = lis.Addr().String()
+		c.OTLP.HTTP.URL = httpSrv.Listener.Addr().String()
+		c.OTLP.Insecure = true
+		c.OTLP.Headers = map[string]string{"api-key": "s3cr3t"}
+
+		tr, err := OTel.NewTracer(ctx, c)
+		Expect(err).To(Succeed())
+
+		defer func() { Expect(tr.Shutdown(ctx)).To(Succeed()) }()
+
+		_, span := tr.Start(ctx, "test-span")
+		span.End()
+		Expect(tr.ForceFlush(ctx)).To(Succeed())
+
+		Expect(grpcStub.spans).To(Receive(Equal("test-span")))
+
+		var h http.Header
+		Expect(headers).To(Receive(&h))
+		Expect(h.Get("api-key")).To(Equal("s3cr3t"))
+	})
+	t.Run("sample-ratio", func(t *testing.T) {
+		exp := tracetest.NewInMemoryExporter()
+		tr, err := OTel.NewTracer(ctx, &TracerConfiguration{
+			Name:        "test",
+			Exporters:   []sdk_trace.SpanExporter{exp},
+			SampleRatio: -1,
+		})
+		Expect(err).To(Succeed())
+
+		defer func() { Expect(tr.Shutdown(ctx)).To(Succeed()) }()
+
+		_, span := tr.Start(ctx, "root")
+		span.End()
+		Expect(span.SpanContext().IsSampled()).To(BeFalse())
+
+		// the child of a sampled remote parent follow the decision of its parent
+		parent := trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    trace.TraceID{1},
+			SpanID:     trace.SpanID{1},
+			TraceFlags: trace.FlagsSampled,
+			Remote:     true,
+		})
+		_, span = tr.Start(trace.ContextWithRemoteSpanContext(ctx, parent), "child")
+		span.End()
+		Expect(span.SpanContext().IsSampled()).To(BeTrue())
+
+		Expect(tr.ForceFlush(ctx)).To(Succeed())
+		Expect(exp.GetSpans()).To(HaveLen(1))
+		Expect(exp.GetSpans()[0].Name).To(Equal("child"))
+	})
+}
+
+// otlpTraceStub is an OTLP trace collector that receive the name of the first
+// exported span.
+type otlpTraceStub struct {
+	coltracepb.UnimplementedTraceServiceServer
+	spans chan string
+}
+
+func (x *otlpTraceStub) Export(
+	ctx context.Context, req *coltracepb.ExportTraceServiceRequest,
+) (*coltracepb.ExportTraceServiceResponse, error) {
+	for _, rs := range req.GetResourceSpans() {
+		for _, ss := range rs.GetScopeSpans() {
+			for _, span := range ss.GetSpans() {
+				select {
+				case x.spans <- span.GetName():
+				default:
+				}
+			}
+		}
+	}
+
+	return &coltracepb.ExportTraceServiceResponse{}, nil
+}
+
+func test_OpenTelemetrySetup(t *testing.T) {
+	t.Parallel()
+
+	Expect := NewWithT(t).Expect
+	ctx := context.Background()
+
+	t.Run("setup", func(t *testing.T) {
+		spans, buf := new(bytes.Buffer), new(bytes.Buffer)
+		c := &OTelConfiguration{
+			Tracer: &TracerConfiguration{Name: "test", Stdout: spans},
+			Meter:  &MeterConfiguration{Name: "test"},
+			Logger: []io.Writer{buf},
+		}
+		c.Meter.Prometheus.HTTPHandlerCallback = func(http.Handler) {}
+
+		ctx, shutdown, err := OTel.Setup(ctx, c)
+		Expect(err).To(Succeed())
+
+		// the context carry the tracer, meter & logger
+		tr, err := OTel.NewTracer(ctx, nil)
+		Expect(err).To(Succeed())
+		_, err = OTel.NewMeter(ctx, nil)
+		Expect(err).To(Succeed())
+		OTel.NewLogger(ctx).Z().Info().Msg("test")
+		Expect(buf.String()).To(ContainSubstring(`"message":"test"`))
+
+		sctx, span := tr.Start(ctx, "test-span")
+		span.End()
+
+		member, _ := baggage.NewMember("tenant", "acme")
+		bag, _ := baggage.New(member)
+		sctx = baggage.ContextWithBaggage(sctx, bag)
+
+		carrier := propagation.MapCarrier{}
+		otel.GetTextMapPropagator().Inject(sctx, carrier)
+		Expect(carrier.Get("traceparent")).To(ContainSubstring(span.SpanContext().TraceID().String()))
+		Expect(carrier.Get("baggage")).To(Equal("tenant=acme"))
+
+		// the spans are flushed on shutdown
+		Expect(shutdown(ctx)).To(Succeed())
+		Expect(spans.String()).To(ContainSubstring(`"Name":"test-span"`))
+	})
+	t.Run("invalid", func(t *testing.T) {
+		_, shutdown, err := OTel.Setup(ctx, &OTelConfiguration{Tracer: &TracerConfiguration{Name: "test"}})
+		Expect(err).To(MatchError(ErrTracerEndpointRequired))
+		Expect(shutdown(ctx)).To(Succeed())
+	})
+}
+
+func test_OpenTelemetryLogger(t *testing.T) {
+	t.Parallel()
+
+	Expect := NewWithT(t).Expect
+	ctx := context.Background()
+
+	t.Run("trace", func(t *testing.T) {
+		buf, exp := new(bytes.Buffer), tracetest.NewInMemoryExporter()
+		log := OTel.NewLogger(ctx, buf).SpanEvent(true)
+
+		tp := sdk_trace.NewTracerProvider(sdk_trace.WithSyncer(exp))
+		sctx, span := tp.Tracer("test").Start(ctx, "test")
+		log.Ctx(sctx).Info().Msg("info")
+		log.Ctx(sctx).Error().Msg("error")
+		span.End()
+
+		traceID, spanID := span.SpanContext().TraceID().String(), span.SpanContext().SpanID().String()
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		Expect(lines).To(HaveLen(2))
+		for _, line := range lines {
+			Expect(line).To(ContainSubstring(`"trace_id":"` + traceID + `","span_id":"` + spanID + `"`))
+		}
+
+		// only the error is recorded as a span event
+		Expect(exp.GetSpans()).To(HaveLen(1))
+		Expect(exp.GetSpans()[0].Events).To(HaveLen(1))
+		Expect(exp.GetSpans()[0].Events[0].Attributes).To(ContainElements(
+			attribute.String("log.severity", "error"),
+			attribute.String("log.message", "error"),
+		))
+
+		buf.Reset()
+		log.Ctx(ctx).Error().Msg("no-span")
+		Expect(buf.String()).NotTo(ContainSubstring("trace_id"))
+	})
+	t.Run("fields", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		log := OTel.NewLogger(ctx, buf)
+
+		var requestID string
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := OTel.ContextWithLogFields(r.Context(), map[string]interface{}{"user": "alice"})
+			log.Ctx(ctx).Info().Msg("test")
+			requestID = w.Header().Get("X-Request-ID")
+		})
+
+		w := httptest.NewRecorder()
+		Middleware(MuxLogFields(nil), next).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users", nil))
+		Expect(requestID).To(HaveLen(32))
+		Expect(buf.String()).To(ContainSubstring(`"request_id":"` + requestID + `","route":"GET /users","user":"alice"`))
+
+		buf.Reset()
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("X-Request-ID", "abc")
+		Middleware(MuxLogFields(nil), next).ServeHTTP(httptest.NewRecorder(), r)
+		Expect(buf.String()).To(ContainSubstring(`"request_id":"abc"`))
+
+		// an oversized request ID is replaced instead of being echoed
+		buf.Reset()
+		r = httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("X-Request-ID", strings.Repeat("x", 9))
+		Middleware(MuxLogFields(&MuxLogFieldsConfiguration{MaxLength: 8}), next).ServeHTTP(httptest.NewRecorder(), r)
+		Expect(requestID).To(HaveLen(32))
+		Expect(buf.String()).NotTo(ContainSubstring("xxxxxxxxx"))
+
+		// the route is the pattern of the Mux
+		buf.Reset()
+		mux := new(Mux).Handle(http.MethodGet, "/users/:id", next)
+		mux.Middleware = func(h http.Handler) http.Handler { return Middleware(MuxLogFields(nil), h) }
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/42", nil))
+		Expect(buf.String()).To(ContainSubstring(`"route":"GET /users/:id"`))
+	})
+	t.Run("sample", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		log := OTel.NewLogger(ctx, buf).Sample(&LogSampleConfiguration{
+			Burst:  2,
+			Period: time.Hour,
+			Levels: map[string]uint32{"debug": 0, "info": 3},
+		})
+
+		// the burst pass any level, then 1 of 3 info events, no debug events & every
+		// warn events
+		for i := 0; i < 10; i++ {
+			log.Z().Debug().Int("i", i).Msg("debug")
+			log.Z().Info().Int("i", i).Msg("info")
+			log.Z().Warn().Int("i", i).Msg("warn")
+		}
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		count := map[string]int{}
+		for _, line := range lines {
+			for _, level := range []string{"debug", "info", "warn"} {
+				if strings.Contains(line, `"level":"`+level+`"`) {
+					count[level]++
+				}
+			}
+		}
+
+		Expect(count).To(Equal(map[string]int{"debug": 1, "info": 4, "warn": 10}))
+
+		buf.Reset()
+		log.Sample(nil).Z().Debug().Msg("debug")
+		Expect(buf.String()).To(ContainSubstring(`"level":"debug"`))
+	})
+}
@@ -6,7 +6,9 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"regexp"
 	"strings"
+	"unicode"
 )
 
 var (
@@ -14,6 +16,10 @@ var (
 	ErrInvalidArgumentsScan = errors.New("Invalid arguments for scan")
 	ErrNoColumnsReturned    = errors.New("No columns returned")
 	ErrInvalidDatabase      = errors.New("Invalid database")
+
+	// sqlIdentifier is an optionally schema-qualified identifier, e.g. a table
+	// name that is concatenated into a query.
+	sqlIdentifier = regexp.MustCompile(`^[A-Za-z_]\w*(\.[A-Za-z_]\w*)?$`)
 )
 
 type (
@@ -71,6 +77,8 @@ const (
 	_ADD      = "ADD"
 	_EXEC     = "EXEC"
 	_TRUNCATE = "TRUNCATE"
+	_EXPLAIN  = "EXPLAIN"
+	_ANALYZE  = "ANALYZE"
 )
 
 func SQLNoScan() interface{} { return new([]byte) }
@@ -107,6 +115,24 @@ type BoxQuery interface {
 	Scan(row func(i int) List) (err error)
 }
 
+// BoxQueryRow will wrap `QueryContext` so that we can Scan a single row later
+//
+//	BoxQueryRow(cmd.QueryContext(ctx, "..."))
+//
+// It behave like *sql.Row, any error from routing or validating the query is
+// carried and returned by Scan, and sql.ErrNoRows is returned when no row found.
+func (SQL) BoxQueryRow(sqlRows *sql.Rows, err error) BoxQueryRow { return boxQueryRow{sqlRows, err} }
+
+type BoxQueryRow interface {
+	// Err return the carried error without scanning, it is nil when the query
+	// is executed successfully.
+	Err() (err error)
+
+	// Scan the first row into dest and discard the rest, dest must be the
+	// same length as the columns.
+	Scan(dest ...interface{}) (err error)
+}
+
 // EndTx will end transaction with provided *sql.Tx and error. The tx argument
 // should be valid, and then will check the err, if any error occurred, will
 // commencing the ROLLBACK else will COMMIT the transaction.
@@ -219,28 +245,91 @@ func (x boxQuery) Scan(row func(i int) List) (err error) {
 	return err
 }
 
-// RemoveComment from sql command.
+type boxQueryRow struct {
+	sqlRows *sql.Rows
+	err     error
+}
+
+func (x boxQueryRow) Err() (err error) {
+	if x.err != nil {
+		return fmt.Errorf("database: BoxQueryRow: %w", x.err)
+	}
+
+	return nil
+}
+
+func (x boxQueryRow) Scan(dest ...interface{}) (err error) {
+	if err = x.Err(); err != nil {
+		return err
+	} else if len(dest) < 1 {
+		return fmt.Errorf("database: BoxQueryRow: %w", ErrInvalidArgumentsScan)
+	} else if x.sqlRows == nil {
+		return fmt.Errorf("database: BoxQueryRow: %w", sql.ErrNoRows)
+	}
+
+	found := false
+	err = boxQuery{x.sqlRows, nil}.Scan(func(i int) List {
+		if i > 0 {
+			return nil
+		}
+
+		found = true
+
+		return List(dest)
+	})
+
+	if err != nil {
+		return fmt.Errorf("database: BoxQueryRow: %w", err)
+	} else if err = x.sqlRows.Err(); err != nil {
+		return fmt.Errorf("database: BoxQueryRow: %w", err)
+	} else if !found {
+		return fmt.Errorf("database: BoxQueryRow: %w", sql.ErrNoRows)
+	}
+
+	return nil
+}
+
+// RemoveComment from sql command, both line `--` and block `/* */` comments
+// are removed, while anything inside a quoted literal is kept as is.
 func (SQL) RemoveComment(query string) (query_ string) {
-	commentStartIdx, replaces := -1, []string{}
+	b, quote := new(strings.Builder), byte(0)
 
-	for i := range query {
-		// we found sql comment
-		if i-1 >= 0 && i+1 < len(query) && query[i] == '-' && query[i+1] == '-' && query[i-1] != '-' {
-			commentStartIdx = i
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '-' && i+1 < len(query) && query[i+1] == '-':
+			for i < len(query) && query[i] != '\n' {
+				i++
+			}
+
+			if i < len(query) {
+				_ = b.WriteByte('\n')
+			}
 
 			continue
-		}
+		case c == '/' && i+1 < len(query) && query[i+1] == '*':
+			if end := strings.Index(query[i+2:], "*/"); end < 0 {
+				i = len(query)
+			} else {
+				i += end + 3
+			}
 
-		if commentStartIdx > -1 && query[i] == '\n' {
-			replaces = append(replaces, query[commentStartIdx:i])
+			_ = b.WriteByte(' ')
+
+			continue
 		}
-	}
 
-	for _, v := range replaces {
-		query = strings.Replace(query, v, "", 1)
+		_ = b.WriteByte(c)
 	}
 
-	return strings.TrimSpace(query)
+	return strings.TrimSpace(b.String())
 }
 
 // IsMultipleCommand is a naive implementation of checking multiple sql command.
@@ -257,9 +346,15 @@ func (SQL) IsMultipleCommand(query string) (ok bool) {
 	return validCount > 1
 }
 
-// IsSELECTCommand only valid if starts with SELECT.
+// IsSELECTCommand only valid if starts with SELECT, an EXPLAIN without ANALYZE
+// is read-only as well since the statement is only planned and never run; while
+// an EXPLAIN ANALYZE is read-only only when the explained statement is.
 func (SQL) IsSELECTCommand(query string) (ok bool) {
 	query = strings.ToUpper(strings.TrimSpace(SQL{}.RemoveComment(query)))
+	if explain, analyze, stmt := sqlExplain(query); explain {
+		return !analyze || strings.HasPrefix(stmt, _SELECT)
+	}
+
 	for _, s := range []string{_SELECT} {
 		ok = ok || strings.HasPrefix(query, s) || strings.Contains(query, s)
 	}
@@ -267,6 +362,52 @@ func (SQL) IsSELECTCommand(query string) (ok bool) {
 	return ok
 }
 
+// sqlExplain parse the options of an uppercase EXPLAIN query, either the list of
+// `EXPLAIN (ANALYZE, FORMAT JSON) stmt` or the legacy `EXPLAIN ANALYZE VERBOSE
+// stmt`, and return the explained statement.
+func sqlExplain(query string) (explain, analyze bool, stmt string) {
+	rest := strings.TrimPrefix(query, _EXPLAIN)
+	if len(rest) == len(query) || (rest != "" && !unicode.IsSpace(rune(rest[0])) && rest[0] != '(') {
+		return false, false, query
+	}
+
+	if rest = strings.TrimSpace(rest); strings.HasPrefix(rest, "(") {
+		end := strings.IndexByte(rest, ')')
+		if end < 0 {
+			end = len(rest) - 1
+		}
+
+		for _, opt := range strings.Split(rest[1:end], ",") {
+			switch f := strings.Fields(opt); {
+			case len(f) < 1 || (f[0] != _ANALYZE && f[0] != "ANALYSE"):
+			case len(f) < 2:
+				analyze = true
+			default:
+				analyze = f[1] != "FALSE" && f[1] != "OFF" && f[1] != "0"
+			}
+		}
+
+		return true, analyze, strings.TrimSpace(rest[end+1:])
+	}
+
+	for {
+		i := strings.IndexFunc(rest, unicode.IsSpace)
+		if i < 0 {
+			i = len(rest)
+		}
+
+		switch rest[:i] {
+		case _ANALYZE, "ANALYSE":
+			analyze = true
+		case "VERBOSE":
+		default:
+			return true, analyze, rest
+		}
+
+		rest = strings.TrimSpace(rest[i:])
+	}
+}
+
 // IsDMLCommand only valid if starts with INSERT, UPDATE, DELETE.
 func (SQL) IsDMLCommand(query string) (ok bool) {
 	query = strings.ToUpper(strings.TrimSpace(SQL{}.RemoveComment(query)))
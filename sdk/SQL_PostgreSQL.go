@@ -6,12 +6,12 @@ import (
 	"database/sql/driver"
 	"errors"
 	"fmt"
-	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/lib/pq"
 	"github.com/uptrace/bun/dialect/pgdialect"
-	"github.com/uptrace/bun/driver/pgdriver"
 )
 
 // nolint: gochecknoglobals
@@ -58,26 +58,60 @@ type sqlRoundRobinIndex struct {
 	*sync.Mutex
 }
 
-// OpenWithDSN will open connection from the given dsn string with URL format, note
-// that any error when opening the database should result in a panic.
-func (SQL) OpenWithDSN(ctx context.Context, dsn string) (conn *sql.DB, err error) {
-	driverName := strings.Split(dsn+"://", "://")[0]
-	switch driverName {
-	case "postgres", "postgresql":
-		conn = sql.OpenDB(pgdriver.NewConnector(pgdriver.WithDSN(dsn)))
-		if conn == nil {
-			return nil, ErrInvalidDatabase
-		}
-	default:
-		conn, err = sql.Open(driverName, dsn)
-		if err != nil {
-			return nil, err
-		} else if conn == nil {
-			return nil, ErrInvalidDatabase
-		}
+// sqlRoute is a per call routing hint carried in the context, see SQL.WithPrimary
+// and SQL.WithReplica.
+type sqlRoute int
+
+const (
+	sqlRouteDefault sqlRoute = iota
+	sqlRoutePrimary
+	sqlRouteReplica
+)
+
+type sqlRouteCtxKey struct{}
+
+type sqlStickyCtxKey struct{}
+
+// sqlSticky holds the last write time of a request or session, any read within
+// the window after a write is routed to the READ/WRITE connection.
+type sqlSticky struct {
+	window    time.Duration
+	lastWrite int64
+}
+
+func (s *sqlSticky) write() { atomic.StoreInt64(&s.lastWrite, time.Now().UnixNano()) }
+
+func (s *sqlSticky) active() bool {
+	last := atomic.LoadInt64(&s.lastWrite)
+
+	return last > 0 && time.Since(time.Unix(0, last)) < s.window
+}
+
+// WithPrimary will force the next call using the returned context to be routed
+// into the READ/WRITE connection.
+func (SQL) WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, sqlRouteCtxKey{}, sqlRoutePrimary)
+}
+
+// WithReplica will force the next read using the returned context to be routed
+// into the READ-ONLY connection, writes are always routed into READ/WRITE.
+func (SQL) WithReplica(ctx context.Context) context.Context {
+	return context.WithValue(ctx, sqlRouteCtxKey{}, sqlRouteReplica)
+}
+
+// WithStickyPrimary enable read-your-writes consistency, after a write is made
+// using the returned context (or its children), any read within the window will
+// be routed into the READ/WRITE connection instead of a lagging replica.
+//
+//	ctx = new(sdk.SQL).WithStickyPrimary(r.Context(), 5*time.Second)
+//	_, _ = conn.ExecContext(ctx, "INSERT ...") // primary, mark the write
+//	_, _ = conn.QueryContext(ctx, "SELECT ...") // primary, still in window
+func (SQL) WithStickyPrimary(ctx context.Context, window time.Duration) context.Context {
+	if s, ok := ctx.Value(sqlStickyCtxKey{}).(*sqlSticky); ok && s != nil && s.window == window {
+		return ctx
 	}
 
-	return conn, nil
+	return context.WithValue(ctx, sqlStickyCtxKey{}, &sqlSticky{window, 0})
 }
 
 // NewRoundRobin will reduce multiple connections into one with RoundRobin style.
@@ -93,14 +127,31 @@ func (SQL) NewRoundRobin(ctx context.Context, conns ...SQLConn) SQLConn {
 
 // BeginTx READ+WRITE database.
 func (rr *sqlRoundRobin) BeginTx(ctx context.Context, opts *sql.TxOptions) (tx *sql.Tx, err error) {
-	conn, err := rr.get(0)
+	conn, err := rr.get(ctx, 0)
 	if err != nil {
 		return nil, err
 	}
 
+	if opts == nil || !opts.ReadOnly {
+		rr.written(ctx)
+	}
+
 	return conn.BeginTx(ctx, opts)
 }
 
+// Conn READ+WRITE database, returning a single dedicated connection; useful
+// when a session state is needed, e.g. advisory lock.
+func (rr *sqlRoundRobin) Conn(ctx context.Context) (conn *sql.Conn, err error) {
+	c, ok := rr.conns[0].(interface {
+		Conn(ctx context.Context) (*sql.Conn, error)
+	})
+	if !ok {
+		return nil, fmt.Errorf("database: %w", ErrInvalidDatabase)
+	}
+
+	return c.Conn(ctx)
+}
+
 // Close all databases.
 func (rr *sqlRoundRobin) Close() (err error) {
 	errs := new(ListError)
@@ -133,11 +184,13 @@ func (rr *sqlRoundRobin) PrepareContext(ctx context.Context, query string) (stmt
 	}
 
 	if rr.IsDDLCommand(query) {
-		conn, err = rr.get(0)
+		conn, err = rr.get(ctx, 0)
+		rr.written(ctx)
 	} else if rr.IsDMLCommand(query) {
-		conn, err = rr.get(0)
+		conn, err = rr.get(ctx, 0)
+		rr.written(ctx)
 	} else if rr.IsSELECTCommand(query) {
-		conn, err = rr.get(-2)
+		conn, err = rr.read(ctx)
 	} else {
 		return nil, fmt.Errorf("database: %w: %q", ErrInvalidCommand, query)
 	}
@@ -161,9 +214,11 @@ func (rr *sqlRoundRobin) ExecContext(ctx context.Context, query string, args ...
 	}
 
 	if rr.IsDDLCommand(query) {
-		conn, err = rr.get(0)
+		conn, err = rr.get(ctx, 0)
+		rr.written(ctx)
 	} else if rr.IsDMLCommand(query) {
-		conn, err = rr.get(0)
+		conn, err = rr.get(ctx, 0)
+		rr.written(ctx)
 	} else if rr.IsSELECTCommand(query) {
 		return nil, fmt.Errorf("database: %w: %q", ErrInvalidCommand, query)
 	} else {
@@ -189,7 +244,7 @@ func (rr *sqlRoundRobin) QueryContext(ctx context.Context, query string, args ..
 	}
 
 	if rr.IsSELECTCommand(query) {
-		conn, err = rr.get(-2)
+		conn, err = rr.read(ctx)
 	} else if rr.IsDDLCommand(query) {
 		return nil, fmt.Errorf("database: %w: %q", ErrInvalidCommand, query)
 	} else if rr.IsDMLCommand(query) {
@@ -205,46 +260,122 @@ func (rr *sqlRoundRobin) QueryContext(ctx context.Context, query string, args ..
 	return conn.QueryContext(ctx, query, args...)
 }
 
-// QueryRowContext valid queries are SELECT.
+// QueryRowContext valid queries are SELECT. The returned *sql.Row is never nil,
+// when the query is invalid or unable to be routed the row will carry the error
+// (ErrMultipleCommands, ErrInvalidCommand or *SQLRoundRobinError) on Scan, and
+// the query never reach any database.
 func (rr *sqlRoundRobin) QueryRowContext(ctx context.Context, query string, args ...interface{}) (row *sql.Row) {
 	query = rr.RemoveComment(query)
-	conn := SQLConn(nil)
+	conn, err := SQLConn(nil), error(nil)
 
 	if rr.IsMultipleCommand(query) {
-		return nil
+		return sqlErrRow(fmt.Errorf("database: %w", ErrMultipleCommands))
 	} else if !rr.IsValidCommand(query) {
-		return nil
+		return sqlErrRow(fmt.Errorf("database: %w: %q", ErrInvalidCommand, query))
 	}
 
 	if rr.IsSELECTCommand(query) {
-		conn, _ = rr.get(-2)
+		conn, err = rr.read(ctx)
 	} else if rr.IsDDLCommand(query) {
-		return nil
+		return sqlErrRow(fmt.Errorf("database: %w: %q", ErrInvalidCommand, query))
 	} else if rr.IsDMLCommand(query) {
-		return nil
+		return sqlErrRow(fmt.Errorf("database: %w: %q", ErrInvalidCommand, query))
 	} else {
-		return nil
+		return sqlErrRow(fmt.Errorf("database: %w: %q", ErrInvalidCommand, query))
+	}
+
+	if err != nil {
+		return sqlErrRow(err)
 	}
 
 	return conn.QueryRowContext(ctx, query, args...)
 }
 
+// sqlErrRow will return a *sql.Row that carry err, since *sql.Row is unable to
+// be constructed outside database/sql, the row is queried from sqlErrDB.
+func sqlErrRow(err error) *sql.Row {
+	return sqlErrDB.QueryRowContext(context.Background(), "", sqlErrArg{err})
+}
+
+// sqlErrDB is an in-memory database that fail every query with the error of
+// its sqlErrArg, it never open any connection to a real database.
+//
+// nolint: gochecknoglobals
+var sqlErrDB = sql.OpenDB(sqlErrConnector{})
+
+type sqlErrArg struct{ error }
+
+type sqlErrConnector struct{}
+
+func (sqlErrConnector) Connect(context.Context) (driver.Conn, error) { return sqlErrConn{}, nil }
+
+func (sqlErrConnector) Driver() driver.Driver { return sqlErrConnector{} }
+
+func (sqlErrConnector) Open(string) (driver.Conn, error) { return sqlErrConn{}, nil }
+
+type sqlErrConn struct{}
+
+func (sqlErrConn) Prepare(string) (driver.Stmt, error) { return nil, driver.ErrSkip }
+
+func (sqlErrConn) Close() error { return nil }
+
+func (sqlErrConn) Begin() (driver.Tx, error) { return nil, driver.ErrSkip }
+
+func (sqlErrConn) CheckNamedValue(*driver.NamedValue) error { return nil }
+
+func (sqlErrConn) QueryContext(_ context.Context, _ string, args []driver.NamedValue) (driver.Rows, error) {
+	if len(args) > 0 {
+		if arg, ok := args[0].Value.(sqlErrArg); ok && arg.error != nil {
+			return nil, arg.error
+		}
+	}
+
+	return nil, fmt.Errorf("database: %w", ErrInvalidCommand)
+}
+
+// read will return a Conn for READ-ONLY query, the READ/WRITE is chosen when it
+// is forced via SQL.WithPrimary or when a write is still in the sticky window.
+func (rr *sqlRoundRobin) read(ctx context.Context) (SQLConn, error) {
+	switch route, _ := ctx.Value(sqlRouteCtxKey{}).(sqlRoute); route {
+	case sqlRoutePrimary:
+		return rr.get(ctx, 0)
+	case sqlRouteReplica:
+		return rr.get(ctx, -2)
+	}
+
+	if s, ok := ctx.Value(sqlStickyCtxKey{}).(*sqlSticky); ok && s != nil && s.active() {
+		return rr.get(ctx, 0)
+	}
+
+	return rr.get(ctx, -2)
+}
+
+// written will mark the sticky window, if any, from the given context.
+func (rr *sqlRoundRobin) written(ctx context.Context) {
+	if s, ok := ctx.Value(sqlStickyCtxKey{}).(*sqlSticky); ok && s != nil {
+		s.write()
+	}
+}
+
 // get will return a new Conn that balanced using roundRobin
 //
-//	rr.get(0)    -> direct READ+WRITE
-//	rr.get(1..n) -> direct READ-ONLY
-//	rr.get(-1)   -> roundRobin READ+WRITE and READ-ONLY
-//	rr.get(-2)   -> roundRobin READ-ONLY
-func (rr *sqlRoundRobin) get(i int) (SQLConn, error) {
-	l := len(rr.conns)
+//	rr.get(ctx, 0)    -> direct READ+WRITE
+//	rr.get(ctx, 1..n) -> direct READ-ONLY
+//	rr.get(ctx, -1)   -> roundRobin READ+WRITE and READ-ONLY
+//	rr.get(ctx, -2)   -> roundRobin READ-ONLY
+//
+// The chosen index is reported to the context when it is prepared by
+// SQL.WithTelemetry.
+func (rr *sqlRoundRobin) get(ctx context.Context, i int) (SQLConn, error) {
+	l, idx := len(rr.conns), 0
 
+	rr.index.Lock()
 	switch {
 	case l == 1: // only one
 		rr.index.int = 0
 	case i >= 0 && l > i: // direct
 		rr.index.int = i
 	case (i == -1 || i == -2) && l > 1: // roundRobin
-		rr.index.Lock()
 		if rr.index.int++; rr.index.int >= l {
 			switch i {
 			case -1: // roundRobin READ/WRITE and READ-ONLY
@@ -253,12 +384,32 @@ func (rr *sqlRoundRobin) get(i int) (SQLConn, error) {
 				rr.index.int = 1
 			}
 		}
-		rr.index.Unlock()
 	default:
+		rr.index.Unlock()
+
 		return nil, &SQLRoundRobinError{l, i}
 	}
+	idx = rr.index.int
+	rr.index.Unlock()
+
+	if p, ok := ctx.Value(sqlReplicaCtxKey{}).(*int); ok && p != nil {
+		*p = idx
+	}
+
+	return rr.conns[idx], nil
+}
+
+// stats of each *sql.DB by its index.
+func (rr *sqlRoundRobin) stats() map[int]sql.DBStats {
+	stats := map[int]sql.DBStats{}
+
+	for i := range rr.conns {
+		if db, ok := rr.conns[i].(interface{ Stats() sql.DBStats }); ok {
+			stats[i] = db.Stats()
+		}
+	}
 
-	return rr.conns[rr.index.int], nil
+	return stats
 }
 
 // SQLRoundRobinError reporting issue when getting from set of Conn from SQLRoundRobin.
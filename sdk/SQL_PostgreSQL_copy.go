@@ -0,0 +1,313 @@
+package sdk
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+	"github.com/uptrace/bun/driver/pgdriver"
+)
+
+// nolint: gochecknoglobals
+var (
+	ErrCopyInvalidColumn = errors.New("Invalid copy column")
+
+	sqlColumnIdentifier = regexp.MustCompile(`^[A-Za-z_]\w*$`)
+	sqlCopyLine         = regexp.MustCompile(`line (\d+)`)
+	sqlCopyEscaper      = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+)
+
+// -----------------------------------------------------------------------------
+// COPY
+// -----------------------------------------------------------------------------
+
+// SQLCopySource is an iterator of the rows to be copied.
+type SQLCopySource interface {
+	// Next advance to the next row, false when there is no more row.
+	Next() bool
+	// Values of the current row, by order of the columns.
+	Values() ([]interface{}, error)
+	// Err of the iteration, if any.
+	Err() error
+}
+
+// SQLCopyFromRows is a SQLCopySource of rows.
+func SQLCopyFromRows(rows [][]interface{}) SQLCopySource { return &sqlCopyRows{rows, -1} }
+
+type sqlCopyRows struct {
+	rows [][]interface{}
+	i    int
+}
+
+func (x *sqlCopyRows) Next() bool {
+	x.i++
+
+	return x.i < len(x.rows)
+}
+
+func (x *sqlCopyRows) Values() ([]interface{}, error) { return x.rows[x.i], nil }
+
+func (x *sqlCopyRows) Err() error { return nil }
+
+// SQLCopyFromStructs is a SQLCopySource of a slice of struct (or pointer to
+// struct), the value of each column is taken from the field with the same `db`
+// tag or name, see SQL.Named.
+func SQLCopyFromStructs(slice interface{}, columns ...string) SQLCopySource {
+	rv := reflect.ValueOf(slice)
+	for rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return &sqlCopyStructs{err: fmt.Errorf("database: copy: %w: %T", ErrInvalidValue, slice)}
+	}
+
+	return &sqlCopyStructs{rv: rv, columns: columns, i: -1}
+}
+
+type sqlCopyStructs struct {
+	rv      reflect.Value
+	columns []string
+	i       int
+	err     error
+}
+
+func (x *sqlCopyStructs) Next() bool {
+	if x.err != nil {
+		return false
+	}
+
+	x.i++
+
+	return x.i < x.rv.Len()
+}
+
+func (x *sqlCopyStructs) Values() ([]interface{}, error) {
+	lookup, err := sqlNamedLookup(x.rv.Index(x.i).Interface())
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]interface{}, len(x.columns))
+	for i, col := range x.columns {
+		v, ok := lookup(col)
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrCopyInvalidColumn, col)
+		}
+
+		values[i] = v
+	}
+
+	return values, nil
+}
+
+func (x *sqlCopyStructs) Err() error { return x.err }
+
+// CopyFrom bulk insert the rows of src into table using `COPY FROM STDIN`, db
+// should be opened using pgdriver, e.g. SQL.OpenWithDSN with `postgres://` dsn.
+// It returns the number of rows written, the error is wrapped with the index of
+// the row when it is known.
+//
+//	n, err := new(sdk.SQL).CopyFrom(ctx, db, "core.products", []string{"id", "name"},
+//	  sdk.SQLCopyFromStructs(products, "id", "name"),
+//	)
+func (SQL) CopyFrom(ctx context.Context, db *sql.DB, table string, columns []string, src SQLCopySource) (n int64, err error) {
+	if db == nil {
+		return 0, fmt.Errorf("database: copy: %w", ErrInvalidDatabase)
+	} else if _, ok := db.Driver().(pgdriver.Driver); !ok {
+		return 0, fmt.Errorf("database: copy: %w: %T", ErrInvalidDatabase, db.Driver())
+	} else if !sqlIdentifier.MatchString(table) {
+		return 0, fmt.Errorf("database: copy: %w: %q", ErrInvalidValue, table)
+	}
+
+	for _, col := range columns {
+		if !sqlColumnIdentifier.MatchString(col) {
+			return 0, fmt.Errorf("database: copy: %w: %q", ErrCopyInvalidColumn, col)
+		}
+	}
+
+	conn, err := bun.NewDB(db, pgdialect.New()).Conn(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("database: copy: %w", err)
+	}
+	defer conn.Close()
+
+	r, w := io.Pipe()
+	encoded := make(chan error, 1)
+
+	go func() {
+		err := sqlCopyEncode(w, len(columns), src)
+		_ = w.CloseWithError(err)
+		encoded <- err
+	}()
+
+	query := "COPY " + table + " (" + strings.Join(columns, ", ") + ") FROM STDIN"
+	res, err := pgdriver.CopyFrom(ctx, conn, r, query)
+	_ = r.Close()
+
+	// the pipe is closed by a server error before all rows are encoded
+	if encErr := <-encoded; encErr != nil && !errors.Is(encErr, io.ErrClosedPipe) {
+		return 0, fmt.Errorf("database: copy: %w", encErr)
+	} else if err != nil {
+		var pgErr pgdriver.Error
+		if errors.As(err, &pgErr) {
+			if m := sqlCopyLine.FindStringSubmatch(pgErr.Field('W')); len(m) > 1 {
+				line, _ := strconv.Atoi(m[1])
+
+				return 0, fmt.Errorf("database: copy: row %d: %w", line-1, err)
+			}
+		}
+
+		return 0, fmt.Errorf("database: copy: %w", err)
+	}
+
+	return res.RowsAffected()
+}
+
+// sqlCopyEncode write the rows of src using the text format of COPY.
+func sqlCopyEncode(w io.Writer, columns int, src SQLCopySource) error {
+	b := new(strings.Builder)
+
+	for i := 0; src.Next(); i++ {
+		values, err := src.Values()
+		if err != nil {
+			return fmt.Errorf("row %d: %w", i, err)
+		} else if len(values) != columns {
+			return fmt.Errorf("row %d: %w: [%d] values on [%d] columns", i, ErrInvalidValue, len(values), columns)
+		}
+
+		b.Reset()
+
+		for j, v := range values {
+			if j > 0 {
+				_ = b.WriteByte('\t')
+			}
+
+			s, err := sqlCopyValue(v)
+			if err != nil {
+				return fmt.Errorf("row %d: column %d: %w", i, j, err)
+			}
+
+			_, _ = b.WriteString(s)
+		}
+
+		_ = b.WriteByte('\n')
+
+		if _, err := io.WriteString(w, b.String()); err != nil {
+			return fmt.Errorf("row %d: %w", i, err)
+		}
+	}
+
+	return src.Err()
+}
+
+func sqlCopyValue(v interface{}) (string, error) {
+	s, null, err := sqlTextValue(v)
+	if err != nil {
+		return "", err
+	} else if null {
+		return `\N`, nil
+	}
+
+	return sqlCopyEscaper.Replace(s), nil
+}
+
+// -----------------------------------------------------------------------------
+// Batch
+// -----------------------------------------------------------------------------
+
+// SQLBatch join many parameterized statements into a single multi-statement
+// query, so that they are sent in one round trip (it is not the extended query
+// pipeline); they are executed in one implicit transaction and any error aborts
+// the whole batch.
+//
+//	b := new(sdk.SQLBatch)
+//	for _, p := range products {
+//	  b.Queue("UPDATE core.products SET name = $2 WHERE id = $1", p.ID, p.Name)
+//	}
+//	_, err := b.ExecContext(ctx, db)
+//
+// The arguments are formatted by pgdriver on the client side, so conn should
+// be a pgdriver *sql.DB, *sql.Conn or *sql.Tx; SQL.NewRoundRobin refuse
+// multiple commands, use its Conn instead.
+type SQLBatch struct {
+	queries []string
+	args    []interface{}
+}
+
+// Queue a statement with its positional arguments.
+func (b *SQLBatch) Queue(query string, args ...interface{}) *SQLBatch {
+	b.queries = append(b.queries, sqlRenumber(SQL{}.RemoveComment(query), len(b.args)))
+	b.args = append(b.args, args...)
+
+	return b
+}
+
+// Len is the number of queued statements.
+func (b *SQLBatch) Len() int { return len(b.queries) }
+
+// Query return the combined query and arguments.
+func (b *SQLBatch) Query() (query string, args []interface{}) {
+	return strings.Join(b.queries, ";\n"), b.args
+}
+
+// ExecContext send the queued statements, the result is of the last statement.
+func (b *SQLBatch) ExecContext(ctx context.Context, conn ExecContext) (sql.Result, error) {
+	if len(b.queries) < 1 {
+		return nil, fmt.Errorf("database: batch: %w", ErrNoResult)
+	}
+
+	query, args := b.Query()
+
+	res, err := conn.ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("database: batch: [%d] statements: %w", len(b.queries), err)
+	}
+
+	return res, nil
+}
+
+// sqlRenumber shift the `$N` placeholders of query by offset, quoted literals
+// and identifiers are kept as is.
+func sqlRenumber(query string, offset int) string {
+	query = strings.TrimRight(strings.TrimSpace(query), ";")
+	if offset < 1 {
+		return query
+	}
+
+	b := new(strings.Builder)
+
+	for i := 0; i < len(query); i++ {
+		switch c := query[i]; {
+		case c == '\'' || c == '"':
+			j := strings.IndexByte(query[i+1:], c)
+			if j < 0 {
+				j = len(query) - i - 2
+			}
+
+			_, _ = b.WriteString(query[i : i+j+2])
+			i += j + 1
+		case c == '$' && i+1 < len(query) && query[i+1] >= '0' && query[i+1] <= '9':
+			j := i + 1
+			for j < len(query) && query[j] >= '0' && query[j] <= '9' {
+				j++
+			}
+
+			n, _ := strconv.Atoi(query[i+1 : j])
+			_, _ = b.WriteString("$" + strconv.Itoa(n+offset))
+			i = j - 1
+		default:
+			_ = b.WriteByte(c)
+		}
+	}
+
+	return b.String()
+}
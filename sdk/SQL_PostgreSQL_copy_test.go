@@ -0,0 +1,120 @@
+package sdk_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/gunawanwijaya/forest/sdk"
+	. "github.com/onsi/gomega"
+	"github.com/uptrace/bun/driver/pgdriver"
+)
+
+func test_SQLPostgreSQLCopy(t *testing.T) {
+	t.Parallel()
+
+	Expect := NewWithT(t).Expect
+	ctx := context.Background()
+
+	t.Run("copy-source", func(t *testing.T) {
+		type product struct {
+			ID   []byte `db:"id"`
+			Name string
+		}
+
+		src := SQLCopyFromStructs([]product{{[]byte{1}, "A"}, {[]byte{2}, "B"}}, "id", "name")
+		rows := [][]interface{}{}
+		for src.Next() {
+			values, err := src.Values()
+			Expect(err).To(Succeed())
+
+			rows = append(rows, values)
+		}
+		Expect(src.Err()).To(Succeed())
+		Expect(rows).To(Equal([][]interface{}{{[]byte{1}, "A"}, {[]byte{2}, "B"}}))
+
+		src = SQLCopyFromStructs([]product{{}}, "price")
+		Expect(src.Next()).To(BeTrue())
+		_, err := src.Values()
+		Expect(err).To(MatchError(ErrCopyInvalidColumn))
+
+		Expect(SQLCopyFromStructs(1).Err()).To(MatchError(ErrInvalidValue))
+	})
+	t.Run("copy-invalid", func(t *testing.T) {
+		mock := new(SQL).NewMock(ctx, 0)
+		defer mock.Close()
+
+		_, err := new(SQL).CopyFrom(ctx, mock.Primary(), "core.products", []string{"id"}, SQLCopyFromRows(nil))
+		Expect(err).To(MatchError(ErrInvalidDatabase))
+	})
+	t.Run("copy", func(t *testing.T) {
+		copied := make(chan string, 1)
+		srv := newPGServer(t, func(_ int, c *pgServerConn) {
+			if c.query() != "COPY core.products (id, name) FROM STDIN" {
+				return
+			}
+
+			if data, ok := c.copyIn(2); ok {
+				copied <- data
+				c.complete("COPY 2")
+				c.idle()
+			}
+		})
+
+		n, err := new(SQL).CopyFrom(ctx, srv.db, "core.products", []string{"id", "name"},
+			SQLCopyFromRows([][]interface{}{{1, "A\tB"}, {2, nil}}))
+		Expect(err).To(Succeed())
+		Expect(n).To(Equal(int64(2)))
+		Expect(<-copied).To(Equal("1\tA\\tB\n2\t\\N\n"))
+	})
+	t.Run("copy-failed", func(t *testing.T) {
+		// the line of the error is the 1-based line of the data, mapped into the
+		// 0-based index of the row
+		srv := newPGServer(t, func(_ int, c *pgServerConn) {
+			if c.query() == "" {
+				return
+			}
+
+			if _, ok := c.copyIn(1); ok {
+				c.fail(map[byte]string{
+					'C': "22P02",
+					'M': `invalid input syntax for type bigint: "x"`,
+					'W': `COPY products, line 2, column id: "x"`,
+				})
+				c.idle()
+			}
+		})
+
+		n, err := new(SQL).CopyFrom(ctx, srv.db, "core.products", []string{"id"},
+			SQLCopyFromRows([][]interface{}{{1}, {"x"}, {3}}))
+		Expect(err).To(MatchError(ContainSubstring("database: copy: row 1: ")))
+		Expect(err).To(MatchError(ContainSubstring(`invalid input syntax for type bigint: "x"`)))
+		Expect(n).To(Equal(int64(0)))
+
+		var pgErr pgdriver.Error
+		Expect(errors.As(err, &pgErr)).To(BeTrue())
+		Expect(pgErr.Field('C')).To(Equal("22P02"))
+	})
+	t.Run("batch", func(t *testing.T) {
+		mock := new(SQL).NewMock(ctx, 0)
+		defer mock.Close()
+
+		b := new(SQLBatch).
+			Queue("UPDATE core.products SET name = $2 WHERE id = $1;", []byte{1}, "A").
+			Queue("-- rename\nUPDATE core.products SET name = '$1' || $2 WHERE id = $1", []byte{2}, "B")
+		Expect(b.Len()).To(Equal(2))
+
+		query, args := b.Query()
+		Expect(query).To(Equal("UPDATE core.products SET name = $2 WHERE id = $1;\n" +
+			"UPDATE core.products SET name = '$1' || $4 WHERE id = $3"))
+		Expect(args).To(Equal([]interface{}{[]byte{1}, "A", []byte{2}, "B"}))
+
+		mock.ExpectExec(`UPDATE .*;\nUPDATE`).WithArgs([]byte{1}, "A", []byte{2}, "B").WillReturnResult(0, 1)
+		_, err := b.ExecContext(ctx, mock)
+		Expect(err).To(Succeed())
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+
+		_, err = new(SQLBatch).ExecContext(ctx, mock)
+		Expect(err).To(MatchError(ErrNoResult))
+	})
+}
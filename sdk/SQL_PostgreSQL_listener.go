@@ -0,0 +1,289 @@
+package sdk
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+	"github.com/uptrace/bun/driver/pgdriver"
+)
+
+// nolint: gochecknoglobals
+var (
+	ErrListenerOverflow = errors.New("Listener overflow")
+)
+
+// PostgreSQLNotification is a payload of `NOTIFY channel, payload`.
+type PostgreSQLNotification struct {
+	Channel string
+	Payload string
+}
+
+// Decode the payload using JSON.
+func (n PostgreSQLNotification) Decode(v interface{}) error {
+	if err := JSON.Unmarshal([]byte(n.Payload), v); err != nil {
+		return fmt.Errorf("database: listener: %s: %w", n.Channel, err)
+	}
+
+	return nil
+}
+
+type PostgreSQLListenerConfiguration struct {
+	// MinBackoff & MaxBackoff of the reconnection, default to 100ms & 30s.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// Buffer of each channel returned by Subscribe, default to 64. The
+	// notification is dropped when the buffer is full.
+	Buffer int
+
+	// PollTimeout to check ctx while waiting for a notification, default to 1s.
+	PollTimeout time.Duration
+
+	// OnError is called on any error of the listener, optional.
+	OnError func(err error)
+
+	// OnReconnect is called after the connection is re-established and the
+	// channels are re-subscribed, notifications in between might be lost.
+	OnReconnect func()
+}
+
+// PostgreSQLListener subscribe to the channels using `LISTEN` on a dedicated
+// connection and deliver the notifications on go channels or callbacks.
+//
+//	ln, err := new(sdk.SQL).NewPostgreSQLListener(ctx, db, nil)
+//	ln.Handle(func(ctx context.Context, n sdk.PostgreSQLNotification) error {
+//	  var product struct{ ID []byte }
+//	  if err := n.Decode(&product); err != nil {
+//	    return err
+//	  }
+//	  cache.Delete(product.ID)
+//	  return nil
+//	}, "core_products")
+//	go ln.Run(ctx)
+type PostgreSQLListener struct {
+	cfg PostgreSQLListenerConfiguration
+	ln  *pgdriver.Listener
+
+	mu       *sync.RWMutex
+	subs     map[string][]chan PostgreSQLNotification
+	handlers map[string][]func(ctx context.Context, n PostgreSQLNotification) error
+}
+
+// NewPostgreSQLListener create a listener from db, db should be opened using
+// pgdriver, e.g. SQL.OpenWithDSN with `postgres://` dsn.
+func (SQL) NewPostgreSQLListener(ctx context.Context, db *sql.DB, c *PostgreSQLListenerConfiguration) (*PostgreSQLListener, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database: listener: %w", ErrInvalidDatabase)
+	} else if _, ok := db.Driver().(pgdriver.Driver); !ok {
+		return nil, fmt.Errorf("database: listener: %w: %T", ErrInvalidDatabase, db.Driver())
+	}
+
+	cfg := PostgreSQLListenerConfiguration{
+		MinBackoff:  100 * time.Millisecond,
+		MaxBackoff:  30 * time.Second,
+		Buffer:      64,
+		PollTimeout: time.Second,
+	}
+	if c != nil {
+		if c.MinBackoff > 0 {
+			cfg.MinBackoff = c.MinBackoff
+		}
+
+		if c.MaxBackoff > 0 {
+			cfg.MaxBackoff = c.MaxBackoff
+		}
+
+		if c.Buffer > 0 {
+			cfg.Buffer = c.Buffer
+		}
+
+		if c.PollTimeout > 0 {
+			cfg.PollTimeout = c.PollTimeout
+		}
+
+		cfg.OnError, cfg.OnReconnect = c.OnError, c.OnReconnect
+	}
+
+	return &PostgreSQLListener{
+		cfg:      cfg,
+		ln:       pgdriver.NewListener(bun.NewDB(db, pgdialect.New())),
+		mu:       new(sync.RWMutex),
+		subs:     map[string][]chan PostgreSQLNotification{},
+		handlers: map[string][]func(ctx context.Context, n PostgreSQLNotification) error{},
+	}, nil
+}
+
+// Listen on the channels, the channels are re-subscribed on reconnection even
+// when an error is returned.
+func (x *PostgreSQLListener) Listen(ctx context.Context, channels ...string) error {
+	if err := x.ln.Listen(ctx, channels...); err != nil {
+		return fmt.Errorf("database: listener: %w", err)
+	}
+
+	return nil
+}
+
+// Unlisten the channels.
+func (x *PostgreSQLListener) Unlisten(ctx context.Context, channels ...string) error {
+	if err := x.ln.Unlisten(ctx, channels...); err != nil {
+		return fmt.Errorf("database: listener: %w", err)
+	}
+
+	return nil
+}
+
+// Subscribe return a go channel receiving the notifications of channel, it is
+// closed when Run returns. Call Listen to start listening on channel.
+func (x *PostgreSQLListener) Subscribe(channel string) <-chan PostgreSQLNotification {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	ch := make(chan PostgreSQLNotification, x.cfg.Buffer)
+	x.subs[channel] = append(x.subs[channel], ch)
+
+	return ch
+}
+
+// Handle register fn to be called on the notifications of the channels, fn is
+// called sequentially by Run. Call Listen to start listening on the channels.
+func (x *PostgreSQLListener) Handle(fn func(ctx context.Context, n PostgreSQLNotification) error, channels ...string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	for _, ch := range channels {
+		x.handlers[ch] = append(x.handlers[ch], fn)
+	}
+}
+
+// Run receive & deliver the notifications until ctx is done, then close the
+// listener and the subscribed go channels. A broken connection is re-established
+// with exponential backoff.
+func (x *PostgreSQLListener) Run(ctx context.Context) error {
+	defer x.close()
+
+	backoff, broken := time.Duration(0), false
+
+	for ctx.Err() == nil {
+		channel, payload, err := x.ln.ReceiveTimeout(ctx, x.cfg.PollTimeout)
+
+		var netErr net.Error
+		switch {
+		case err == nil:
+		case ctx.Err() != nil:
+			return nil
+		case errors.As(err, &netErr) && netErr.Timeout():
+			// idle, the connection is still alive
+		default:
+			x.error(fmt.Errorf("database: listener: %w", err))
+
+			backoff, broken = x.next(backoff), true
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(backoff):
+			}
+
+			continue
+		}
+
+		if broken && x.cfg.OnReconnect != nil {
+			x.cfg.OnReconnect()
+		}
+
+		backoff, broken = 0, false
+
+		if err == nil {
+			x.deliver(ctx, PostgreSQLNotification{channel, payload})
+		}
+	}
+
+	return nil
+}
+
+func (x *PostgreSQLListener) next(backoff time.Duration) time.Duration {
+	if backoff *= 2; backoff < x.cfg.MinBackoff {
+		return x.cfg.MinBackoff
+	} else if backoff > x.cfg.MaxBackoff {
+		return x.cfg.MaxBackoff
+	}
+
+	return backoff
+}
+
+func (x *PostgreSQLListener) deliver(ctx context.Context, n PostgreSQLNotification) {
+	x.mu.RLock()
+	subs, handlers := x.subs[n.Channel], x.handlers[n.Channel]
+	x.mu.RUnlock()
+
+	for _, ch := range subs {
+		select {
+		case ch <- n:
+		default:
+			x.error(fmt.Errorf("database: listener: %w: %s", ErrListenerOverflow, n.Channel))
+		}
+	}
+
+	for _, fn := range handlers {
+		if err := fn(ctx, n); err != nil {
+			x.error(fmt.Errorf("database: listener: %s: %w", n.Channel, err))
+		}
+	}
+}
+
+func (x *PostgreSQLListener) error(err error) {
+	if x.cfg.OnError != nil {
+		x.cfg.OnError(err)
+	}
+}
+
+func (x *PostgreSQLListener) close() {
+	_ = x.ln.Close()
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	for channel, subs := range x.subs {
+		for _, ch := range subs {
+			close(ch)
+		}
+
+		delete(x.subs, channel)
+	}
+}
+
+// Notify send `NOTIFY channel, payload`, a payload other than string or []byte
+// is marshaled using JSON. The `SELECT pg_notify(...)` is a SELECT that has to
+// reach the primary, it is queried using SQL.WithPrimary so that a round-robin
+// conn does not route it into a replica (where NOTIFY is not allowed).
+func (SQL) Notify(ctx context.Context, conn QueryRowContext, channel string, payload interface{}) error {
+	var p string
+	switch v := payload.(type) {
+	case string:
+		p = v
+	case []byte:
+		p = string(v)
+	default:
+		b, err := JSON.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("database: notify: %w", err)
+		}
+
+		p = string(b)
+	}
+
+	row := conn.QueryRowContext(SQL{}.WithPrimary(ctx), "SELECT pg_notify($1, $2)", channel, p)
+	if row == nil {
+		return fmt.Errorf("database: notify: %w", ErrNoResult)
+	} else if err := row.Scan(SQLNoScan()); err != nil {
+		return fmt.Errorf("database: notify: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,325 @@
+package sdk_test
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/gunawanwijaya/forest/sdk"
+	. "github.com/onsi/gomega"
+	"github.com/uptrace/bun/driver/pgdriver"
+)
+
+func test_SQLPostgreSQLListener(t *testing.T) {
+	t.Parallel()
+
+	g := NewWithT(t)
+	Expect := g.Expect
+	ctx := context.Background()
+
+	t.Run("decode", func(t *testing.T) {
+		var v struct{ ID int }
+		Expect(PostgreSQLNotification{"core_products", `{"ID":1}`}.Decode(&v)).To(Succeed())
+		Expect(v.ID).To(Equal(1))
+		Expect(PostgreSQLNotification{"core_products", `x`}.Decode(&v)).NotTo(Succeed())
+	})
+	t.Run("invalid-driver", func(t *testing.T) {
+		mock := new(SQL).NewMock(ctx, 0)
+		defer mock.Close()
+
+		_, err := new(SQL).NewPostgreSQLListener(ctx, mock.Primary(), nil)
+		Expect(err).To(MatchError(ErrInvalidDatabase))
+	})
+	t.Run("notify", func(t *testing.T) {
+		mock := new(SQL).NewMock(ctx, 1)
+		defer mock.Close()
+
+		// pg_notify is a SELECT, it must be routed into the primary
+		mock.ExpectQuery(`SELECT pg_notify\(\$1, \$2\)`).WithArgs("core_products", `{"ID":1}`).OnPrimary().
+			WillReturnRows([]string{"pg_notify"}, []interface{}{nil})
+		Expect(new(SQL).Notify(ctx, mock, "core_products", struct{ ID int }{1})).To(Succeed())
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+	})
+	t.Run("run", func(t *testing.T) {
+		srv := newPGServer(t, func(_ int, c *pgServerConn) {
+			if c.query() != `LISTEN "core_products"` {
+				return
+			}
+
+			c.notify("core_products", `{"ID":1}`)
+			c.idle()
+		})
+
+		ln, err := new(SQL).NewPostgreSQLListener(ctx, srv.db, &PostgreSQLListenerConfiguration{PollTimeout: 20 * time.Millisecond})
+		Expect(err).To(Succeed())
+
+		ch, handled := ln.Subscribe("core_products"), make(chan PostgreSQLNotification, 1)
+		ln.Handle(func(_ context.Context, n PostgreSQLNotification) error {
+			handled <- n
+			return nil
+		}, "core_products")
+		Expect(ln.Listen(ctx, "core_products")).To(Succeed())
+
+		ctx, cancel := context.WithCancel(ctx)
+		done := make(chan error, 1)
+
+		go func() { done <- ln.Run(ctx) }()
+
+		n := PostgreSQLNotification{"core_products", `{"ID":1}`}
+		g.Eventually(ch, time.Second).Should(Receive(Equal(n)))
+		g.Eventually(handled, time.Second).Should(Receive(Equal(n)))
+
+		// the subscribed channels are closed once Run returns
+		cancel()
+		g.Eventually(done, time.Second).Should(Receive(BeNil()))
+		g.Eventually(ch).Should(BeClosed())
+	})
+	t.Run("reconnect", func(t *testing.T) {
+		// the first connection is broken right after LISTEN, the channels are
+		// re-subscribed on the next connection
+		srv := newPGServer(t, func(i int, c *pgServerConn) {
+			if c.query() != `LISTEN "core_products"` || i == 0 {
+				return
+			}
+
+			c.notify("core_products", "after")
+			c.idle()
+		})
+
+		var (
+			mu         sync.Mutex
+			errs       []error
+			reconnects int
+		)
+
+		ln, err := new(SQL).NewPostgreSQLListener(ctx, srv.db, &PostgreSQLListenerConfiguration{
+			MinBackoff:  10 * time.Millisecond,
+			PollTimeout: 20 * time.Millisecond,
+			OnError:     func(err error) { mu.Lock(); errs = append(errs, err); mu.Unlock() },
+			OnReconnect: func() { mu.Lock(); reconnects++; mu.Unlock() },
+		})
+		Expect(err).To(Succeed())
+
+		ch := ln.Subscribe("core_products")
+		Expect(ln.Listen(ctx, "core_products")).To(Succeed())
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		go func() { _ = ln.Run(ctx) }()
+
+		g.Eventually(ch, time.Second).Should(Receive(Equal(PostgreSQLNotification{"core_products", "after"})))
+
+		mu.Lock()
+		defer mu.Unlock()
+		Expect(errs).NotTo(BeEmpty())
+		Expect(errs[0].Error()).To(HavePrefix("database: listener: "))
+		Expect(reconnects).To(Equal(1))
+		Expect(srv.accepted()).To(Equal(2))
+	})
+	t.Run("backoff", func(t *testing.T) {
+		// every connection is refused, the retry is delayed by an exponential
+		// backoff from MinBackoff capped at MaxBackoff
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).To(Succeed())
+		Expect(l.Close()).To(Succeed())
+
+		db := sql.OpenDB(pgdriver.NewConnector(pgdriver.WithDSN("postgres://u@" + l.Addr().String() + "/db?sslmode=disable")))
+		defer db.Close()
+
+		var (
+			mu  sync.Mutex
+			ats []time.Time
+		)
+
+		ln, err := new(SQL).NewPostgreSQLListener(ctx, db, &PostgreSQLListenerConfiguration{
+			MinBackoff: 10 * time.Millisecond,
+			MaxBackoff: 40 * time.Millisecond,
+			OnError:    func(error) { mu.Lock(); ats = append(ats, time.Now()); mu.Unlock() },
+		})
+		Expect(err).To(Succeed())
+
+		ctx, cancel := context.WithCancel(ctx)
+		done := make(chan error, 1)
+
+		go func() { done <- ln.Run(ctx) }()
+
+		g.Eventually(func() int { mu.Lock(); defer mu.Unlock(); return len(ats) }, 2*time.Second).Should(BeNumerically(">=", 6))
+		cancel()
+		g.Eventually(done, time.Second).Should(Receive(BeNil()))
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		for i, backoff := range []time.Duration{10, 20, 40, 40, 40} {
+			Expect(ats[i+1].Sub(ats[i])).To(BeNumerically(">=", backoff*time.Millisecond), "retry %d", i+1)
+		}
+
+		// without the cap the last retry would be delayed by 160ms
+		Expect(ats[5].Sub(ats[4])).To(BeNumerically("<", 120*time.Millisecond))
+	})
+}
+
+// pgServer is a minimal PostgreSQL server speaking the wire protocol, every
+// connection is accepted without authentication and handed to serve.
+type pgServer struct {
+	db *sql.DB
+
+	mu sync.Mutex
+	n  int
+}
+
+func newPGServer(t *testing.T, serve func(i int, c *pgServerConn)) *pgServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := &pgServer{db: sql.OpenDB(pgdriver.NewConnector(pgdriver.WithDSN("postgres://u@" + l.Addr().String() + "/db?sslmode=disable")))}
+	t.Cleanup(func() { _ = srv.db.Close(); _ = l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			srv.mu.Lock()
+			i := srv.n
+			srv.n++
+			srv.mu.Unlock()
+
+			go func() {
+				defer conn.Close()
+
+				c := &pgServerConn{conn, bufio.NewReader(conn)}
+				if c.startup() {
+					serve(i, c)
+				}
+			}()
+		}
+	}()
+
+	return srv
+}
+
+func (srv *pgServer) accepted() int {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	return srv.n
+}
+
+type pgServerConn struct {
+	net.Conn
+	rd *bufio.Reader
+}
+
+// startup read the startup message and reply AuthenticationOk & ReadyForQuery.
+func (c *pgServerConn) startup() bool {
+	var n int32
+	if binary.Read(c.rd, binary.BigEndian, &n) != nil {
+		return false
+	} else if _, err := io.CopyN(io.Discard, c.rd, int64(n-4)); err != nil {
+		return false
+	}
+
+	c.write('R', []byte{0, 0, 0, 0})
+	c.write('Z', []byte{'I'})
+
+	return true
+}
+
+// query return the next simple query sent by the client.
+func (c *pgServerConn) query() string {
+	for {
+		typ, err := c.rd.ReadByte()
+		if err != nil {
+			return ""
+		}
+
+		var n int32
+		if binary.Read(c.rd, binary.BigEndian, &n) != nil {
+			return ""
+		}
+
+		p := make([]byte, n-4)
+		if _, err = io.ReadFull(c.rd, p); err != nil {
+			return ""
+		} else if typ == 'Q' {
+			return strings.TrimSuffix(string(p), "\x00")
+		}
+	}
+}
+
+// idle read & discard until the client close the connection.
+func (c *pgServerConn) idle() { _, _ = io.Copy(io.Discard, c.rd) }
+
+// copyIn reply CopyInResponse of n text columns and return the CopyData sent by
+// the client until CopyDone.
+func (c *pgServerConn) copyIn(n int) (string, bool) {
+	c.write('G', append([]byte{0, byte(n >> 8), byte(n)}, make([]byte, 2*n)...))
+
+	data := new(strings.Builder)
+	for {
+		typ, err := c.rd.ReadByte()
+		if err != nil {
+			return "", false
+		}
+
+		var size int32
+		if binary.Read(c.rd, binary.BigEndian, &size) != nil {
+			return "", false
+		}
+
+		p := make([]byte, size-4)
+		if _, err = io.ReadFull(c.rd, p); err != nil {
+			return "", false
+		}
+
+		switch typ {
+		case 'd':
+			_, _ = data.Write(p)
+		case 'c':
+			return data.String(), true
+		default:
+			return "", false
+		}
+	}
+}
+
+// complete send CommandComplete of tag & ReadyForQuery.
+func (c *pgServerConn) complete(tag string) {
+	c.write('C', []byte(tag+"\x00"))
+	c.write('Z', []byte{'I'})
+}
+
+// fail send an ErrorResponse of fields (e.g. 'M' the message) & ReadyForQuery.
+func (c *pgServerConn) fail(fields map[byte]string) {
+	body := []byte{'S', 'E', 'R', 'R', 'O', 'R', 0}
+	for typ, v := range fields {
+		body = append(append(append(body, typ), v...), 0)
+	}
+
+	c.write('E', append(body, 0))
+	c.write('Z', []byte{'I'})
+}
+
+// notify send a NotificationResponse.
+func (c *pgServerConn) notify(channel, payload string) {
+	c.write('A', append(append([]byte{0, 0, 0, 1}, channel+"\x00"...), payload+"\x00"...))
+}
+
+func (c *pgServerConn) write(typ byte, body []byte) {
+	p := append([]byte{typ, 0, 0, 0, 0}, body...)
+	binary.BigEndian.PutUint32(p[1:], uint32(len(body)+4))
+	_, _ = c.Write(p)
+}
@@ -0,0 +1,122 @@
+package sdk_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/gunawanwijaya/forest/sdk"
+	. "github.com/onsi/gomega"
+)
+
+func test_SQLPostgreSQL(t *testing.T) {
+	t.Parallel()
+
+	Expect := NewWithT(t).Expect
+	ctx := context.Background()
+
+	t.Run("round-robin", func(t *testing.T) {
+		primary, replica := new(countConn), new(countConn)
+		rr := new(SQL).NewRoundRobin(ctx, primary, replica)
+
+		_, _ = rr.QueryContext(ctx, "SELECT 1")
+		Expect(primary.n).To(Equal(0))
+		Expect(replica.n).To(Equal(1))
+
+		_, _ = rr.ExecContext(ctx, "INSERT INTO t VALUES (1)")
+		Expect(primary.n).To(Equal(1))
+		Expect(replica.n).To(Equal(1))
+
+		_, _ = rr.QueryContext(new(SQL).WithPrimary(ctx), "SELECT 1")
+		Expect(primary.n).To(Equal(2))
+		Expect(replica.n).To(Equal(1))
+
+		_, _ = rr.ExecContext(new(SQL).WithReplica(ctx), "DELETE FROM t")
+		Expect(primary.n).To(Equal(3))
+		Expect(replica.n).To(Equal(1))
+	})
+	t.Run("sticky-primary", func(t *testing.T) {
+		primary, replica := new(countConn), new(countConn)
+		rr := new(SQL).NewRoundRobin(ctx, primary, replica)
+		ctx := new(SQL).WithStickyPrimary(ctx, 50*time.Millisecond)
+
+		_ = rr.QueryRowContext(ctx, "SELECT 1")
+		Expect(replica.n).To(Equal(1))
+
+		_, _ = rr.ExecContext(ctx, "UPDATE t SET a = 1")
+		_ = rr.QueryRowContext(ctx, "SELECT 1")
+		Expect(primary.n).To(Equal(2))
+		Expect(replica.n).To(Equal(1))
+
+		_, _ = rr.QueryContext(new(SQL).WithReplica(ctx), "SELECT 1")
+		Expect(replica.n).To(Equal(2))
+
+		time.Sleep(60 * time.Millisecond)
+		_, _ = rr.QueryContext(ctx, "SELECT 1")
+		Expect(primary.n).To(Equal(2))
+		Expect(replica.n).To(Equal(3))
+	})
+	t.Run("query-row-error", func(t *testing.T) {
+		primary, replica := new(countConn), new(countConn)
+		rr := new(SQL).NewRoundRobin(ctx, primary, replica)
+
+		for query, expect := range map[string]error{
+			"SELECT 1; SELECT 2":       ErrMultipleCommands,
+			"-- only a comment":        ErrInvalidCommand,
+			"VACUUM t":                 ErrInvalidCommand,
+			"INSERT INTO t VALUES (1)": ErrInvalidCommand,
+			"CREATE TABLE t (a INT)":   ErrInvalidCommand,
+		} {
+			row := rr.QueryRowContext(ctx, query)
+			Expect(row).NotTo(BeNil())
+
+			err := row.Scan(new(int))
+			Expect(errors.Is(err, expect)).To(BeTrue(), query)
+			Expect(errors.Is(err, context.Canceled)).To(BeFalse(), query)
+			Expect(row.Err()).To(MatchError(expect), query)
+		}
+
+		// the rejected queries never reach any database
+		Expect(primary.n).To(Equal(0))
+		Expect(replica.n).To(Equal(0))
+	})
+}
+
+// countConn is a SQLConn that only count the number of calls.
+type countConn struct{ n int }
+
+func (c *countConn) BeginTx(context.Context, *sql.TxOptions) (*sql.Tx, error) {
+	c.n++
+
+	return nil, nil
+}
+
+func (c *countConn) Close() error { return nil }
+
+func (c *countConn) PingContext(context.Context) error { return nil }
+
+func (c *countConn) ExecContext(context.Context, string, ...interface{}) (sql.Result, error) {
+	c.n++
+
+	return nil, nil
+}
+
+func (c *countConn) PrepareContext(context.Context, string) (*sql.Stmt, error) {
+	c.n++
+
+	return nil, nil
+}
+
+func (c *countConn) QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error) {
+	c.n++
+
+	return nil, nil
+}
+
+func (c *countConn) QueryRowContext(context.Context, string, ...interface{}) *sql.Row {
+	c.n++
+
+	return nil
+}
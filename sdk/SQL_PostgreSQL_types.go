@@ -0,0 +1,854 @@
+package sdk
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"net/netip"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// nolint: gochecknoglobals
+var (
+	sqlTimeLayouts = []string{
+		"2006-01-02 15:04:05.999999999Z07:00:00",
+		"2006-01-02 15:04:05.999999999Z07:00",
+		"2006-01-02 15:04:05.999999999Z07",
+		"2006-01-02 15:04:05.999999999",
+		time.RFC3339Nano,
+		"2006-01-02",
+	}
+	sqlIntervalUnits = map[string]time.Duration{
+		"year": 365 * 24 * time.Hour, "mon": 30 * 24 * time.Hour, "month": 30 * 24 * time.Hour,
+		"day": 24 * time.Hour, "hour": time.Hour, "min": time.Minute, "minute": time.Minute,
+		"sec": time.Second, "second": time.Second, "msec": time.Millisecond, "millisecond": time.Millisecond,
+		"usec": time.Microsecond, "microsecond": time.Microsecond,
+	}
+)
+
+type sqlValuerScanner struct {
+	value func() (driver.Value, error)
+	scan  func(src interface{}) error
+}
+
+func (x sqlValuerScanner) Value() (driver.Value, error) { return x.value() }
+
+func (x sqlValuerScanner) Scan(src interface{}) error { return x.scan(src) }
+
+// sqlSource return the text of src as received from the driver.
+func sqlSource(src interface{}) (s string, null bool, err error) {
+	switch x := src.(type) {
+	case nil:
+		return "", true, nil
+	case []byte:
+		return string(x), false, nil
+	case string:
+		return x, false, nil
+	case time.Time:
+		return x.Format(time.RFC3339Nano), false, nil
+	}
+
+	return "", false, fmt.Errorf("database: scan: %w: %T", ErrInvalidValue, src)
+}
+
+// -----------------------------------------------------------------------------
+// JSON
+// -----------------------------------------------------------------------------
+
+// PostgreSQLJSON bridge a json or jsonb column into v using JSON, v should be a
+// pointer when it is used as a Scanner. A NULL is scanned as the zero value.
+//
+//	err := row.Scan(sdk.PostgreSQLJSON(&settings))
+//	_, err = conn.ExecContext(ctx, "UPDATE t SET settings = $1", sdk.PostgreSQLJSON(settings))
+func PostgreSQLJSON(v interface{}) interface {
+	driver.Valuer
+	sql.Scanner
+} {
+	return sqlValuerScanner{
+		func() (driver.Value, error) {
+			if rv := reflect.ValueOf(v); !rv.IsValid() || (rv.Kind() == reflect.Pointer && rv.IsNil()) {
+				return nil, nil
+			}
+
+			p, err := JSON.Marshal(v)
+
+			return string(p), err
+		},
+		func(src interface{}) error {
+			s, null, err := sqlSource(src)
+			if err != nil {
+				return err
+			} else if null {
+				return sqlAssignZero(v)
+			}
+
+			return JSON.Unmarshal([]byte(s), v)
+		},
+	}
+}
+
+// -----------------------------------------------------------------------------
+// hstore
+// -----------------------------------------------------------------------------
+
+// PostgreSQLHstore bridge a hstore column into map[string]*string, m should be
+// a pointer when it is used as a Scanner, a nil value is a NULL.
+func PostgreSQLHstore(m interface{}) interface {
+	driver.Valuer
+	sql.Scanner
+} {
+	return sqlValuerScanner{
+		func() (driver.Value, error) {
+			var h map[string]*string
+			switch x := m.(type) {
+			case map[string]*string:
+				h = x
+			case *map[string]*string:
+				if x != nil {
+					h = *x
+				}
+			default:
+				return nil, fmt.Errorf("database: hstore: %w: %T", ErrInvalidValue, m)
+			}
+
+			if h == nil {
+				return nil, nil
+			}
+
+			pairs := make([]string, 0, len(h))
+			for k, v := range h {
+				val := "NULL"
+				if v != nil {
+					val = sqlQuote(*v, `\`)
+				}
+
+				pairs = append(pairs, sqlQuote(k, `\`)+"=>"+val)
+			}
+
+			return strings.Join(pairs, ", "), nil
+		},
+		func(src interface{}) error {
+			dest, ok := m.(*map[string]*string)
+			if !ok || dest == nil {
+				return fmt.Errorf("database: hstore: %w: %T", ErrInvalidValue, m)
+			}
+
+			s, null, err := sqlSource(src)
+			if err != nil {
+				return err
+			} else if null {
+				*dest = nil
+
+				return nil
+			}
+
+			h, err := sqlParseHstore(s)
+			if err != nil {
+				return fmt.Errorf("database: hstore: %w", err)
+			}
+
+			*dest = h
+
+			return nil
+		},
+	}
+}
+
+func sqlParseHstore(s string) (map[string]*string, error) {
+	h, i := map[string]*string{}, 0
+	skip := func() {
+		for i < len(s) && (s[i] == ' ' || s[i] == ',') {
+			i++
+		}
+	}
+
+	for skip(); i < len(s); skip() {
+		key, n, ok := sqlUnquote(s[i:])
+		if !ok || key == nil {
+			return nil, fmt.Errorf("%w: key at %d", ErrInvalidValue, i)
+		}
+
+		if i += n; !strings.HasPrefix(strings.TrimLeft(s[i:], " "), "=>") {
+			return nil, fmt.Errorf("%w: => at %d", ErrInvalidValue, i)
+		}
+
+		i = len(s) - len(strings.TrimLeft(strings.TrimLeft(s[i:], " ")[2:], " "))
+
+		if strings.HasPrefix(s[i:], "NULL") {
+			h[*key], i = nil, i+4
+
+			continue
+		}
+
+		val, n, ok := sqlUnquote(s[i:])
+		if !ok || val == nil {
+			return nil, fmt.Errorf("%w: value at %d", ErrInvalidValue, i)
+		}
+
+		h[*key], i = val, i+n
+	}
+
+	return h, nil
+}
+
+// -----------------------------------------------------------------------------
+// range
+// -----------------------------------------------------------------------------
+
+// PostgreSQLRangeBound is the type of the bound of int4range, int8range,
+// numrange, tsrange, tstzrange and daterange.
+type PostgreSQLRangeBound interface {
+	int32 | int64 | float64 | time.Time
+}
+
+// PostgreSQLRange is a range, a nil bound is unbounded (infinite).
+//
+//	r := sdk.PostgreSQLRange[time.Time]{Lower: &from, Upper: &to, LowerInc: true}
+//	_, err := conn.ExecContext(ctx, "INSERT INTO bookings (during) VALUES ($1::tstzrange)", r)
+type PostgreSQLRange[T PostgreSQLRangeBound] struct {
+	Lower, Upper       *T
+	LowerInc, UpperInc bool
+	Empty              bool
+}
+
+// Contains is true when v is within the range.
+func (r PostgreSQLRange[T]) Contains(v T) bool {
+	if r.Empty {
+		return false
+	}
+
+	if r.Lower != nil {
+		if c := sqlCompare(v, *r.Lower); c < 0 || (c == 0 && !r.LowerInc) {
+			return false
+		}
+	}
+
+	if r.Upper != nil {
+		if c := sqlCompare(v, *r.Upper); c > 0 || (c == 0 && !r.UpperInc) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (r PostgreSQLRange[T]) Value() (driver.Value, error) {
+	if r.Empty {
+		return "empty", nil
+	}
+
+	b := new(strings.Builder)
+	if r.LowerInc && r.Lower != nil {
+		_ = b.WriteByte('[')
+	} else {
+		_ = b.WriteByte('(')
+	}
+
+	for i, v := range []*T{r.Lower, r.Upper} {
+		if i > 0 {
+			_ = b.WriteByte(',')
+		}
+
+		if v == nil {
+			continue
+		}
+
+		switch x := interface{}(*v).(type) {
+		case time.Time:
+			_, _ = b.WriteString(`"` + x.Format("2006-01-02 15:04:05.999999Z07:00") + `"`)
+		default:
+			_, _ = b.WriteString(fmt.Sprint(x))
+		}
+	}
+
+	if r.UpperInc && r.Upper != nil {
+		_ = b.WriteByte(']')
+	} else {
+		_ = b.WriteByte(')')
+	}
+
+	return b.String(), nil
+}
+
+func (r *PostgreSQLRange[T]) Scan(src interface{}) error {
+	s, null, err := sqlSource(src)
+	if err != nil {
+		return err
+	}
+
+	*r = PostgreSQLRange[T]{}
+	if s = strings.TrimSpace(s); null {
+		return nil
+	} else if s == "empty" {
+		r.Empty = true
+
+		return nil
+	} else if len(s) < 3 || !strings.ContainsRune("[(", rune(s[0])) || !strings.ContainsRune("])", rune(s[len(s)-1])) {
+		return fmt.Errorf("database: range: %w: %q", ErrInvalidValue, s)
+	}
+
+	r.LowerInc, r.UpperInc = s[0] == '[', s[len(s)-1] == ']'
+
+	fields, err := sqlSplitFields(s[1 : len(s)-1])
+	if err != nil || len(fields) != 2 {
+		return fmt.Errorf("database: range: %w: %q", ErrInvalidValue, s)
+	}
+
+	for i, f := range fields {
+		if f == nil {
+			continue
+		}
+
+		v := new(T)
+		if err := sqlAssignText(v, f); err != nil {
+			return fmt.Errorf("database: range: %w", err)
+		}
+
+		if i == 0 {
+			r.Lower = v
+		} else {
+			r.Upper = v
+		}
+	}
+
+	return nil
+}
+
+func sqlCompare[T PostgreSQLRangeBound](a, b T) int {
+	switch x := interface{}(a).(type) {
+	case time.Time:
+		return x.Compare(interface{}(b).(time.Time))
+	case int32:
+		return sqlCmp(x, interface{}(b).(int32))
+	case int64:
+		return sqlCmp(x, interface{}(b).(int64))
+	case float64:
+		return sqlCmp(x, interface{}(b).(float64))
+	}
+
+	return 0
+}
+
+func sqlCmp[T int32 | int64 | float64](a, b T) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+
+	return 0
+}
+
+// -----------------------------------------------------------------------------
+// interval
+// -----------------------------------------------------------------------------
+
+// PostgreSQLInterval bridge an interval column into time.Duration, d should be
+// a *time.Duration when it is used as a Scanner. A month is 30 days and a year
+// is 365 days, as time.Duration has no calendar.
+func PostgreSQLInterval(d interface{}) interface {
+	driver.Valuer
+	sql.Scanner
+} {
+	return sqlValuerScanner{
+		func() (driver.Value, error) {
+			switch x := d.(type) {
+			case time.Duration:
+				return strconv.FormatInt(x.Microseconds(), 10) + " microseconds", nil
+			case *time.Duration:
+				if x == nil {
+					return nil, nil
+				}
+
+				return strconv.FormatInt(x.Microseconds(), 10) + " microseconds", nil
+			}
+
+			return nil, fmt.Errorf("database: interval: %w: %T", ErrInvalidValue, d)
+		},
+		func(src interface{}) error {
+			dest, ok := d.(*time.Duration)
+			if !ok || dest == nil {
+				return fmt.Errorf("database: interval: %w: %T", ErrInvalidValue, d)
+			}
+
+			s, null, err := sqlSource(src)
+			if err != nil {
+				return err
+			} else if null {
+				*dest = 0
+
+				return nil
+			}
+
+			if *dest, err = sqlParseInterval(s); err != nil {
+				return fmt.Errorf("database: interval: %w", err)
+			}
+
+			return nil
+		},
+	}
+}
+
+// sqlParseInterval parse the `postgres` and `postgres_verbose` IntervalStyle,
+// e.g. `1 year 2 mons 3 days -04:05:06.789` or `@ 3 days 4 hours ago`.
+func sqlParseInterval(s string) (d time.Duration, err error) {
+	fields := strings.Fields(strings.TrimPrefix(strings.TrimSpace(s), "@"))
+	ago := len(fields) > 0 && fields[len(fields)-1] == "ago"
+	if ago {
+		fields = fields[:len(fields)-1]
+	}
+
+	for i := 0; i < len(fields); i++ {
+		f := fields[i]
+		if strings.Contains(f, ":") {
+			neg := strings.HasPrefix(f, "-")
+			parts := strings.Split(strings.TrimLeft(f, "+-"), ":")
+
+			var t time.Duration
+			for j, unit := range []time.Duration{time.Hour, time.Minute, time.Second} {
+				if j >= len(parts) {
+					break
+				}
+
+				v, err := strconv.ParseFloat(parts[j], 64)
+				if err != nil {
+					return 0, fmt.Errorf("%w: %q", ErrInvalidValue, s)
+				}
+
+				t += time.Duration(v * float64(unit))
+			}
+
+			if neg {
+				t = -t
+			}
+
+			d += t
+
+			continue
+		}
+
+		if i+1 >= len(fields) {
+			return 0, fmt.Errorf("%w: %q", ErrInvalidValue, s)
+		}
+
+		v, err := strconv.ParseFloat(f, 64)
+		unit, ok := sqlIntervalUnits[strings.TrimSuffix(strings.ToLower(fields[i+1]), "s")]
+		if err != nil || !ok {
+			return 0, fmt.Errorf("%w: %q", ErrInvalidValue, s)
+		}
+
+		d, i = d+time.Duration(v*float64(unit)), i+1
+	}
+
+	if ago {
+		d = -d
+	}
+
+	return d, nil
+}
+
+// -----------------------------------------------------------------------------
+// inet & cidr
+// -----------------------------------------------------------------------------
+
+// PostgreSQLInet bridge an inet or cidr column into netip.Addr or netip.Prefix,
+// v should be a pointer when it is used as a Scanner. A host inet is scanned
+// into netip.Prefix with the full bits, and a network into netip.Addr without
+// the bits.
+func PostgreSQLInet(v interface{}) interface {
+	driver.Valuer
+	sql.Scanner
+} {
+	return sqlValuerScanner{
+		func() (driver.Value, error) {
+			switch x := v.(type) {
+			case netip.Addr:
+				return x.String(), nil
+			case netip.Prefix:
+				return x.String(), nil
+			case *netip.Addr:
+				if x != nil {
+					return x.String(), nil
+				}
+			case *netip.Prefix:
+				if x != nil {
+					return x.String(), nil
+				}
+			default:
+				return nil, fmt.Errorf("database: inet: %w: %T", ErrInvalidValue, v)
+			}
+
+			return nil, nil
+		},
+		func(src interface{}) error {
+			s, null, err := sqlSource(src)
+			if err != nil {
+				return err
+			}
+
+			var prefix netip.Prefix
+			if !null {
+				if strings.Contains(s, "/") {
+					prefix, err = netip.ParsePrefix(s)
+				} else if addr, err_ := netip.ParseAddr(s); err_ == nil {
+					prefix = netip.PrefixFrom(addr, addr.BitLen())
+				} else {
+					err = err_
+				}
+
+				if err != nil {
+					return fmt.Errorf("database: inet: %w", err)
+				}
+			}
+
+			switch x := v.(type) {
+			case *netip.Addr:
+				*x = prefix.Addr()
+			case *netip.Prefix:
+				*x = prefix
+			default:
+				return fmt.Errorf("database: inet: %w: %T", ErrInvalidValue, v)
+			}
+
+			return nil
+		},
+	}
+}
+
+// -----------------------------------------------------------------------------
+// composite
+// -----------------------------------------------------------------------------
+
+// PostgreSQLComposite bridge a composite (row) type into fields by order of its
+// attributes, the fields should be pointers when it is used as a Scanner.
+//
+//	type money struct{ Amount int64; Currency string }
+//	err := row.Scan(sdk.PostgreSQLComposite(&m.Amount, &m.Currency))
+//	_, err = conn.ExecContext(ctx, "UPDATE t SET price = $1::money_t", sdk.PostgreSQLComposite(m.Amount, m.Currency))
+func PostgreSQLComposite(fields ...interface{}) interface {
+	driver.Valuer
+	sql.Scanner
+} {
+	return sqlValuerScanner{
+		func() (driver.Value, error) {
+			b := new(strings.Builder)
+			_ = b.WriteByte('(')
+
+			for i, f := range fields {
+				if i > 0 {
+					_ = b.WriteByte(',')
+				}
+
+				s, null, err := sqlTextValue(f)
+				if err != nil {
+					return nil, fmt.Errorf("database: composite: field %d: %w", i, err)
+				} else if !null {
+					_, _ = b.WriteString(sqlQuote(s, `\`))
+				}
+			}
+
+			_ = b.WriteByte(')')
+
+			return b.String(), nil
+		},
+		func(src interface{}) error {
+			s, null, err := sqlSource(src)
+			if err != nil {
+				return err
+			} else if null {
+				for _, f := range fields {
+					if err := sqlAssignZero(f); err != nil {
+						return err
+					}
+				}
+
+				return nil
+			} else if s = strings.TrimSpace(s); len(s) < 2 || s[0] != '(' || s[len(s)-1] != ')' {
+				return fmt.Errorf("database: composite: %w: %q", ErrInvalidValue, s)
+			}
+
+			values, err := sqlSplitFields(s[1 : len(s)-1])
+			if err != nil || len(values) != len(fields) {
+				return fmt.Errorf("database: composite: %w: [%d] fields on %q", ErrInvalidValue, len(fields), s)
+			}
+
+			for i := range fields {
+				if err := sqlAssignText(fields[i], values[i]); err != nil {
+					return fmt.Errorf("database: composite: field %d: %w", i, err)
+				}
+			}
+
+			return nil
+		},
+	}
+}
+
+// -----------------------------------------------------------------------------
+// Null
+// -----------------------------------------------------------------------------
+
+// Null is a nullable T, that is also a NULL in JSON. T is converted using its
+// driver.Valuer and sql.Scanner when it implements them.
+//
+//	var email sdk.Null[string]
+//	err := row.Scan(&email)
+//	if email.Valid { ... email.V ... }
+type Null[T any] struct {
+	sql.Null[T]
+}
+
+// NullOf return a valid Null of v.
+func NullOf[T any](v T) Null[T] { return Null[T]{sql.Null[T]{V: v, Valid: true}} }
+
+func (n Null[T]) Value() (driver.Value, error) {
+	if !n.Valid {
+		return nil, nil
+	} else if valuer, ok := interface{}(n.V).(driver.Valuer); ok {
+		return valuer.Value()
+	}
+
+	return driver.DefaultParameterConverter.ConvertValue(n.V)
+}
+
+func (n *Null[T]) Scan(src interface{}) error {
+	if src == nil {
+		*n = Null[T]{}
+
+		return nil
+	} else if scanner, ok := interface{}(&n.V).(sql.Scanner); ok {
+		n.Valid = true
+
+		return scanner.Scan(src)
+	}
+
+	return n.Null.Scan(src)
+}
+
+func (n Null[T]) MarshalJSON() ([]byte, error) {
+	if !n.Valid {
+		return []byte("null"), nil
+	}
+
+	return JSON.Marshal(n.V)
+}
+
+func (n *Null[T]) UnmarshalJSON(p []byte) error {
+	if string(p) == "null" {
+		*n = Null[T]{}
+
+		return nil
+	}
+
+	n.Valid = true
+
+	return JSON.Unmarshal(p, &n.V)
+}
+
+// -----------------------------------------------------------------------------
+// text format
+// -----------------------------------------------------------------------------
+
+// sqlTextValue return the text format of v, as used by COPY and the literal of
+// array, range and composite.
+func sqlTextValue(v interface{}) (s string, null bool, err error) {
+	if valuer, ok := v.(driver.Valuer); ok {
+		if rv := reflect.ValueOf(v); rv.Kind() == reflect.Pointer && rv.IsNil() {
+			return "", true, nil
+		}
+
+		if v, err = valuer.Value(); err != nil {
+			return "", false, err
+		}
+	} else if sqlNamedIsSlice(v) {
+		if v, err = PostgreSQLArray(v).Value(); err != nil {
+			return "", false, err
+		}
+	}
+
+	switch x := v.(type) {
+	case nil:
+		return "", true, nil
+	case []byte:
+		if x == nil {
+			return "", true, nil
+		}
+
+		return `\x` + hex.EncodeToString(x), false, nil
+	case string:
+		return x, false, nil
+	case bool:
+		if x {
+			return "t", false, nil
+		}
+
+		return "f", false, nil
+	case time.Time:
+		return x.Format(time.RFC3339Nano), false, nil
+	}
+
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return "", true, nil
+		}
+
+		return sqlTextValue(rv.Elem().Interface())
+	}
+
+	return fmt.Sprint(v), false, nil
+}
+
+// sqlAssignText assign the text format s into dest, a nil s is a NULL.
+func sqlAssignText(dest interface{}, s *string) error {
+	if scanner, ok := dest.(sql.Scanner); ok {
+		if s == nil {
+			return scanner.Scan(nil)
+		}
+
+		return scanner.Scan(*s)
+	}
+
+	rv := reflect.ValueOf(dest)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("%w: %T is not a pointer", ErrInvalidValue, dest)
+	} else if s == nil {
+		return sqlAssignZero(dest)
+	}
+
+	rv = rv.Elem()
+	if rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+
+		return sqlAssignText(rv.Interface(), s)
+	}
+
+	var err error
+	switch x := rv.Addr().Interface().(type) {
+	case *time.Time:
+		for _, layout := range sqlTimeLayouts {
+			var t time.Time
+			if t, err = time.Parse(layout, *s); err == nil {
+				*x = t
+
+				return nil
+			}
+		}
+
+		return fmt.Errorf("%w: %q", ErrInvalidValue, *s)
+	case *[]byte:
+		if strings.HasPrefix(*s, `\x`) {
+			*x, err = hex.DecodeString((*s)[2:])
+		} else {
+			*x = []byte(*s)
+		}
+
+		return err
+	}
+
+	switch rv.Kind() {
+	case reflect.String:
+		rv.SetString(*s)
+	case reflect.Bool:
+		rv.SetBool(*s == "t" || *s == "true")
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var v int64
+		if v, err = strconv.ParseInt(*s, 10, rv.Type().Bits()); err == nil {
+			rv.SetInt(v)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var v uint64
+		if v, err = strconv.ParseUint(*s, 10, rv.Type().Bits()); err == nil {
+			rv.SetUint(v)
+		}
+	case reflect.Float32, reflect.Float64:
+		var v float64
+		if v, err = strconv.ParseFloat(*s, rv.Type().Bits()); err == nil {
+			rv.SetFloat(v)
+		}
+	default:
+		return fmt.Errorf("%w: %T", ErrInvalidValue, dest)
+	}
+
+	return err
+}
+
+func sqlAssignZero(dest interface{}) error {
+	rv := reflect.ValueOf(dest)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("%w: %T is not a pointer", ErrInvalidValue, dest)
+	}
+
+	rv.Elem().Set(reflect.Zero(rv.Elem().Type()))
+
+	return nil
+}
+
+// sqlQuote s with double quote, escaping `"` and `\` with esc.
+func sqlQuote(s, esc string) string {
+	return `"` + strings.NewReplacer(`\`, esc+`\`, `"`, esc+`"`).Replace(s) + `"`
+}
+
+// sqlUnquote read a quoted (or unquoted until a delimiter) value from the start
+// of s, it returns the value, the number of bytes read and false when invalid.
+func sqlUnquote(s string) (v *string, n int, ok bool) {
+	if !strings.HasPrefix(s, `"`) {
+		end := strings.IndexAny(s, ",=>) ")
+		if end < 0 {
+			end = len(s)
+		}
+
+		val := s[:end]
+
+		return &val, end, true
+	}
+
+	b := new(strings.Builder)
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' && i+1 < len(s):
+			i++
+			_ = b.WriteByte(s[i])
+		case c == '"' && i+1 < len(s) && s[i+1] == '"':
+			i++
+			_ = b.WriteByte('"')
+		case c == '"':
+			val := b.String()
+
+			return &val, i + 1, true
+		default:
+			_ = b.WriteByte(c)
+		}
+	}
+
+	return nil, 0, false
+}
+
+// sqlSplitFields split the comma separated fields of a range or composite, an
+// empty unquoted field is a NULL.
+func sqlSplitFields(s string) (fields []*string, err error) {
+	for i := 0; ; {
+		if i >= len(s) || s[i] == ',' {
+			fields = append(fields, nil)
+		} else {
+			v, n, ok := sqlUnquote(s[i:])
+			if !ok {
+				return nil, fmt.Errorf("%w: %q", ErrInvalidValue, s)
+			}
+
+			fields, i = append(fields, v), i+n
+		}
+
+		if i >= len(s) {
+			return fields, nil
+		} else if s[i] != ',' {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidValue, s)
+		}
+
+		i++
+	}
+}
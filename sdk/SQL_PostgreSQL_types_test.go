@@ -0,0 +1,231 @@
+package sdk_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"net/netip"
+	"os"
+	"testing"
+	"time"
+
+	. "github.com/gunawanwijaya/forest/sdk"
+	. "github.com/onsi/gomega"
+)
+
+func test_SQLPostgreSQLTypes(t *testing.T) {
+	t.Parallel()
+
+	Expect := NewWithT(t).Expect
+	ctx := context.Background()
+
+	roundTrip := func(in driver.Valuer, out interface{ Scan(interface{}) error }) {
+		v, err := in.Value()
+		Expect(err).To(Succeed())
+		Expect(out.Scan(v)).To(Succeed())
+	}
+
+	type settings struct {
+		Theme string `json:"theme"`
+		Size  int    `json:"size"`
+	}
+
+	t.Run("json", func(t *testing.T) {
+		in, out := settings{"dark", 12}, settings{}
+		roundTrip(PostgreSQLJSON(in), PostgreSQLJSON(&out))
+		Expect(out).To(Equal(in))
+
+		Expect(PostgreSQLJSON(&out).Scan(nil)).To(Succeed())
+		Expect(out).To(Equal(settings{}))
+
+		v, err := PostgreSQLJSON((*settings)(nil)).Value()
+		Expect(err).To(Succeed())
+		Expect(v).To(BeNil())
+	})
+	t.Run("hstore", func(t *testing.T) {
+		a, b := `x"y\z`, ""
+		in, out := map[string]*string{"a": &a, "b=>": &b, "c": nil}, map[string]*string{}
+		roundTrip(PostgreSQLHstore(in), PostgreSQLHstore(&out))
+		Expect(out).To(HaveLen(3))
+		Expect(*out["a"]).To(Equal(a))
+		Expect(*out["b=>"]).To(Equal(b))
+		Expect(out["c"]).To(BeNil())
+
+		Expect(PostgreSQLHstore(&out).Scan(`"a"=>"1",  "b" => NULL`)).To(Succeed())
+		Expect(*out["a"]).To(Equal("1"))
+		Expect(out).To(HaveKeyWithValue("b", BeNil()))
+		Expect(PostgreSQLHstore(&out).Scan(`"a"`)).To(MatchError(ErrInvalidValue))
+	})
+	t.Run("range", func(t *testing.T) {
+		lo, hi := int64(1), int64(10)
+		in, out := PostgreSQLRange[int64]{Lower: &lo, Upper: &hi, LowerInc: true}, PostgreSQLRange[int64]{}
+		v, err := in.Value()
+		Expect(err).To(Succeed())
+		Expect(v).To(Equal("[1,10)"))
+		roundTrip(in, &out)
+		Expect(out).To(Equal(in))
+		Expect(out.Contains(1)).To(BeTrue())
+		Expect(out.Contains(10)).To(BeFalse())
+
+		Expect(out.Scan("(,5]")).To(Succeed())
+		Expect(out.Lower).To(BeNil())
+		Expect(*out.Upper).To(Equal(int64(5)))
+		Expect(out.Contains(-100)).To(BeTrue())
+
+		Expect(out.Scan("empty")).To(Succeed())
+		Expect(out.Empty).To(BeTrue())
+		Expect(out.Contains(1)).To(BeFalse())
+		Expect(out.Scan("[1")).To(MatchError(ErrInvalidValue))
+
+		from := time.Date(2024, 1, 2, 3, 4, 5, 6000, time.UTC)
+		ts, tsOut := PostgreSQLRange[time.Time]{Lower: &from, LowerInc: true}, PostgreSQLRange[time.Time]{}
+		roundTrip(ts, &tsOut)
+		Expect(tsOut.Lower.Equal(from)).To(BeTrue())
+		Expect(tsOut.Upper).To(BeNil())
+
+		Expect(tsOut.Scan(`["2024-01-02 03:04:05.000006+07","2024-01-03 00:00:00+07")`)).To(Succeed())
+		Expect(tsOut.Lower.Equal(from.Add(-7 * time.Hour))).To(BeTrue())
+	})
+	t.Run("interval", func(t *testing.T) {
+		in, out := 26*time.Hour+3*time.Minute+4500*time.Microsecond, time.Duration(0)
+		v, err := PostgreSQLInterval(in).Value()
+		Expect(err).To(Succeed())
+		Expect(v).To(Equal("93780004500 microseconds"))
+
+		for s, d := range map[string]time.Duration{
+			"1 day 02:03:00.0045":   in,
+			"1 year 2 mons -3 days": (365 + 60 - 3) * 24 * time.Hour,
+			"-00:00:01.5":           -1500 * time.Millisecond,
+			"@ 1 day 2 hours ago":   -26 * time.Hour,
+			"00:00:00":              0,
+			"2 days":                48 * time.Hour,
+		} {
+			Expect(PostgreSQLInterval(&out).Scan(s)).To(Succeed(), s)
+			Expect(out).To(Equal(d), s)
+		}
+
+		Expect(PostgreSQLInterval(&out).Scan("1 fortnight")).To(MatchError(ErrInvalidValue))
+	})
+	t.Run("inet", func(t *testing.T) {
+		var addr netip.Addr
+		var prefix netip.Prefix
+
+		roundTrip(PostgreSQLInet(netip.MustParseAddr("10.0.0.1")), PostgreSQLInet(&addr))
+		Expect(addr).To(Equal(netip.MustParseAddr("10.0.0.1")))
+
+		roundTrip(PostgreSQLInet(netip.MustParsePrefix("10.0.0.0/8")), PostgreSQLInet(&prefix))
+		Expect(prefix).To(Equal(netip.MustParsePrefix("10.0.0.0/8")))
+
+		Expect(PostgreSQLInet(&prefix).Scan("::1")).To(Succeed())
+		Expect(prefix).To(Equal(netip.MustParsePrefix("::1/128")))
+		Expect(PostgreSQLInet(&addr).Scan("192.168.1.5/24")).To(Succeed())
+		Expect(addr).To(Equal(netip.MustParseAddr("192.168.1.5")))
+		Expect(PostgreSQLInet(&addr).Scan("x")).NotTo(Succeed())
+	})
+	t.Run("composite", func(t *testing.T) {
+		var amount int64
+		var currency string
+		var note *string
+
+		v, err := PostgreSQLComposite(int64(100), `I"DR`, nil).Value()
+		Expect(err).To(Succeed())
+		Expect(v).To(Equal(`("100","I\"DR",)`))
+
+		Expect(PostgreSQLComposite(&amount, &currency, &note).Scan(v)).To(Succeed())
+		Expect(amount).To(Equal(int64(100)))
+		Expect(currency).To(Equal(`I"DR`))
+		Expect(note).To(BeNil())
+
+		Expect(PostgreSQLComposite(&amount, &currency, &note).Scan(`(5,"a ""b""",x)`)).To(Succeed())
+		Expect(amount).To(Equal(int64(5)))
+		Expect(currency).To(Equal(`a "b"`))
+		Expect(*note).To(Equal("x"))
+
+		Expect(PostgreSQLComposite(&amount).Scan(`(1,2)`)).To(MatchError(ErrInvalidValue))
+	})
+	t.Run("null", func(t *testing.T) {
+		var s Null[string]
+		Expect(s.Scan("a")).To(Succeed())
+		Expect(s).To(Equal(NullOf("a")))
+		Expect(s.Scan(nil)).To(Succeed())
+		Expect(s.Valid).To(BeFalse())
+
+		v, err := s.Value()
+		Expect(err).To(Succeed())
+		Expect(v).To(BeNil())
+
+		var d Null[time.Duration]
+		v, err = NullOf(time.Second).Value()
+		Expect(err).To(Succeed())
+		Expect(v).To(Equal(int64(time.Second)))
+		Expect(d.Scan(int64(time.Second))).To(Succeed())
+		Expect(d.V).To(Equal(time.Second))
+
+		var r Null[PostgreSQLRange[int32]]
+		Expect(r.Scan("[1,2)")).To(Succeed())
+		Expect(r.Valid).To(BeTrue())
+		Expect(*r.V.Lower).To(Equal(int32(1)))
+
+		p, err := JSON.Marshal(struct{ A, B Null[int] }{A: NullOf(1)})
+		Expect(err).To(Succeed())
+		Expect(string(p)).To(Equal(`{"A":1,"B":null}`))
+
+		var x struct{ A, B Null[int] }
+		Expect(JSON.Unmarshal([]byte(`{"A":null,"B":2}`), &x)).To(Succeed())
+		Expect(x.A.Valid).To(BeFalse())
+		Expect(x.B).To(Equal(NullOf(2)))
+	})
+	t.Run("postgresql", func(t *testing.T) {
+		dsn := os.Getenv("POSTGRES_DSN")
+		if dsn == "" {
+			t.Skip("POSTGRES_DSN is not set")
+		}
+
+		db, err := new(SQL).OpenWithDSN(ctx, dsn)
+		Expect(err).To(Succeed())
+		defer db.Close()
+
+		var (
+			jsonOut     settings
+			hstoreOut   map[string]*string
+			rangeOut    PostgreSQLRange[time.Time]
+			intervalOut time.Duration
+			inetOut     netip.Prefix
+			nullOut     Null[string]
+			amount      int64
+			currency    string
+		)
+
+		a := "1"
+		from := time.Date(2024, 1, 2, 3, 4, 5, 6000, time.UTC)
+
+		_, _ = db.ExecContext(ctx, "CREATE EXTENSION IF NOT EXISTS hstore")
+		err = db.QueryRowContext(ctx, `SELECT $1::jsonb, $2::hstore, $3::tstzrange, $4::interval, $5::cidr,
+			$6::text, ROW($7::bigint, $8::text)`,
+			PostgreSQLJSON(settings{"dark", 12}),
+			PostgreSQLHstore(map[string]*string{"a": &a, "b": nil}),
+			PostgreSQLRange[time.Time]{Lower: &from, LowerInc: true},
+			PostgreSQLInterval(90*time.Minute),
+			PostgreSQLInet(netip.MustParsePrefix("10.0.0.0/8")),
+			Null[string]{},
+			int64(100), "IDR",
+		).Scan(
+			PostgreSQLJSON(&jsonOut),
+			PostgreSQLHstore(&hstoreOut),
+			&rangeOut,
+			PostgreSQLInterval(&intervalOut),
+			PostgreSQLInet(&inetOut),
+			&nullOut,
+			PostgreSQLComposite(&amount, &currency),
+		)
+		Expect(err).To(Succeed())
+		Expect(jsonOut).To(Equal(settings{"dark", 12}))
+		Expect(*hstoreOut["a"]).To(Equal("1"))
+		Expect(hstoreOut).To(HaveKeyWithValue("b", BeNil()))
+		Expect(rangeOut.Lower.Equal(from)).To(BeTrue())
+		Expect(intervalOut).To(Equal(90 * time.Minute))
+		Expect(inetOut).To(Equal(netip.MustParsePrefix("10.0.0.0/8")))
+		Expect(nullOut.Valid).To(BeFalse())
+		Expect(amount).To(Equal(int64(100)))
+		Expect(currency).To(Equal("IDR"))
+	})
+}
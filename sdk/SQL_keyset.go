@@ -0,0 +1,366 @@
+package sdk
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// nolint: gochecknoglobals
+var (
+	ErrKeysetInvalidCursor = errors.New("Invalid cursor")
+	ErrKeysetInvalidOrder  = errors.New("Invalid keyset order")
+)
+
+// SQLKeysetOrder is a column of the sort spec.
+type SQLKeysetOrder struct {
+	Column string
+	Desc   bool
+}
+
+type SQLKeysetConfiguration struct {
+	// Key of the HMAC signing the cursor, required.
+	Key []byte
+
+	// Orders is the sort spec, the last column should be unique (e.g. id)
+	// so that the order is deterministic. The columns should be NOT NULL and
+	// selected by the query with the same name.
+	Orders []SQLKeysetOrder
+
+	// DefaultLimit & MaxLimit of a page, default to 20 & 100.
+	DefaultLimit int
+	MaxLimit     int
+}
+
+// SQLKeyset is a keyset (seek) pagination, instead of `OFFSET` the next page
+// continue from the sort values of the last row, carried in an opaque cursor.
+//
+//	keyset, _ := new(sdk.SQL).NewKeyset(&sdk.SQLKeysetConfiguration{
+//	  Key:    key,
+//	  Orders: []sdk.SQLKeysetOrder{{Column: "name"}, {Column: "id"}},
+//	})
+//
+//	cursor, limit, err := keyset.FromRequest(r) // ?cursor=...&limit=...
+//	next, err := keyset.QueryContext(ctx, conn, cqrs.SQL_query_core_get_product, cursor, limit,
+//	  func(i int) sdk.List {
+//	    res.List = append(res.List, GetProductResponse{})
+//	    return sdk.List{&res.List[i].ID, &res.List[i].Name}
+//	  },
+//	)
+type SQLKeyset struct {
+	cfg SQLKeysetConfiguration
+	sig string
+}
+
+// NewKeyset validate the configuration of SQLKeyset.
+func (SQL) NewKeyset(c *SQLKeysetConfiguration) (*SQLKeyset, error) {
+	if c == nil || len(c.Key) < 1 {
+		return nil, fmt.Errorf("database: keyset: %w: key required", ErrInvalidValue)
+	} else if len(c.Orders) < 1 {
+		return nil, fmt.Errorf("database: keyset: %w", ErrKeysetInvalidOrder)
+	}
+
+	cfg := SQLKeysetConfiguration{Key: c.Key, Orders: c.Orders, DefaultLimit: 20, MaxLimit: 100}
+	if c.DefaultLimit > 0 {
+		cfg.DefaultLimit = c.DefaultLimit
+	}
+
+	if c.MaxLimit > 0 {
+		cfg.MaxLimit = c.MaxLimit
+	}
+
+	sig := make([]string, len(cfg.Orders))
+	for i, o := range cfg.Orders {
+		if !sqlColumnIdentifier.MatchString(o.Column) {
+			return nil, fmt.Errorf("database: keyset: %w: %q", ErrKeysetInvalidOrder, o.Column)
+		}
+
+		sig[i] = o.Column
+		if o.Desc {
+			sig[i] += " DESC"
+		}
+	}
+
+	return &SQLKeyset{cfg, strings.Join(sig, ", ")}, nil
+}
+
+// Clause return the `WHERE ... ORDER BY ... LIMIT` clause of the page after
+// cursor, the placeholders start after offset. The limit is increased by one
+// to know whether there is a next page.
+func (k *SQLKeyset) Clause(cursor string, limit, offset int) (clause string, args []interface{}, err error) {
+	limit = k.limit(limit)
+
+	if cursor != "" {
+		if args, err = k.Decode(cursor); err != nil {
+			return "", nil, err
+		}
+
+		clause = "WHERE " + k.where(offset) + " "
+	}
+
+	return clause + "ORDER BY " + k.sig + " LIMIT " + strconv.Itoa(limit+1), args, nil
+}
+
+// where use a row comparison `(a, b) > ($1, $2)` when all columns have the
+// same direction, else the expanded form `a > $1 OR (a = $1 AND b < $2)`.
+func (k *SQLKeyset) where(offset int) string {
+	same, cols, params := true, []string{}, []string{}
+	for i, o := range k.cfg.Orders {
+		same = same && o.Desc == k.cfg.Orders[0].Desc
+		cols, params = append(cols, o.Column), append(params, "$"+strconv.Itoa(offset+i+1))
+	}
+
+	op := func(desc bool) string {
+		if desc {
+			return " < "
+		}
+
+		return " > "
+	}
+
+	if same {
+		return "(" + strings.Join(cols, ", ") + ")" + op(k.cfg.Orders[0].Desc) + "(" + strings.Join(params, ", ") + ")"
+	}
+
+	ors := make([]string, len(cols))
+	for i := range cols {
+		ands := []string{}
+		for j := 0; j < i; j++ {
+			ands = append(ands, cols[j]+" = "+params[j])
+		}
+
+		ors[i] = "(" + strings.Join(append(ands, cols[i]+op(k.cfg.Orders[i].Desc)+params[i]), " AND ") + ")"
+	}
+
+	return "(" + strings.Join(ors, " OR ") + ")"
+}
+
+// Apply wrap query as a subquery and append the clause of the page after cursor.
+func (k *SQLKeyset) Apply(query, cursor string, limit int, args ...interface{}) (string, []interface{}, error) {
+	clause, cargs, err := k.Clause(cursor, limit, len(args))
+	if err != nil {
+		return "", nil, err
+	}
+
+	query = strings.TrimRight(strings.TrimSpace(SQL{}.RemoveComment(query)), ";")
+
+	return "SELECT * FROM (" + query + ") AS keyset " + clause, append(append([]interface{}{}, args...), cargs...), nil
+}
+
+// QueryContext query the page after cursor and scan each row using BoxQuery,
+// next is an empty string on the last page.
+func (k *SQLKeyset) QueryContext(ctx context.Context, conn QueryContext, query, cursor string, limit int, row func(i int) List, args ...interface{}) (next string, err error) {
+	query, args, err = k.Apply(query, cursor, limit, args...)
+	if err != nil {
+		return "", err
+	}
+
+	rows, err := conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return "", fmt.Errorf("database: keyset: %w", err)
+	}
+
+	cols, err := rows.Columns()
+	if err != nil {
+		_ = rows.Close()
+
+		return "", fmt.Errorf("database: keyset: %w", err)
+	}
+
+	index := make([]int, len(k.cfg.Orders))
+	for i, o := range k.cfg.Orders {
+		index[i] = -1
+		for j, col := range cols {
+			if strings.EqualFold(col, o.Column) {
+				index[i] = j
+			}
+		}
+
+		if index[i] < 0 {
+			_ = rows.Close()
+
+			return "", fmt.Errorf("database: keyset: %w: %q is not selected", ErrKeysetInvalidOrder, o.Column)
+		}
+	}
+
+	limit, last, more := k.limit(limit), List(nil), false
+	err = SQL{}.BoxQuery(rows, nil).Scan(func(i int) List {
+		if i >= limit {
+			more = true
+
+			return nil
+		}
+
+		last = row(i)
+
+		return last
+	})
+	if err != nil || !more || last == nil {
+		return "", err
+	}
+
+	values := make([]interface{}, len(index))
+	for i, j := range index {
+		if j >= len(last) {
+			return "", fmt.Errorf("database: keyset: %w", ErrInvalidArgumentsScan)
+		}
+
+		rv := reflect.ValueOf(last[j])
+		for rv.Kind() == reflect.Pointer && !rv.IsNil() {
+			rv = rv.Elem()
+		}
+
+		if values[i], err = driver.DefaultParameterConverter.ConvertValue(rv.Interface()); err != nil {
+			return "", fmt.Errorf("database: keyset: %w", err)
+		}
+	}
+
+	return k.Encode(values...)
+}
+
+// FromRequest bind the `cursor` and `limit` query parameters of r, an invalid
+// cursor or limit return an error.
+func (k *SQLKeyset) FromRequest(r *http.Request) (cursor string, limit int, err error) {
+	q := r.URL.Query()
+	if cursor = q.Get("cursor"); cursor != "" {
+		if _, err = k.Decode(cursor); err != nil {
+			return "", 0, err
+		}
+	}
+
+	if l := q.Get("limit"); l != "" {
+		if limit, err = strconv.Atoi(l); err != nil || limit < 1 {
+			return "", 0, fmt.Errorf("database: keyset: %w: limit %q", ErrInvalidValue, l)
+		}
+	}
+
+	return cursor, k.limit(limit), nil
+}
+
+func (k *SQLKeyset) limit(limit int) int {
+	if limit < 1 {
+		return k.cfg.DefaultLimit
+	} else if limit > k.cfg.MaxLimit {
+		return k.cfg.MaxLimit
+	}
+
+	return limit
+}
+
+// -----------------------------------------------------------------------------
+// Cursor
+// -----------------------------------------------------------------------------
+
+// sqlKeysetValue keep the type of a value, so that []byte and time.Time are
+// decoded as is.
+type sqlKeysetValue struct {
+	T string `json:"t"`
+	V string `json:"v,omitempty"`
+}
+
+// Encode the sort values of the last row into an opaque cursor, signed using
+// HMAC-SHA256 along with the sort spec.
+func (k *SQLKeyset) Encode(values ...interface{}) (string, error) {
+	if len(values) != len(k.cfg.Orders) {
+		return "", fmt.Errorf("database: keyset: %w: [%d] values on [%d] orders", ErrInvalidValue, len(values), len(k.cfg.Orders))
+	}
+
+	vs := make([]sqlKeysetValue, len(values))
+	for i, v := range values {
+		v, err := driver.DefaultParameterConverter.ConvertValue(v)
+		if err != nil {
+			return "", fmt.Errorf("database: keyset: %w", err)
+		}
+
+		switch x := v.(type) {
+		case nil:
+			vs[i] = sqlKeysetValue{"n", ""}
+		case bool:
+			vs[i] = sqlKeysetValue{"b", strconv.FormatBool(x)}
+		case int64:
+			vs[i] = sqlKeysetValue{"i", strconv.FormatInt(x, 10)}
+		case float64:
+			vs[i] = sqlKeysetValue{"f", strconv.FormatFloat(x, 'g', -1, 64)}
+		case string:
+			vs[i] = sqlKeysetValue{"s", x}
+		case []byte:
+			vs[i] = sqlKeysetValue{"x", base64.RawURLEncoding.EncodeToString(x)}
+		case time.Time:
+			vs[i] = sqlKeysetValue{"t", x.Format(time.RFC3339Nano)}
+		default:
+			return "", fmt.Errorf("database: keyset: %w: %T", ErrInvalidValue, v)
+		}
+	}
+
+	p, err := JSON.Marshal(vs)
+	if err != nil {
+		return "", fmt.Errorf("database: keyset: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(p) + "." + base64.RawURLEncoding.EncodeToString(k.mac(p)), nil
+}
+
+// Decode verify the cursor and return the sort values.
+func (k *SQLKeyset) Decode(cursor string) (values []interface{}, err error) {
+	payload, mac, ok := strings.Cut(cursor, ".")
+	if !ok {
+		return nil, fmt.Errorf("database: keyset: %w", ErrKeysetInvalidCursor)
+	}
+
+	p, err1 := base64.RawURLEncoding.DecodeString(payload)
+	m, err2 := base64.RawURLEncoding.DecodeString(mac)
+	if err1 != nil || err2 != nil || !hmac.Equal(m, k.mac(p)) {
+		return nil, fmt.Errorf("database: keyset: %w", ErrKeysetInvalidCursor)
+	}
+
+	vs := []sqlKeysetValue{}
+	if err = JSON.Unmarshal(p, &vs); err != nil || len(vs) != len(k.cfg.Orders) {
+		return nil, fmt.Errorf("database: keyset: %w", ErrKeysetInvalidCursor)
+	}
+
+	values = make([]interface{}, len(vs))
+	for i, v := range vs {
+		switch v.T {
+		case "n":
+			values[i] = nil
+		case "b":
+			values[i], err = strconv.ParseBool(v.V)
+		case "i":
+			values[i], err = strconv.ParseInt(v.V, 10, 64)
+		case "f":
+			values[i], err = strconv.ParseFloat(v.V, 64)
+		case "s":
+			values[i] = v.V
+		case "x":
+			values[i], err = base64.RawURLEncoding.DecodeString(v.V)
+		case "t":
+			values[i], err = time.Parse(time.RFC3339Nano, v.V)
+		default:
+			err = ErrInvalidValue
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("database: keyset: %w: %s", ErrKeysetInvalidCursor, err.Error())
+		}
+	}
+
+	return values, nil
+}
+
+func (k *SQLKeyset) mac(p []byte) []byte {
+	h := hmac.New(sha256.New, k.cfg.Key)
+	_, _ = h.Write([]byte(k.sig))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write(p)
+
+	return h.Sum(nil)
+}
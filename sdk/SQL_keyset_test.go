@@ -0,0 +1,115 @@
+package sdk_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/gunawanwijaya/forest/sdk"
+	. "github.com/onsi/gomega"
+)
+
+func test_SQLKeyset(t *testing.T) {
+	t.Parallel()
+
+	Expect := NewWithT(t).Expect
+	ctx := context.Background()
+	key := []byte("secret")
+
+	t.Run("config", func(t *testing.T) {
+		_, err := new(SQL).NewKeyset(&SQLKeysetConfiguration{Orders: []SQLKeysetOrder{{Column: "id"}}})
+		Expect(err).To(MatchError(ErrInvalidValue))
+
+		_, err = new(SQL).NewKeyset(&SQLKeysetConfiguration{Key: key, Orders: []SQLKeysetOrder{{Column: "id; --"}}})
+		Expect(err).To(MatchError(ErrKeysetInvalidOrder))
+	})
+	t.Run("cursor", func(t *testing.T) {
+		k, err := new(SQL).NewKeyset(&SQLKeysetConfiguration{Key: key, Orders: []SQLKeysetOrder{{Column: "name"}, {Column: "id"}}})
+		Expect(err).To(Succeed())
+
+		cursor, err := k.Encode("A", []byte{1})
+		Expect(err).To(Succeed())
+
+		values, err := k.Decode(cursor)
+		Expect(err).To(Succeed())
+		Expect(values).To(Equal([]interface{}{"A", []byte{1}}))
+
+		_, err = k.Decode(cursor[:len(cursor)-2] + "xx")
+		Expect(err).To(MatchError(ErrKeysetInvalidCursor))
+
+		other, _ := new(SQL).NewKeyset(&SQLKeysetConfiguration{Key: key, Orders: []SQLKeysetOrder{{Column: "name", Desc: true}, {Column: "id"}}})
+		_, err = other.Decode(cursor)
+		Expect(err).To(MatchError(ErrKeysetInvalidCursor))
+	})
+	t.Run("clause", func(t *testing.T) {
+		k, _ := new(SQL).NewKeyset(&SQLKeysetConfiguration{Key: key, Orders: []SQLKeysetOrder{{Column: "name"}, {Column: "id"}}})
+		cursor, _ := k.Encode("A", []byte{1})
+
+		clause, args, err := k.Clause("", 0, 0)
+		Expect(err).To(Succeed())
+		Expect(clause).To(Equal("ORDER BY name, id LIMIT 21"))
+		Expect(args).To(BeEmpty())
+
+		clause, args, err = k.Clause(cursor, 500, 1)
+		Expect(err).To(Succeed())
+		Expect(clause).To(Equal("WHERE (name, id) > ($2, $3) ORDER BY name, id LIMIT 101"))
+		Expect(args).To(Equal([]interface{}{"A", []byte{1}}))
+
+		k, _ = new(SQL).NewKeyset(&SQLKeysetConfiguration{Key: key, Orders: []SQLKeysetOrder{{Column: "name", Desc: true}, {Column: "id"}}})
+		cursor, _ = k.Encode("A", []byte{1})
+		clause, _, err = k.Clause(cursor, 10, 0)
+		Expect(err).To(Succeed())
+		Expect(clause).To(Equal("WHERE ((name < $1) OR (name = $1 AND id > $2)) ORDER BY name DESC, id LIMIT 11"))
+	})
+	t.Run("query", func(t *testing.T) {
+		mock := new(SQL).NewMock(ctx, 0)
+		defer mock.Close()
+
+		k, _ := new(SQL).NewKeyset(&SQLKeysetConfiguration{Key: key, Orders: []SQLKeysetOrder{{Column: "id"}}})
+
+		r := httptest.NewRequest("GET", "/products?limit=2", nil)
+		cursor, limit, err := k.FromRequest(r)
+		Expect(err).To(Succeed())
+		Expect(cursor).To(BeEmpty())
+		Expect(limit).To(Equal(2))
+
+		_, _, err = k.FromRequest(httptest.NewRequest("GET", "/products?cursor=x", nil))
+		Expect(err).To(MatchError(ErrKeysetInvalidCursor))
+
+		mock.ExpectQuery(`^SELECT \* FROM \(SELECT id, name FROM core\.products\) AS keyset ORDER BY id LIMIT 3$`).
+			WillReturnRows([]string{"id", "name"},
+				[]interface{}{[]byte{1}, "A"}, []interface{}{[]byte{2}, "B"}, []interface{}{[]byte{3}, "C"})
+
+		type product struct {
+			ID   []byte
+			Name string
+		}
+
+		list := []product{}
+		next, err := k.QueryContext(ctx, mock, "-- select product\nSELECT id, name FROM core.products", cursor, limit,
+			func(i int) List {
+				list = append(list, product{})
+				return List{&list[i].ID, &list[i].Name}
+			})
+		Expect(err).To(Succeed())
+		Expect(list).To(HaveLen(2))
+
+		values, err := k.Decode(next)
+		Expect(err).To(Succeed())
+		Expect(values).To(Equal([]interface{}{[]byte{2}}))
+
+		mock.ExpectQuery(`WHERE \(id\) > \(\$1\) ORDER BY id LIMIT 3$`).WithArgs([]byte{2}).
+			WillReturnRows([]string{"id", "name"}, []interface{}{[]byte{3}, "C"})
+
+		list = list[:0]
+		next, err = k.QueryContext(ctx, mock, "SELECT id, name FROM core.products", next, limit,
+			func(i int) List {
+				list = append(list, product{})
+				return List{&list[i].ID, &list[i].Name}
+			})
+		Expect(err).To(Succeed())
+		Expect(list).To(HaveLen(1))
+		Expect(next).To(BeEmpty())
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+	})
+}
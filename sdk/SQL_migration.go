@@ -0,0 +1,444 @@
+package sdk
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// nolint: gochecknoglobals
+var (
+	ErrMigrationInvalidFile   = errors.New("Invalid migration file")
+	ErrMigrationNotFound      = errors.New("Migration not found")
+	ErrMigrationChecksumDrift = errors.New("Migration checksum drift")
+
+	sqlMigrationFileRegexp = regexp.MustCompile(`^(\d+)_([0-9A-Za-z_\-]+)\.(up|down)\.sql$`)
+)
+
+// sqlMigrationNoTx is a marker inside a migration file to execute the file
+// outside of a transaction, e.g. `CREATE INDEX CONCURRENTLY`.
+const sqlMigrationNoTx = "-- migrate:no-transaction"
+
+type SQLMigrationConfiguration struct {
+	// Table to record applied versions and checksums, default to
+	// `schema_migrations`.
+	Table string
+
+	// LockKey is the key of pg_advisory_lock, default to the hash of Table.
+	LockKey int64
+}
+
+// SQLMigrationStatus is a state of a single version.
+type SQLMigrationStatus struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+
+	// Drift is true when the applied checksum is different from the file.
+	Drift bool
+
+	// Missing is true when the version is applied but the file is not found.
+	Missing bool
+}
+
+// SQLMigration run versioned up/down files against PostgreSQL.
+type SQLMigration struct {
+	conn  SQLConn
+	c     SQLMigrationConfiguration
+	files []sqlMigrationFile
+}
+
+type sqlMigrationFile struct {
+	version        int64
+	name           string
+	up, down       string
+	upTx, downTx   bool
+	checksum       string
+	hasUp, hasDown bool
+}
+
+type sqlMigrationApplied struct {
+	version   int64
+	checksum  string
+	appliedAt time.Time
+}
+
+// NewMigration will read the migration files from dir of fsys, the file name
+// should follow `<version>_<name>.up.sql` and `<version>_<name>.down.sql`.
+//
+//	//go:embed *.sql
+//	var FS embed.FS
+//
+//	m, err := new(sdk.SQL).NewMigration(ctx, db, FS, ".", nil)
+//	n, err := m.Up(ctx)
+//
+// Each file is executed in a transaction, unless the file contain a line of
+// `-- migrate:no-transaction`. The conn should be a READ/WRITE access able to
+// provide a single dedicated connection to hold the advisory lock, i.e. a
+// *sql.DB, a *sql.Conn or SQL.NewRoundRobin.
+func (SQL) NewMigration(ctx context.Context, conn SQLConn, fsys fs.FS, dir string, c *SQLMigrationConfiguration) (*SQLMigration, error) {
+	m := &SQLMigration{conn: conn}
+	if c != nil {
+		m.c = *c
+	}
+
+	if m.c.Table == "" {
+		m.c.Table = "schema_migrations"
+	}
+
+	if !sqlIdentifier.MatchString(m.c.Table) {
+		return nil, fmt.Errorf("database: migration: %w: table %q", ErrInvalidValue, m.c.Table)
+	}
+
+	if m.c.LockKey == 0 {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(m.c.Table))
+		m.c.LockKey = int64(h.Sum64())
+	}
+
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("database: migration: %w", err)
+	}
+
+	files := map[int64]*sqlMigrationFile{}
+
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".sql") {
+			continue
+		}
+
+		match := sqlMigrationFileRegexp.FindStringSubmatch(e.Name())
+		if match == nil {
+			return nil, fmt.Errorf("database: migration: %w: %q", ErrMigrationInvalidFile, e.Name())
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil || version < 1 {
+			return nil, fmt.Errorf("database: migration: %w: %q", ErrMigrationInvalidFile, e.Name())
+		}
+
+		p, err := fs.ReadFile(fsys, path.Join(dir, e.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("database: migration: %w", err)
+		}
+
+		f, ok := files[version]
+		if !ok {
+			f = &sqlMigrationFile{version: version, name: match[2]}
+			files[version] = f
+		} else if f.name != match[2] {
+			return nil, fmt.Errorf("database: migration: %w: duplicate version %d", ErrMigrationInvalidFile, version)
+		}
+
+		query, tx := string(p), !strings.Contains(string(p), sqlMigrationNoTx)
+
+		switch match[3] {
+		case "up":
+			sum := sha256.Sum256(p)
+			f.up, f.upTx, f.hasUp, f.checksum = query, tx, true, hex.EncodeToString(sum[:])
+		case "down":
+			f.down, f.downTx, f.hasDown = query, tx, true
+		}
+	}
+
+	for _, f := range files {
+		if !f.hasUp {
+			return nil, fmt.Errorf("database: migration: %w: version %d has no up file", ErrMigrationInvalidFile, f.version)
+		}
+
+		m.files = append(m.files, *f)
+	}
+
+	sort.Slice(m.files, func(i, j int) bool { return m.files[i].version < m.files[j].version })
+
+	return m, nil
+}
+
+// Up will apply all pending migrations.
+func (m *SQLMigration) Up(ctx context.Context) (n int, err error) {
+	latest := int64(0)
+	if l := len(m.files); l > 0 {
+		latest = m.files[l-1].version
+	}
+
+	return m.To(ctx, latest)
+}
+
+// Down will rollback the latest applied migration.
+func (m *SQLMigration) Down(ctx context.Context) (n int, err error) {
+	err = m.session(ctx, func(conn SQLConn, applied map[int64]sqlMigrationApplied) error {
+		latest := int64(0)
+		for v := range applied {
+			if v > latest {
+				latest = v
+			}
+		}
+
+		if latest == 0 {
+			return nil
+		}
+
+		f, ok := m.file(latest)
+		if !ok {
+			return fmt.Errorf("database: migration: %w: version %d", ErrMigrationNotFound, latest)
+		}
+
+		if err := m.apply(ctx, conn, f, false); err != nil {
+			return err
+		}
+
+		n++
+
+		return nil
+	})
+
+	return n, err
+}
+
+// To will apply or rollback migrations until the given version is the latest
+// applied, use version 0 to rollback all migrations.
+func (m *SQLMigration) To(ctx context.Context, version int64) (n int, err error) {
+	if _, ok := m.file(version); !ok && version != 0 {
+		return 0, fmt.Errorf("database: migration: %w: version %d", ErrMigrationNotFound, version)
+	}
+
+	err = m.session(ctx, func(conn SQLConn, applied map[int64]sqlMigrationApplied) error {
+		// rollback every applied version above the target, latest first
+		versions := make([]int64, 0, len(applied))
+		for v := range applied {
+			if v > version {
+				versions = append(versions, v)
+			}
+		}
+
+		sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+
+		for _, v := range versions {
+			f, ok := m.file(v)
+			if !ok {
+				return fmt.Errorf("database: migration: %w: version %d", ErrMigrationNotFound, v)
+			}
+
+			if err := m.apply(ctx, conn, f, false); err != nil {
+				return err
+			}
+
+			n++
+		}
+
+		for _, f := range m.files {
+			if _, ok := applied[f.version]; ok || f.version > version {
+				continue
+			}
+
+			if err := m.apply(ctx, conn, f, true); err != nil {
+				return err
+			}
+
+			n++
+		}
+
+		return nil
+	})
+
+	return n, err
+}
+
+// Status will report every known version, both from the files and the table.
+func (m *SQLMigration) Status(ctx context.Context) (status []SQLMigrationStatus, err error) {
+	// the status is read without the advisory lock, so any conn will do
+	if err = m.ensureTable(ctx, m.conn); err != nil {
+		return nil, err
+	}
+
+	applied, err := m.applied(ctx, m.conn)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range m.files {
+		s := SQLMigrationStatus{Version: f.version, Name: f.name}
+		if a, ok := applied[f.version]; ok {
+			s.Applied, s.AppliedAt, s.Drift = true, a.appliedAt, a.checksum != f.checksum
+		}
+
+		status = append(status, s)
+	}
+
+	for v, a := range applied {
+		if _, ok := m.file(v); !ok {
+			status = append(status, SQLMigrationStatus{Version: v, Applied: true, AppliedAt: a.appliedAt, Missing: true})
+		}
+	}
+
+	sort.Slice(status, func(i, j int) bool { return status[i].Version < status[j].Version })
+
+	return status, nil
+}
+
+// session will hold the advisory lock, check the checksum drift and then call
+// fn with the applied versions.
+func (m *SQLMigration) session(ctx context.Context, fn func(SQLConn, map[int64]sqlMigrationApplied) error) (err error) {
+	conn, done, err := m.dedicated(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if _, err = conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, m.c.LockKey); err != nil {
+		return fmt.Errorf("database: migration: %w", err)
+	}
+
+	defer func() {
+		_, errU := conn.ExecContext(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock($1)`, m.c.LockKey)
+		if err == nil && errU != nil {
+			err = fmt.Errorf("database: migration: %w", errU)
+		}
+	}()
+
+	if err = m.ensureTable(ctx, conn); err != nil {
+		return err
+	}
+
+	applied, err := m.applied(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	drift := []string{}
+	for _, f := range m.files {
+		if a, ok := applied[f.version]; ok && a.checksum != f.checksum {
+			drift = append(drift, strconv.FormatInt(f.version, 10))
+		}
+	}
+
+	if len(drift) > 0 {
+		return fmt.Errorf("database: migration: %w: version %s", ErrMigrationChecksumDrift, strings.Join(drift, ", "))
+	}
+
+	return fn(conn, applied)
+}
+
+// dedicated will return a single connection of conn, since the advisory lock is
+// held per session; a conn unable to provide one is rejected instead of locking
+// & unlocking on the pool, which may land on different sessions.
+func (m *SQLMigration) dedicated(ctx context.Context) (SQLConn, func(), error) {
+	if conn, ok := m.conn.(*sql.Conn); ok {
+		return conn, func() {}, nil
+	}
+
+	c, ok := m.conn.(interface {
+		Conn(ctx context.Context) (*sql.Conn, error)
+	})
+	if !ok {
+		return nil, nil, fmt.Errorf("database: migration: %w: %T is unable to provide a dedicated connection", ErrInvalidDatabase, m.conn)
+	}
+
+	conn, err := c.Conn(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("database: migration: %w", err)
+	}
+
+	return conn, func() { _ = conn.Close() }, nil
+}
+
+func (m *SQLMigration) ensureTable(ctx context.Context, conn SQLConn) error {
+	_, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+m.c.Table+` (
+		version    BIGINT      NOT NULL PRIMARY KEY,
+		name       TEXT        NOT NULL,
+		checksum   TEXT        NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`)
+	if err != nil {
+		return fmt.Errorf("database: migration: %w", err)
+	}
+
+	return nil
+}
+
+func (m *SQLMigration) applied(ctx context.Context, conn SQLConn) (map[int64]sqlMigrationApplied, error) {
+	applied, a := map[int64]sqlMigrationApplied{}, sqlMigrationApplied{}
+
+	err := SQL{}.
+		BoxQuery(conn.QueryContext(ctx, `SELECT version, checksum, applied_at FROM `+m.c.Table)).
+		Scan(func(i int) List {
+			if i > 0 {
+				applied[a.version] = a
+			}
+
+			return List{&a.version, &a.checksum, &a.appliedAt}
+		})
+	if err != nil {
+		return nil, fmt.Errorf("database: migration: %w", err)
+	} else if a.version > 0 {
+		applied[a.version] = a
+	}
+
+	return applied, nil
+}
+
+// apply will execute up or down file and record it, inside a transaction unless
+// it is marked with sqlMigrationNoTx.
+func (m *SQLMigration) apply(ctx context.Context, conn SQLConn, f sqlMigrationFile, up bool) (err error) {
+	query, useTx := f.up, f.upTx
+	if !up {
+		if !f.hasDown {
+			return fmt.Errorf("database: migration %d_%s: %w: no down file", f.version, f.name, ErrMigrationNotFound)
+		}
+
+		query, useTx = f.down, f.downTx
+	}
+
+	exec := func(txc SQLTxConn) error {
+		if _, err := txc.ExecContext(ctx, query); err != nil {
+			return err
+		}
+
+		if up {
+			_, err := txc.ExecContext(ctx, `INSERT INTO `+m.c.Table+` (version, name, checksum) VALUES ($1, $2, $3)`,
+				f.version, f.name, f.checksum)
+
+			return err
+		}
+
+		_, err := txc.ExecContext(ctx, `DELETE FROM `+m.c.Table+` WHERE version = $1`, f.version)
+
+		return err
+	}
+
+	if !useTx {
+		err = exec(conn)
+	} else if tx, errTx := conn.BeginTx(ctx, nil); errTx != nil {
+		err = errTx
+	} else {
+		err = SQL{}.EndTx(tx, exec(tx))
+	}
+
+	if err != nil {
+		return fmt.Errorf("database: migration %d_%s: %w", f.version, f.name, err)
+	}
+
+	return nil
+}
+
+func (m *SQLMigration) file(version int64) (sqlMigrationFile, bool) {
+	i := sort.Search(len(m.files), func(i int) bool { return m.files[i].version >= version })
+	if i < len(m.files) && m.files[i].version == version {
+		return m.files[i], true
+	}
+
+	return sqlMigrationFile{}, false
+}
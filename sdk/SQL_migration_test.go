@@ -0,0 +1,214 @@
+package sdk_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash/fnv"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	. "github.com/gunawanwijaya/forest/sdk"
+	. "github.com/onsi/gomega"
+)
+
+func test_SQLMigration(t *testing.T) {
+	t.Parallel()
+
+	Expect := NewWithT(t).Expect
+	ctx := context.Background()
+	file := func(s string) *fstest.MapFile { return &fstest.MapFile{Data: []byte(s)} }
+
+	t.Run("invalid-file", func(t *testing.T) {
+		_, err := new(SQL).NewMigration(ctx, nil, fstest.MapFS{
+			"1_init.sql": file("SELECT 1"),
+		}, ".", nil)
+		Expect(err).To(MatchError(ErrMigrationInvalidFile))
+
+		_, err = new(SQL).NewMigration(ctx, nil, fstest.MapFS{
+			"1_init.down.sql": file("SELECT 1"),
+		}, ".", nil)
+		Expect(err).To(MatchError(ErrMigrationInvalidFile))
+
+		_, err = new(SQL).NewMigration(ctx, nil, fstest.MapFS{
+			"1_init.up.sql":  file("SELECT 1"),
+			"1_other.up.sql": file("SELECT 1"),
+		}, ".", nil)
+		Expect(err).To(MatchError(ErrMigrationInvalidFile))
+
+		_, err = new(SQL).NewMigration(ctx, nil, fstest.MapFS{}, ".", &SQLMigrationConfiguration{Table: "x; DROP"})
+		Expect(err).To(MatchError(ErrInvalidValue))
+	})
+	t.Run("not-found", func(t *testing.T) {
+		m, err := new(SQL).NewMigration(ctx, nil, fstest.MapFS{
+			"migration/0001_init.up.sql":   file("CREATE TABLE a (id INT)"),
+			"migration/0001_init.down.sql": file("DROP TABLE a"),
+			"migration/0002_index.up.sql":  file("-- migrate:no-transaction\nCREATE INDEX CONCURRENTLY ON a (id)"),
+			"migration/README.md":          file("#"),
+		}, "migration", nil)
+		Expect(err).To(Succeed())
+		Expect(m).NotTo(BeNil())
+
+		_, err = m.To(ctx, 3)
+		Expect(err).To(MatchError(ErrMigrationNotFound))
+	})
+
+	fsys := fstest.MapFS{
+		"0001_init.up.sql":    file("CREATE TABLE a (id INT)"),
+		"0001_init.down.sql":  file("DROP TABLE a"),
+		"0002_index.up.sql":   file("-- migrate:no-transaction\nCREATE INDEX CONCURRENTLY a_id ON a (id)"),
+		"0002_index.down.sql": file("DROP INDEX a_id"),
+	}
+	sum := func(name string) string {
+		p := sha256.Sum256(fsys[name].Data)
+
+		return hex.EncodeToString(p[:])
+	}
+	sum1, sum2 := sum("0001_init.up.sql"), sum("0002_index.up.sql")
+	columns, now := []string{"version", "checksum", "applied_at"}, time.Now().UTC()
+
+	// expectSession expect the advisory lock, the table and the applied versions
+	expectSession := func(mock *SQLMock, rows ...[]interface{}) {
+		mock.ExpectExec(`^SELECT pg_advisory_lock\(\$1\)$`).WithArgs(int64(42))
+		mock.ExpectExec(`^CREATE TABLE IF NOT EXISTS schema_migrations`)
+		mock.ExpectQuery(`^SELECT version, checksum, applied_at FROM schema_migrations$`).WillReturnRows(columns, rows...)
+	}
+
+	t.Run("up", func(t *testing.T) {
+		mock := new(SQL).NewMock(ctx, 0)
+		defer mock.Close()
+
+		m, err := new(SQL).NewMigration(ctx, mock, fsys, ".", &SQLMigrationConfiguration{LockKey: 42})
+		Expect(err).To(Succeed())
+
+		expectSession(mock)
+		mock.ExpectBegin()
+		mock.ExpectExec(`^CREATE TABLE a`)
+		mock.ExpectExec(`^INSERT INTO schema_migrations`).WithArgs(int64(1), "init", sum1)
+		mock.ExpectCommit()
+		// a no-transaction file is executed without BEGIN & COMMIT
+		mock.ExpectExec(`CREATE INDEX CONCURRENTLY`)
+		mock.ExpectExec(`^INSERT INTO schema_migrations`).WithArgs(int64(2), "index", sum2)
+		mock.ExpectExec(`^SELECT pg_advisory_unlock\(\$1\)$`).WithArgs(int64(42))
+
+		n, err := m.Up(ctx)
+		Expect(err).To(Succeed())
+		Expect(n).To(Equal(2))
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+	})
+	t.Run("up-failed", func(t *testing.T) {
+		mock := new(SQL).NewMock(ctx, 0)
+		defer mock.Close()
+
+		m, err := new(SQL).NewMigration(ctx, mock, fsys, ".", &SQLMigrationConfiguration{LockKey: 42})
+		Expect(err).To(Succeed())
+
+		errX := errors.New("x")
+		expectSession(mock)
+		mock.ExpectBegin()
+		mock.ExpectExec(`^CREATE TABLE a`).WillReturnError(errX)
+		mock.ExpectRollback()
+		mock.ExpectExec(`^SELECT pg_advisory_unlock`)
+
+		n, err := m.Up(ctx)
+		Expect(err).To(MatchError(errX))
+		Expect(err.Error()).To(ContainSubstring("migration 1_init"))
+		Expect(n).To(Equal(0))
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+	})
+	t.Run("down", func(t *testing.T) {
+		mock := new(SQL).NewMock(ctx, 0)
+		defer mock.Close()
+
+		m, err := new(SQL).NewMigration(ctx, mock, fsys, ".", &SQLMigrationConfiguration{LockKey: 42})
+		Expect(err).To(Succeed())
+
+		// only the latest applied version is rolled back
+		expectSession(mock, []interface{}{int64(1), sum1, now}, []interface{}{int64(2), sum2, now})
+		mock.ExpectBegin()
+		mock.ExpectExec(`^DROP INDEX a_id$`)
+		mock.ExpectExec(`^DELETE FROM schema_migrations WHERE version = \$1$`).WithArgs(int64(2))
+		mock.ExpectCommit()
+		mock.ExpectExec(`^SELECT pg_advisory_unlock`)
+
+		n, err := m.Down(ctx)
+		Expect(err).To(Succeed())
+		Expect(n).To(Equal(1))
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+	})
+	t.Run("status", func(t *testing.T) {
+		mock := new(SQL).NewMock(ctx, 0)
+		defer mock.Close()
+
+		m, err := new(SQL).NewMigration(ctx, mock, fsys, ".", nil)
+		Expect(err).To(Succeed())
+
+		// the status is read without the advisory lock
+		mock.ExpectExec(`^CREATE TABLE IF NOT EXISTS schema_migrations`)
+		mock.ExpectQuery(`^SELECT version, checksum, applied_at FROM schema_migrations$`).
+			WillReturnRows(columns, []interface{}{int64(1), "drifted", now}, []interface{}{int64(3), "x", now})
+
+		status, err := m.Status(ctx)
+		Expect(err).To(Succeed())
+		Expect(status).To(Equal([]SQLMigrationStatus{
+			{Version: 1, Name: "init", Applied: true, AppliedAt: now, Drift: true},
+			{Version: 2, Name: "index"},
+			{Version: 3, Applied: true, AppliedAt: now, Missing: true},
+		}))
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+	})
+	t.Run("checksum-drift", func(t *testing.T) {
+		mock := new(SQL).NewMock(ctx, 0)
+		defer mock.Close()
+
+		m, err := new(SQL).NewMigration(ctx, mock, fsys, ".", &SQLMigrationConfiguration{LockKey: 42})
+		Expect(err).To(Succeed())
+
+		// nothing is applied on drift, but the lock is still released
+		expectSession(mock, []interface{}{int64(1), "drifted", now})
+		mock.ExpectExec(`^SELECT pg_advisory_unlock\(\$1\)$`).WithArgs(int64(42))
+
+		n, err := m.Up(ctx)
+		Expect(err).To(MatchError(ErrMigrationChecksumDrift))
+		Expect(err.Error()).To(ContainSubstring("version 1"))
+		Expect(n).To(Equal(0))
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+	})
+	t.Run("advisory-lock", func(t *testing.T) {
+		mock := new(SQL).NewMock(ctx, 0)
+		defer mock.Close()
+
+		m, err := new(SQL).NewMigration(ctx, mock, fsys, ".", nil)
+		Expect(err).To(Succeed())
+
+		// the default key is derived from the table, and a failed lock is never
+		// unlocked nor followed by any statement
+		h := fnv.New64a()
+		_, _ = h.Write([]byte("schema_migrations"))
+
+		errX := errors.New("lock timeout")
+		mock.ExpectExec(`^SELECT pg_advisory_lock\(\$1\)$`).WithArgs(int64(h.Sum64())).WillReturnError(errX)
+
+		n, err := m.Up(ctx)
+		Expect(err).To(MatchError(errX))
+		Expect(n).To(Equal(0))
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+	})
+	t.Run("dedicated", func(t *testing.T) {
+		mock := new(SQL).NewMock(ctx, 0)
+		defer mock.Close()
+
+		// a wrapper without Conn(ctx) is rejected rather than locking on the pool
+		m, err := new(SQL).NewMigration(ctx, struct{ SQLConn }{mock}, fsys, ".", nil)
+		Expect(err).To(Succeed())
+
+		n, err := m.Up(ctx)
+		Expect(err).To(MatchError(ErrInvalidDatabase))
+		Expect(n).To(Equal(0))
+		_, err = m.Down(ctx)
+		Expect(err).To(MatchError(ErrInvalidDatabase))
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+	})
+}
@@ -0,0 +1,512 @@
+package sdk
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// nolint: gochecknoglobals
+var (
+	ErrSQLMockUnexpected = errors.New("Unexpected call")
+	ErrSQLMockUnmet      = errors.New("Unmet expectation")
+)
+
+type sqlMockKind string
+
+const (
+	sqlMockExec     sqlMockKind = "exec"
+	sqlMockQuery    sqlMockKind = "query"
+	sqlMockBegin    sqlMockKind = "begin"
+	sqlMockCommit   sqlMockKind = "commit"
+	sqlMockRollback sqlMockKind = "rollback"
+)
+
+// SQLMockArg is a custom matcher of an argument, see SQLMockAnyArg.
+type SQLMockArg interface {
+	Match(v interface{}) bool
+}
+
+type sqlMockAnyArg struct{}
+
+func (sqlMockAnyArg) Match(interface{}) bool { return true }
+
+// SQLMockAnyArg match any value of an argument.
+func SQLMockAnyArg() SQLMockArg { return sqlMockAnyArg{} }
+
+// SQLMock is an in-memory SQLConn, every call is matched in order against the
+// expectations, an unexpected call will return ErrSQLMockUnexpected.
+//
+//	mock := new(sdk.SQL).NewMock(ctx, 1)
+//	mock.ExpectExec(`INSERT INTO core\.users`).WithArgs([]byte{1}, "a@b.c").OnPrimary().WillReturnResult(0, 1)
+//	mock.ExpectQuery(`SELECT id, name FROM core\.products`).OnReplica().
+//	  WillReturnRows([]string{"id", "name"}, []interface{}{[]byte{1}, "A"})
+//
+//	x, _ := postgresql_core.New(ctx, postgresql_core.Configuration{}, postgresql_core.Dependency{SQLConn: mock})
+//	...
+//	Expect(mock.ExpectationsWereMet()).To(Succeed())
+//
+// The SQLConn is a SQL.NewRoundRobin of primary and the replicas when replica
+// is more than zero, so the routing could be asserted by OnPrimary & OnReplica.
+type SQLMock struct {
+	SQLConn
+
+	dbs []*sql.DB
+	mu  *sync.Mutex
+
+	expected   []*SQLMockExpectation
+	unexpected []string
+}
+
+// NewMock create a SQLMock with one primary and a number of replicas.
+func (SQL) NewMock(ctx context.Context, replica int) *SQLMock {
+	m := &SQLMock{mu: new(sync.Mutex)}
+
+	conns := make([]SQLConn, 0, replica+1)
+	for i := 0; i <= replica; i++ {
+		db := sql.OpenDB(sqlMockConnector{m, i})
+		m.dbs, conns = append(m.dbs, db), append(conns, db)
+	}
+
+	m.SQLConn = conns[0]
+	if replica > 0 {
+		m.SQLConn = SQL{}.NewRoundRobin(ctx, conns...)
+	}
+
+	return m
+}
+
+// Primary return the READ/WRITE connection.
+func (m *SQLMock) Primary() *sql.DB { return m.dbs[0] }
+
+// Replica return the i-th READ-ONLY connection, starting from 1.
+func (m *SQLMock) Replica(i int) *sql.DB { return m.dbs[i] }
+
+// Conn return a dedicated connection of the primary.
+func (m *SQLMock) Conn(ctx context.Context) (*sql.Conn, error) { return m.Primary().Conn(ctx) }
+
+// Close all connections.
+func (m *SQLMock) Close() error {
+	errs := new(ListError)
+	for _, db := range m.dbs {
+		errs = errs.Add(db.Close())
+	}
+
+	return errs.Err()
+}
+
+// ExpectExec expect an ExecContext matching the regular expression pattern.
+func (m *SQLMock) ExpectExec(pattern string) *SQLMockExpectation {
+	return m.expect(sqlMockExec, pattern)
+}
+
+// ExpectQuery expect a QueryContext or QueryRowContext matching the regular
+// expression pattern.
+func (m *SQLMock) ExpectQuery(pattern string) *SQLMockExpectation {
+	return m.expect(sqlMockQuery, pattern)
+}
+
+// ExpectBegin expect a BeginTx.
+func (m *SQLMock) ExpectBegin() *SQLMockExpectation { return m.expect(sqlMockBegin, "") }
+
+// ExpectCommit expect a Commit of a transaction.
+func (m *SQLMock) ExpectCommit() *SQLMockExpectation { return m.expect(sqlMockCommit, "") }
+
+// ExpectRollback expect a Rollback of a transaction.
+func (m *SQLMock) ExpectRollback() *SQLMockExpectation { return m.expect(sqlMockRollback, "") }
+
+func (m *SQLMock) expect(kind sqlMockKind, pattern string) *SQLMockExpectation {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e := &SQLMockExpectation{kind: kind, re: regexp.MustCompile(pattern), conn: -1}
+	m.expected = append(m.expected, e)
+
+	return e
+}
+
+// ExpectationsWereMet return an error listing the unmet expectations and the
+// unexpected calls, if any.
+//
+//	Expect(mock.ExpectationsWereMet()).To(Succeed())
+func (m *SQLMock) ExpectationsWereMet() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	errs := new(ListError)
+	for _, e := range m.expected {
+		if !e.called {
+			errs = errs.Add(fmt.Errorf("database: mock: %w: %s", ErrSQLMockUnmet, e))
+		}
+	}
+
+	for _, u := range m.unexpected {
+		errs = errs.Add(fmt.Errorf("database: mock: %w: %s", ErrSQLMockUnexpected, u))
+	}
+
+	return errs.Err()
+}
+
+// match the call against the first unfulfilled expectation.
+func (m *SQLMock) match(kind sqlMockKind, conn int, query string, args []driver.NamedValue) (*SQLMockExpectation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	call := fmt.Sprintf("%s on conn %d", kind, conn)
+	if kind == sqlMockExec || kind == sqlMockQuery {
+		call = fmt.Sprintf("%s %q %v on conn %d", kind, query, sqlMockValues(args), conn)
+	}
+
+	for _, e := range m.expected {
+		if e.called {
+			continue
+		}
+
+		if reason := e.mismatch(kind, conn, query, args); reason != "" {
+			m.unexpected = append(m.unexpected, call+": "+reason)
+
+			return nil, fmt.Errorf("database: mock: %w: %s: %s", ErrSQLMockUnexpected, call, reason)
+		}
+
+		e.called = true
+
+		return e, nil
+	}
+
+	m.unexpected = append(m.unexpected, call)
+
+	return nil, fmt.Errorf("database: mock: %w: %s", ErrSQLMockUnexpected, call)
+}
+
+// -----------------------------------------------------------------------------
+// SQLMockExpectation
+// -----------------------------------------------------------------------------
+
+type SQLMockExpectation struct {
+	kind   sqlMockKind
+	re     *regexp.Regexp
+	args   []interface{}
+	conn   int
+	called bool
+
+	lastInsertID, rowsAffected int64
+	columns                    []string
+	rows                       [][]interface{}
+	err                        error
+	delay                      time.Duration
+}
+
+// WithArgs expect the exact arguments, a SQLMockArg is used as a matcher.
+func (e *SQLMockExpectation) WithArgs(args ...interface{}) *SQLMockExpectation {
+	e.args = args
+	if e.args == nil {
+		e.args = []interface{}{}
+	}
+
+	return e
+}
+
+// OnPrimary expect the call to be routed into the READ/WRITE connection.
+func (e *SQLMockExpectation) OnPrimary() *SQLMockExpectation {
+	e.conn = 0
+
+	return e
+}
+
+// OnReplica expect the call to be routed into any READ-ONLY connection.
+func (e *SQLMockExpectation) OnReplica() *SQLMockExpectation {
+	e.conn = -2
+
+	return e
+}
+
+// WillReturnResult of ExecContext.
+func (e *SQLMockExpectation) WillReturnResult(lastInsertID, rowsAffected int64) *SQLMockExpectation {
+	e.lastInsertID, e.rowsAffected = lastInsertID, rowsAffected
+
+	return e
+}
+
+// WillReturnRows of QueryContext, each row should have the same length as columns.
+func (e *SQLMockExpectation) WillReturnRows(columns []string, rows ...[]interface{}) *SQLMockExpectation {
+	e.columns, e.rows = columns, rows
+
+	return e
+}
+
+// WillReturnError of the call.
+func (e *SQLMockExpectation) WillReturnError(err error) *SQLMockExpectation {
+	e.err = err
+
+	return e
+}
+
+// WillDelay the call, or until the context is done.
+func (e *SQLMockExpectation) WillDelay(d time.Duration) *SQLMockExpectation {
+	e.delay = d
+
+	return e
+}
+
+func (e *SQLMockExpectation) String() string {
+	s := string(e.kind)
+	if e.re.String() != "" {
+		s += fmt.Sprintf(" /%s/", e.re)
+	}
+
+	if e.args != nil {
+		s += fmt.Sprintf(" %v", e.args)
+	}
+
+	switch e.conn {
+	case 0:
+		s += " on primary"
+	case -2:
+		s += " on replica"
+	}
+
+	return s
+}
+
+func (e *SQLMockExpectation) mismatch(kind sqlMockKind, conn int, query string, args []driver.NamedValue) string {
+	switch {
+	case e.kind != kind:
+		return "expecting " + e.String()
+	case !e.re.MatchString(query):
+		return "query does not match " + e.String()
+	case e.conn == 0 && conn != 0:
+		return "expecting primary"
+	case e.conn == -2 && conn == 0:
+		return "expecting replica"
+	case e.args == nil:
+		return ""
+	case len(e.args) != len(args):
+		return fmt.Sprintf("expecting %d args", len(e.args))
+	}
+
+	for i, want := range e.args {
+		if matcher, ok := want.(SQLMockArg); ok {
+			if !matcher.Match(args[i].Value) {
+				return fmt.Sprintf("arg %d does not match", i)
+			}
+
+			continue
+		}
+
+		if !reflect.DeepEqual(sqlMockValue(want), args[i].Value) {
+			return fmt.Sprintf("arg %d: expecting %v, got %v", i, want, args[i].Value)
+		}
+	}
+
+	return ""
+}
+
+func (e *SQLMockExpectation) wait(ctx context.Context) error {
+	if e.delay > 0 {
+		t := time.NewTimer(e.delay)
+		defer t.Stop()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+
+	return e.err
+}
+
+// sqlMockValue convert v into a driver.Value when it is able to, so that int &
+// int64 or a driver.Valuer is comparable.
+func sqlMockValue(v interface{}) interface{} {
+	if dv, err := driver.DefaultParameterConverter.ConvertValue(v); err == nil {
+		return dv
+	}
+
+	return v
+}
+
+func sqlMockValues(args []driver.NamedValue) []interface{} {
+	values := make([]interface{}, len(args))
+	for i := range args {
+		values[i] = args[i].Value
+	}
+
+	return values
+}
+
+// -----------------------------------------------------------------------------
+// driver
+// -----------------------------------------------------------------------------
+
+type sqlMockConnector struct {
+	mock *SQLMock
+	idx  int
+}
+
+func (c sqlMockConnector) Connect(context.Context) (driver.Conn, error) { return &sqlMockConn{c}, nil }
+func (c sqlMockConnector) Driver() driver.Driver                        { return sqlMockDriver{c} }
+
+type sqlMockDriver struct{ c sqlMockConnector }
+
+func (d sqlMockDriver) Open(string) (driver.Conn, error) { return &sqlMockConn{d.c}, nil }
+
+type sqlMockConn struct{ sqlMockConnector }
+
+var (
+	_ driver.ConnBeginTx        = (*sqlMockConn)(nil)
+	_ driver.ExecerContext      = (*sqlMockConn)(nil)
+	_ driver.QueryerContext     = (*sqlMockConn)(nil)
+	_ driver.NamedValueChecker  = (*sqlMockConn)(nil)
+	_ driver.ConnPrepareContext = (*sqlMockConn)(nil)
+)
+
+func (c *sqlMockConn) Close() error { return nil }
+
+func (c *sqlMockConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *sqlMockConn) BeginTx(ctx context.Context, _ driver.TxOptions) (driver.Tx, error) {
+	e, err := c.mock.match(sqlMockBegin, c.idx, "", nil)
+	if err != nil {
+		return nil, err
+	} else if err = e.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	return sqlMockTx{c}, nil
+}
+
+func (c *sqlMockConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *sqlMockConn) PrepareContext(_ context.Context, query string) (driver.Stmt, error) {
+	return &sqlMockStmt{c, query}, nil
+}
+
+// CheckNamedValue accept any value, so that a slice could be matched as is.
+func (c *sqlMockConn) CheckNamedValue(nv *driver.NamedValue) error {
+	nv.Value = sqlMockValue(nv.Value)
+
+	return nil
+}
+
+func (c *sqlMockConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	e, err := c.mock.match(sqlMockExec, c.idx, query, args)
+	if err != nil {
+		return nil, err
+	} else if err = e.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	return sqlMockResult{e.lastInsertID, e.rowsAffected}, nil
+}
+
+func (c *sqlMockConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	e, err := c.mock.match(sqlMockQuery, c.idx, query, args)
+	if err != nil {
+		return nil, err
+	} else if err = e.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	return &sqlMockRows{e.columns, e.rows, 0}, nil
+}
+
+type sqlMockTx struct{ c *sqlMockConn }
+
+func (tx sqlMockTx) Commit() error {
+	e, err := tx.c.mock.match(sqlMockCommit, tx.c.idx, "", nil)
+	if err != nil {
+		return err
+	}
+
+	return e.err
+}
+
+func (tx sqlMockTx) Rollback() error {
+	e, err := tx.c.mock.match(sqlMockRollback, tx.c.idx, "", nil)
+	if err != nil {
+		return err
+	}
+
+	return e.err
+}
+
+type sqlMockStmt struct {
+	c     *sqlMockConn
+	query string
+}
+
+func (s *sqlMockStmt) Close() error  { return nil }
+func (s *sqlMockStmt) NumInput() int { return -1 }
+
+func (s *sqlMockStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), sqlMockNamed(args))
+}
+
+func (s *sqlMockStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), sqlMockNamed(args))
+}
+
+func (s *sqlMockStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.c.ExecContext(ctx, s.query, args)
+}
+
+func (s *sqlMockStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.c.QueryContext(ctx, s.query, args)
+}
+
+func (s *sqlMockStmt) CheckNamedValue(nv *driver.NamedValue) error { return s.c.CheckNamedValue(nv) }
+
+func sqlMockNamed(args []driver.Value) []driver.NamedValue {
+	nv := make([]driver.NamedValue, len(args))
+	for i := range args {
+		nv[i] = driver.NamedValue{Ordinal: i + 1, Value: args[i]}
+	}
+
+	return nv
+}
+
+type sqlMockResult struct{ lastInsertID, rowsAffected int64 }
+
+func (r sqlMockResult) LastInsertId() (int64, error) { return r.lastInsertID, nil }
+func (r sqlMockResult) RowsAffected() (int64, error) { return r.rowsAffected, nil }
+
+type sqlMockRows struct {
+	columns []string
+	rows    [][]interface{}
+	i       int
+}
+
+func (r *sqlMockRows) Columns() []string { return r.columns }
+func (r *sqlMockRows) Close() error      { return nil }
+
+func (r *sqlMockRows) Next(dest []driver.Value) error {
+	if r.i >= len(r.rows) {
+		return io.EOF
+	}
+
+	row := r.rows[r.i]
+	if len(row) != len(dest) {
+		return fmt.Errorf("database: mock: %w: row %d has %d columns, expecting %d",
+			ErrInvalidValue, r.i, len(row), len(dest))
+	}
+
+	for i := range row {
+		dest[i] = sqlMockValue(row[i])
+	}
+
+	r.i++
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package sdk_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/gunawanwijaya/forest/sdk"
+	. "github.com/onsi/gomega"
+)
+
+func test_SQLMock(t *testing.T) {
+	t.Parallel()
+
+	Expect := NewWithT(t).Expect
+	ctx := context.Background()
+	errX := errors.New("x")
+
+	t.Run("exec-query", func(t *testing.T) {
+		mock := new(SQL).NewMock(ctx, 1)
+		defer mock.Close()
+
+		mock.ExpectExec(`INSERT INTO core\.users`).WithArgs([]byte{1}, "a@b.c").OnPrimary().WillReturnResult(0, 1)
+		mock.ExpectQuery(`SELECT id, name FROM core\.products`).OnReplica().
+			WillReturnRows([]string{"id", "name"}, []interface{}{[]byte{1}, "A"}, []interface{}{[]byte{2}, "B"})
+		mock.ExpectQuery(`SELECT name`).WithArgs(SQLMockAnyArg()).WillReturnError(errX)
+
+		var n int
+		Expect(new(SQL).BoxExec(mock.ExecContext(ctx, "INSERT INTO core.users (id, email) VALUES ($1, $2)",
+			[]byte{1}, "a@b.c")).Scan(&n, nil)).To(Succeed())
+		Expect(n).To(Equal(1))
+
+		names := []string{}
+		Expect(new(SQL).BoxQuery(mock.QueryContext(ctx, "SELECT id, name FROM core.products")).Scan(func(i int) List {
+			names = append(names, "")
+			return List{new([]byte), &names[i]}
+		})).To(Succeed())
+		Expect(names).To(Equal([]string{"A", "B"}))
+
+		var name string
+		Expect(new(SQL).BoxQueryRow(mock.QueryContext(ctx, "SELECT name FROM t WHERE id = $1", 1)).
+			Scan(&name)).To(MatchError(errX))
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+	})
+	t.Run("tx", func(t *testing.T) {
+		mock := new(SQL).NewMock(ctx, 0)
+		defer mock.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`DELETE`).WillReturnError(errX)
+		mock.ExpectRollback()
+
+		tx, err := mock.BeginTx(ctx, nil)
+		Expect(err).To(Succeed())
+
+		_, err = tx.ExecContext(ctx, "DELETE FROM t")
+		Expect(new(SQL).EndTx(tx, err)).To(MatchError(errX))
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+	})
+	t.Run("unmet-unexpected", func(t *testing.T) {
+		mock := new(SQL).NewMock(ctx, 1)
+		defer mock.Close()
+
+		mock.ExpectQuery(`SELECT 1`).OnPrimary()
+		mock.ExpectExec(`UPDATE`)
+
+		_, err := mock.QueryContext(ctx, "SELECT 1")
+		Expect(err).To(MatchError(ErrSQLMockUnexpected))
+
+		err = mock.ExpectationsWereMet()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring(ErrSQLMockUnmet.Error() + ": exec /UPDATE/"))
+		Expect(err.Error()).To(ContainSubstring("expecting primary"))
+	})
+}
@@ -0,0 +1,321 @@
+package sdk
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// nolint: gochecknoglobals
+var (
+	ErrNamedParameterMissing = errors.New("Missing named parameter")
+	ErrNamedParameterMixed   = errors.New("Mixed named and positional parameters")
+
+	sqlNamedCache     sync.Map
+	sqlNamedCacheSize atomic.Int64
+	sqlNamedInRegexp  = regexp.MustCompile(`(?i)\bIN\s*\(\s*$`)
+)
+
+// sqlNamedCacheLimit bound the number of parsed queries that are cached, a query
+// built at runtime (e.g. concatenated) is parsed on each call once the limit is
+// reached instead of growing the cache indefinitely.
+const sqlNamedCacheLimit = 1024
+
+// SQLDialect decide the placeholder style and how a slice is bound.
+type SQLDialect int
+
+const (
+	// SQLDialectPostgreSQL use `$1` placeholder, a slice is bound as an array
+	// (see PostgreSQLArray) except inside `IN (...)` where it is expanded.
+	SQLDialectPostgreSQL SQLDialect = iota
+	// SQLDialectMySQL use `?` placeholder, a slice is always expanded.
+	SQLDialectMySQL
+	// SQLDialectSQLite use `?` placeholder, a slice is always expanded.
+	SQLDialectSQLite
+)
+
+func (d SQLDialect) placeholder(n int) string {
+	if d == SQLDialectPostgreSQL {
+		return "$" + strconv.Itoa(n)
+	}
+
+	return "?"
+}
+
+// sqlNamedSegment is a part of query followed by a named parameter, the last
+// segment of a query has an empty name.
+type sqlNamedSegment struct {
+	text string
+	name string
+	in   bool
+}
+
+// sqlNamedParsed is the cached parse result of a query, positional is true when
+// a `$1` placeholder is found outside quoted literals and comments.
+type sqlNamedParsed struct {
+	segments   []sqlNamedSegment
+	positional bool
+}
+
+// Named will rewrite `:name` or `@name` parameters of query into the placeholder
+// of dialect, and bind the args from arg, a map with string key or a struct
+// with `db` tag (or the field name when no tag).
+//
+//	query, args, err := new(sdk.SQL).Named(sdk.SQLDialectPostgreSQL,
+//	  "SELECT id FROM core.users WHERE email = :email AND id IN (:ids)",
+//	  map[string]interface{}{"email": "a@b.c", "ids": [][]byte{{1}, {2}}},
+//	)
+//	// SELECT id FROM core.users WHERE email = $1 AND id IN ($2, $3)
+//
+// Quoted literals, comments, `::type` casts and the `@@`, `@>` & `<@` operators
+// are kept as is, and the parsed query is cached so that the rewrite is cheap for
+// the same query.
+func (SQL) Named(dialect SQLDialect, query string, arg interface{}) (query_ string, args []interface{}, err error) {
+	parsed := sqlNamedParse(query)
+	segments := parsed.segments
+
+	if len(segments) < 2 {
+		return query, nil, nil
+	} else if parsed.positional && dialect == SQLDialectPostgreSQL {
+		return "", nil, fmt.Errorf("database: named: %w", ErrNamedParameterMixed)
+	}
+
+	lookup, err := sqlNamedLookup(arg)
+	if err != nil {
+		return "", nil, err
+	}
+
+	b, index := new(strings.Builder), map[string]int{}
+
+	for _, seg := range segments {
+		_, _ = b.WriteString(seg.text)
+		if seg.name == "" {
+			continue
+		}
+
+		v, ok := lookup(seg.name)
+		if !ok {
+			return "", nil, fmt.Errorf("database: named: %w: %q", ErrNamedParameterMissing, seg.name)
+		}
+
+		if n, ok := index[seg.name]; ok && dialect == SQLDialectPostgreSQL {
+			_, _ = b.WriteString(dialect.placeholder(n))
+
+			continue
+		}
+
+		rv := reflect.ValueOf(v)
+		expand := sqlNamedIsSlice(v) && (seg.in || dialect != SQLDialectPostgreSQL)
+
+		switch {
+		case expand && rv.Len() < 1:
+			_, _ = b.WriteString("NULL")
+		case expand:
+			for i := 0; i < rv.Len(); i++ {
+				if i > 0 {
+					_, _ = b.WriteString(", ")
+				}
+
+				args = append(args, rv.Index(i).Interface())
+				_, _ = b.WriteString(dialect.placeholder(len(args)))
+			}
+		default:
+			if sqlNamedIsSlice(v) {
+				v = PostgreSQLArray(v)
+			}
+
+			args = append(args, v)
+			index[seg.name] = len(args)
+			_, _ = b.WriteString(dialect.placeholder(len(args)))
+		}
+	}
+
+	return b.String(), args, nil
+}
+
+// NamedParameters list the unique named parameters of query by order of its
+// first appearance.
+func (SQL) NamedParameters(query string) (names []string) {
+	set := map[string]struct{}{}
+
+	for _, seg := range sqlNamedParse(query).segments {
+		if _, ok := set[seg.name]; !ok && seg.name != "" {
+			set[seg.name], names = struct{}{}, append(names, seg.name)
+		}
+	}
+
+	return names
+}
+
+// NamedExecContext is ExecContext with named parameters, see SQL.Named.
+func (SQL) NamedExecContext(ctx context.Context, conn ExecContext, dialect SQLDialect, query string, arg interface{}) (res sql.Result, err error) {
+	query, args, err := SQL{}.Named(dialect, query, arg)
+	if err != nil {
+		return nil, err
+	}
+
+	return conn.ExecContext(ctx, query, args...)
+}
+
+// NamedQueryContext is QueryContext with named parameters, see SQL.Named.
+func (SQL) NamedQueryContext(ctx context.Context, conn QueryContext, dialect SQLDialect, query string, arg interface{}) (rows *sql.Rows, err error) {
+	query, args, err := SQL{}.Named(dialect, query, arg)
+	if err != nil {
+		return nil, err
+	}
+
+	return conn.QueryContext(ctx, query, args...)
+}
+
+func sqlNamedParse(query string) sqlNamedParsed {
+	if v, ok := sqlNamedCache.Load(query); ok {
+		return v.(sqlNamedParsed)
+	}
+
+	parsed, segments, b := sqlNamedParsed{}, []sqlNamedSegment{}, new(strings.Builder)
+	isName := func(c byte, first bool) bool {
+		return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (!first && c >= '0' && c <= '9')
+	}
+	copyUntil := func(i int, end string) int {
+		j := strings.Index(query[i:], end)
+		if j < 0 {
+			j = len(query) - i - len(end)
+		}
+
+		_, _ = b.WriteString(query[i : i+j+len(end)])
+
+		return i + j + len(end) - 1
+	}
+
+	for i := 0; i < len(query); i++ {
+		c, next := query[i], byte(0)
+		if i+1 < len(query) {
+			next = query[i+1]
+		}
+
+		switch {
+		case c == '\'' || c == '"':
+			_ = b.WriteByte(c)
+			i = copyUntil(i+1, string(c))
+		case c == '-' && next == '-':
+			i = copyUntil(i, "\n")
+		case c == '/' && next == '*':
+			i = copyUntil(i, "*/")
+		case c == '$' && (next == '$' || isName(next, true)):
+			// dollar-quoted string `$tag$ ... $tag$`
+			j := strings.IndexByte(query[i+1:], '$')
+			if tag := query[i : i+j+2]; j > -1 && !strings.ContainsAny(tag, " \t\n(),;") {
+				_, _ = b.WriteString(tag)
+				i = copyUntil(i+len(tag), tag)
+			} else {
+				_ = b.WriteByte(c)
+			}
+		case c == '$' && next >= '0' && next <= '9':
+			parsed.positional = true
+			_ = b.WriteByte(c)
+		case c == ':' && next == ':', c == '@' && (next == '@' || next == '>'), c == '<' && next == '@':
+			// `::type` cast, `@@` text search, `@>` & `<@` containment operators
+			_, _ = b.WriteString(query[i : i+2])
+			i++
+		case (c == ':' || c == '@') && isName(next, true) && (i == 0 || !isName(query[i-1], false)):
+			j := i + 1
+			for j < len(query) && isName(query[j], false) {
+				j++
+			}
+
+			segments = append(segments, sqlNamedSegment{b.String(), query[i+1 : j], sqlNamedInRegexp.MatchString(b.String())})
+			b.Reset()
+
+			i = j - 1
+		default:
+			_ = b.WriteByte(c)
+		}
+	}
+
+	parsed.segments = append(segments, sqlNamedSegment{b.String(), "", false})
+	if sqlNamedCacheSize.Load() < sqlNamedCacheLimit {
+		if _, loaded := sqlNamedCache.LoadOrStore(query, parsed); !loaded {
+			sqlNamedCacheSize.Add(1)
+		}
+	}
+
+	return parsed
+}
+
+// sqlNamedLookup return a lookup func of arg, a map with string key or a struct.
+func sqlNamedLookup(arg interface{}) (func(name string) (interface{}, bool), error) {
+	rv := reflect.ValueOf(arg)
+	for rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+
+	switch {
+	case arg == nil:
+		return func(string) (interface{}, bool) { return nil, false }, nil
+	case rv.Kind() == reflect.Map && rv.Type().Key().Kind() == reflect.String:
+		return func(name string) (interface{}, bool) {
+			v := rv.MapIndex(reflect.ValueOf(name).Convert(rv.Type().Key()))
+			if !v.IsValid() {
+				return nil, false
+			}
+
+			return v.Interface(), true
+		}, nil
+	case rv.Kind() == reflect.Struct:
+		fields := map[string]interface{}{}
+		sqlNamedFields(rv, fields)
+
+		return func(name string) (interface{}, bool) {
+			v, ok := fields[name]
+			if !ok {
+				v, ok = fields[strings.ToLower(name)]
+			}
+
+			return v, ok
+		}, nil
+	}
+
+	return nil, fmt.Errorf("database: named: %w: %T", ErrInvalidValue, arg)
+}
+
+func sqlNamedFields(rv reflect.Value, fields map[string]interface{}) {
+	for i := 0; i < rv.NumField(); i++ {
+		f := rv.Type().Field(i)
+		tag, _, _ := strings.Cut(f.Tag.Get("db"), ",")
+
+		switch {
+		case tag == "" && f.Anonymous && f.Type.Kind() == reflect.Struct:
+			sqlNamedFields(rv.Field(i), fields)
+		case tag == "-" || !f.IsExported():
+			continue
+		case tag != "":
+			fields[tag] = rv.Field(i).Interface()
+		default:
+			fields[f.Name] = rv.Field(i).Interface()
+			if _, ok := fields[strings.ToLower(f.Name)]; !ok {
+				fields[strings.ToLower(f.Name)] = rv.Field(i).Interface()
+			}
+		}
+	}
+}
+
+// sqlNamedIsSlice is true for a slice or an array except []byte and Valuer.
+func sqlNamedIsSlice(v interface{}) bool {
+	if _, ok := v.(driver.Valuer); ok {
+		return false
+	} else if _, ok := v.([]byte); ok {
+		return false
+	}
+
+	k := reflect.ValueOf(v).Kind()
+
+	return k == reflect.Slice || k == reflect.Array
+}
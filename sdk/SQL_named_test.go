@@ -0,0 +1,81 @@
+package sdk_test
+
+import (
+	"testing"
+
+	. "github.com/gunawanwijaya/forest/sdk"
+	. "github.com/onsi/gomega"
+)
+
+func test_SQLNamed(t *testing.T) {
+	t.Parallel()
+
+	Expect := NewWithT(t).Expect
+	x := new(SQL)
+
+	t.Run("struct", func(t *testing.T) {
+		type base struct {
+			ID []byte `db:"id"`
+		}
+
+		arg := struct {
+			base
+			Email   string
+			Ignored string `db:"-"`
+		}{base{[]byte{1}}, "a@b.c", ""}
+
+		query, args, err := x.Named(SQLDialectPostgreSQL,
+			"-- :comment\nSELECT ':lit', id::text FROM t WHERE id = :id AND email = @email OR id = :id", &arg)
+		Expect(err).To(Succeed())
+		Expect(query).To(Equal("-- :comment\nSELECT ':lit', id::text FROM t WHERE id = $1 AND email = $2 OR id = $1"))
+		Expect(args).To(Equal([]interface{}{[]byte{1}, "a@b.c"}))
+
+		_, _, err = x.Named(SQLDialectPostgreSQL, "SELECT :ignored", &arg)
+		Expect(err).To(MatchError(ErrNamedParameterMissing))
+	})
+	t.Run("map", func(t *testing.T) {
+		arg := map[string]interface{}{"ids": []int{1, 2}, "none": []int{}, "tag": "x"}
+
+		query, args, err := x.Named(SQLDialectPostgreSQL, "SELECT $$:tag$$ WHERE a IN (:ids) AND b IN (:none) AND c = ANY(:ids)", arg)
+		Expect(err).To(Succeed())
+		Expect(query).To(Equal("SELECT $$:tag$$ WHERE a IN ($1, $2) AND b IN (NULL) AND c = ANY($3)"))
+		Expect(args).To(HaveLen(3))
+
+		query, args, err = x.Named(SQLDialectMySQL, "SELECT :tag WHERE a IN (:ids) AND b = :tag", arg)
+		Expect(err).To(Succeed())
+		Expect(query).To(Equal("SELECT ? WHERE a IN (?, ?) AND b = ?"))
+		Expect(args).To(Equal([]interface{}{"x", 1, 2, "x"}))
+
+		_, _, err = x.Named(SQLDialectPostgreSQL, "SELECT :tag, $1", arg)
+		Expect(err).To(MatchError(ErrNamedParameterMixed))
+	})
+	t.Run("positional-literal", func(t *testing.T) {
+		arg := map[string]interface{}{"tag": "x"}
+
+		// a `$1` inside a literal or a comment is not a positional parameter
+		query, args, err := x.Named(SQLDialectPostgreSQL, "SELECT '$1', \"$2\", $$ $3 $$ -- $4\nWHERE a = :tag /* $5 */", arg)
+		Expect(err).To(Succeed())
+		Expect(query).To(Equal("SELECT '$1', \"$2\", $$ $3 $$ -- $4\nWHERE a = $1 /* $5 */"))
+		Expect(args).To(Equal([]interface{}{"x"}))
+
+		// the cached parse result keep the positional parameter
+		for i := 0; i < 2; i++ {
+			_, _, err = x.Named(SQLDialectPostgreSQL, "SELECT '$1', :tag WHERE b = $1", arg)
+			Expect(err).To(MatchError(ErrNamedParameterMixed))
+		}
+	})
+	t.Run("operators", func(t *testing.T) {
+		arg := map[string]interface{}{"q": "a & b", "tags": []string{"x"}, "ids": []int{1}}
+
+		query, args, err := x.Named(SQLDialectPostgreSQL,
+			"SELECT id FROM t WHERE tsv @@to_tsquery(:q) AND tags @>:tags AND ids <@:ids AND doc @> '{}'", arg)
+		Expect(err).To(Succeed())
+		Expect(query).To(Equal("SELECT id FROM t WHERE tsv @@to_tsquery($1) AND tags @>$2 AND ids <@$3 AND doc @> '{}'"))
+		Expect(args).To(HaveLen(3))
+		Expect(x.NamedParameters("SELECT a @@b, a @>c, a <@d, @e")).To(Equal([]string{"e"}))
+	})
+	t.Run("parameters", func(t *testing.T) {
+		Expect(x.NamedParameters("SELECT :b, :a, @b, 'x:c' -- :d")).To(Equal([]string{"b", "a"}))
+		Expect(x.NamedParameters("SELECT 1")).To(BeEmpty())
+	})
+}
@@ -0,0 +1,320 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// nolint: gochecknoglobals
+var (
+	ErrOutboxInvalidTable = errors.New("Invalid outbox table")
+	ErrOutboxNoPublisher  = errors.New("No outbox publisher")
+)
+
+// SQLOutboxEvent is a row of the outbox table.
+type SQLOutboxEvent struct {
+	ID        int64
+	Topic     string
+	Key       []byte
+	Payload   []byte
+	Headers   map[string]string
+	Attempts  int
+	CreatedAt time.Time
+}
+
+// SQLOutboxPublisher publish an event into the message broker, an error will
+// make the event to be retried later or dead-lettered after MaxAttempts.
+type SQLOutboxPublisher interface {
+	Publish(ctx context.Context, event SQLOutboxEvent) (err error)
+}
+
+// SQLOutboxPublisherFunc is a func that satisfied SQLOutboxPublisher.
+type SQLOutboxPublisherFunc func(ctx context.Context, event SQLOutboxEvent) (err error)
+
+func (fn SQLOutboxPublisherFunc) Publish(ctx context.Context, event SQLOutboxEvent) (err error) {
+	return fn(ctx, event)
+}
+
+type SQLOutboxConfiguration struct {
+	// Table of the outbox, default to outbox. The table should be created with
+	// the following columns.
+	//
+	//	CREATE TABLE outbox (
+	//	  id           bigserial PRIMARY KEY,
+	//	  topic        text NOT NULL,
+	//	  key          bytea,
+	//	  payload      bytea NOT NULL,
+	//	  headers      jsonb NOT NULL DEFAULT '{}',
+	//	  attempts     int NOT NULL DEFAULT 0,
+	//	  last_error   text,
+	//	  available_at timestamptz NOT NULL DEFAULT now(),
+	//	  created_at   timestamptz NOT NULL DEFAULT now(),
+	//	  published_at timestamptz,
+	//	  dead_at      timestamptz
+	//	);
+	Table string
+
+	// BatchSize of the events locked on each poll, default to 100.
+	BatchSize int
+
+	// PollInterval of the relay when the outbox is drained, default to 1s.
+	PollInterval time.Duration
+
+	// MaxAttempts before the event is dead-lettered, default to 10.
+	MaxAttempts int
+
+	// Backoff return the delay before the next attempt, default to exponential
+	// backoff starting from 1s up to 5m.
+	Backoff func(attempts int) time.Duration
+
+	// DeadLetter is published once the event is dead-lettered, optional.
+	DeadLetter SQLOutboxPublisher
+
+	// OnError is called on any error of the relay, optional.
+	OnError func(err error)
+}
+
+// SQLOutbox store the events in the same transaction of a command, and relay
+// them into a SQLOutboxPublisher, at-least-once.
+//
+//	outbox, _ := new(sdk.SQL).NewOutbox(&sdk.SQLOutboxConfiguration{Table: "core.outbox"})
+//
+//	tx, err := conn.BeginTx(ctx, nil)
+//	...
+//	defer func() { err = new(sdk.SQL).EndTx(tx, err) }()
+//	_, err = tx.ExecContext(ctx, cqrs.SQL_command_core_new_user, id, email)
+//	err = outbox.Add(ctx, tx, "core.user.created", id, UserCreated{ID: id, Email: email})
+//
+//	go outbox.Relay(ctx, conn, publisher)
+type SQLOutbox struct {
+	cfg SQLOutboxConfiguration
+
+	queryInsert, querySelect, queryDone, queryRetry, queryDead string
+}
+
+// NewOutbox validate the configuration and prepare the queries of SQLOutbox.
+func (SQL) NewOutbox(c *SQLOutboxConfiguration) (*SQLOutbox, error) {
+	cfg := SQLOutboxConfiguration{
+		Table:        "outbox",
+		BatchSize:    100,
+		PollInterval: time.Second,
+		MaxAttempts:  10,
+		Backoff: func(attempts int) time.Duration {
+			if d := time.Second << uint(attempts); attempts < 20 && d < 5*time.Minute {
+				return d
+			}
+
+			return 5 * time.Minute
+		},
+	}
+	if c != nil {
+		if c.Table != "" {
+			cfg.Table = c.Table
+		}
+
+		if c.BatchSize > 0 {
+			cfg.BatchSize = c.BatchSize
+		}
+
+		if c.PollInterval > 0 {
+			cfg.PollInterval = c.PollInterval
+		}
+
+		if c.MaxAttempts > 0 {
+			cfg.MaxAttempts = c.MaxAttempts
+		}
+
+		if c.Backoff != nil {
+			cfg.Backoff = c.Backoff
+		}
+
+		cfg.DeadLetter, cfg.OnError = c.DeadLetter, c.OnError
+	}
+
+	if !sqlIdentifier.MatchString(cfg.Table) {
+		return nil, fmt.Errorf("database: outbox: %w: %q", ErrOutboxInvalidTable, cfg.Table)
+	}
+
+	t := cfg.Table
+
+	return &SQLOutbox{
+		cfg:         cfg,
+		queryInsert: "INSERT INTO " + t + " (topic, key, payload, headers) VALUES ($1, $2, $3, $4)",
+		querySelect: "SELECT id, topic, key, payload, headers, attempts, created_at FROM " + t +
+			" WHERE published_at IS NULL AND dead_at IS NULL AND available_at <= now()" +
+			" ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED",
+		queryDone:  "UPDATE " + t + " SET published_at = now(), attempts = attempts + 1 WHERE id = $1",
+		queryRetry: "UPDATE " + t + " SET attempts = attempts + 1, last_error = $2, available_at = $3 WHERE id = $1",
+		queryDead:  "UPDATE " + t + " SET attempts = attempts + 1, last_error = $2, dead_at = now() WHERE id = $1",
+	}, nil
+}
+
+// Add insert an event using tx, it should be the same transaction of the
+// command. A payload other than []byte is marshaled using JSON, and the trace
+// context of ctx is carried in the headers.
+func (o *SQLOutbox) Add(ctx context.Context, tx ExecContext, topic string, key []byte, payload interface{}) error {
+	p, ok := payload.([]byte)
+	if !ok {
+		var err error
+		if p, err = JSON.Marshal(payload); err != nil {
+			return fmt.Errorf("database: outbox: %w", err)
+		}
+	}
+
+	headers := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, headers)
+
+	h, err := JSON.Marshal(headers)
+	if err != nil {
+		return fmt.Errorf("database: outbox: %w", err)
+	}
+
+	if _, err = tx.ExecContext(ctx, o.queryInsert, topic, key, p, string(h)); err != nil {
+		return fmt.Errorf("database: outbox: %w", err)
+	}
+
+	return nil
+}
+
+// Relay poll the outbox and publish the events until ctx is done, the next
+// poll is immediate when the last batch is full.
+func (o *SQLOutbox) Relay(ctx context.Context, conn BeginTx, pub SQLOutboxPublisher) error {
+	if pub == nil {
+		return fmt.Errorf("database: outbox: %w", ErrOutboxNoPublisher)
+	}
+
+	t := time.NewTimer(0)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+		}
+
+		n, err := o.RelayOnce(ctx, conn, pub)
+		if err != nil && o.cfg.OnError != nil && ctx.Err() == nil {
+			o.cfg.OnError(err)
+		}
+
+		if n >= o.cfg.BatchSize {
+			t.Reset(0)
+		} else {
+			t.Reset(o.cfg.PollInterval)
+		}
+	}
+}
+
+// RelayOnce lock a batch of events with `FOR UPDATE SKIP LOCKED`, so that
+// multiple relays could run concurrently, then publish and mark each of them.
+// It returns the number of events locked.
+func (o *SQLOutbox) RelayOnce(ctx context.Context, conn BeginTx, pub SQLOutboxPublisher) (n int, err error) {
+	if pub == nil {
+		return 0, fmt.Errorf("database: outbox: %w", ErrOutboxNoPublisher)
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("database: outbox: %w", err)
+	}
+
+	// a failed publish is not rolled back, as the attempt is recorded in tx
+	perrs := new(ListError)
+	defer func() {
+		if err == nil {
+			err = perrs.Err()
+		}
+	}()
+	defer func() { err = SQL{}.EndTx(tx, err) }()
+
+	events := []SQLOutboxEvent{}
+	headers := [][]byte{}
+	err = SQL{}.BoxQuery(tx.QueryContext(ctx, o.querySelect, o.cfg.BatchSize)).Scan(func(i int) List {
+		events, headers = append(events, SQLOutboxEvent{}), append(headers, nil)
+		e := &events[i]
+
+		return List{&e.ID, &e.Topic, &e.Key, &e.Payload, &headers[i], &e.Attempts, &e.CreatedAt}
+	})
+	if err != nil {
+		return 0, fmt.Errorf("database: outbox: %w", err)
+	}
+
+	for i := range events {
+		events[i].Headers = map[string]string{}
+		_ = JSON.Unmarshal(headers[i], &events[i].Headers)
+
+		var perr error
+		if perr, err = o.publish(ctx, tx, pub, events[i]); err != nil {
+			return 0, fmt.Errorf("database: outbox: %w", err)
+		}
+
+		perrs = perrs.Add(perr)
+	}
+
+	return len(events), nil
+}
+
+// publish return the error of the publisher and the error of marking the event.
+func (o *SQLOutbox) publish(ctx context.Context, tx ExecContext, pub SQLOutboxPublisher, e SQLOutboxEvent) (perr, err error) {
+	tracer := otel.Tracer("github.com/gunawanwijaya/forest/sdk")
+	if t, ok := ctx.Value(tracerCtxKey{}).(*Tracer); ok && t != nil && t.Tracer != nil {
+		tracer = t.Tracer
+	}
+
+	// continue the trace of the command, and carry the span of the relay
+	pctx := otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(e.Headers))
+	pctx, span := tracer.Start(pctx, "outbox publish "+e.Topic,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.destination.name", e.Topic),
+			attribute.Int64("messaging.message.id", e.ID),
+			attribute.Int("outbox.attempts", e.Attempts+1),
+		),
+	)
+	defer span.End()
+
+	headers := make(map[string]string, len(e.Headers))
+	for k, v := range e.Headers {
+		headers[k] = v
+	}
+
+	otel.GetTextMapPropagator().Inject(pctx, propagation.MapCarrier(headers))
+	e.Headers = headers
+
+	if perr = pub.Publish(pctx, e); perr == nil {
+		_, err = tx.ExecContext(ctx, o.queryDone, e.ID)
+
+		return nil, err
+	}
+
+	span.RecordError(perr)
+	span.SetStatus(codes.Error, perr.Error())
+
+	if e.Attempts+1 < o.cfg.MaxAttempts {
+		_, err = tx.ExecContext(ctx, o.queryRetry, e.ID, perr.Error(), time.Now().Add(o.cfg.Backoff(e.Attempts+1)))
+
+		return fmt.Errorf("database: outbox: publish %d: %w", e.ID, perr), err
+	}
+
+	if o.cfg.DeadLetter != nil {
+		if derr := o.cfg.DeadLetter.Publish(pctx, e); derr != nil {
+			// keep the event to be retried, as it is not dead-lettered yet
+			_, err = tx.ExecContext(ctx, o.queryRetry, e.ID, derr.Error(), time.Now().Add(o.cfg.Backoff(e.Attempts+1)))
+
+			return fmt.Errorf("database: outbox: dead letter %d: %w", e.ID, derr), err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, o.queryDead, e.ID, perr.Error())
+
+	return fmt.Errorf("database: outbox: dead %d: %w", e.ID, perr), err
+}
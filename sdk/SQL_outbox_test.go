@@ -0,0 +1,145 @@
+package sdk_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/gunawanwijaya/forest/sdk"
+	. "github.com/onsi/gomega"
+	sdk_trace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func test_SQLOutbox(t *testing.T) {
+	t.Parallel()
+
+	Expect := NewWithT(t).Expect
+	ctx := context.Background()
+	errX := errors.New("x")
+	columns := []string{"id", "topic", "key", "payload", "headers", "attempts", "created_at"}
+
+	t.Run("config", func(t *testing.T) {
+		_, err := new(SQL).NewOutbox(&SQLOutboxConfiguration{Table: "core.outbox; DROP TABLE x"})
+		Expect(err).To(MatchError(ErrOutboxInvalidTable))
+
+		o, err := new(SQL).NewOutbox(nil)
+		Expect(err).To(Succeed())
+
+		_, err = o.RelayOnce(ctx, new(SQL).NewMock(ctx, 0), nil)
+		Expect(err).To(MatchError(ErrOutboxNoPublisher))
+	})
+	t.Run("add", func(t *testing.T) {
+		mock := new(SQL).NewMock(ctx, 0)
+		defer mock.Close()
+
+		o, err := new(SQL).NewOutbox(&SQLOutboxConfiguration{Table: "core.outbox"})
+		Expect(err).To(Succeed())
+
+		mock.ExpectExec(`INSERT INTO core\.outbox \(topic, key, payload, headers\)`).
+			WithArgs("core.user.created", []byte{1}, []byte(`{"id":1}`), "{}").WillReturnResult(1, 1)
+		Expect(o.Add(ctx, mock, "core.user.created", []byte{1}, map[string]int{"id": 1})).To(Succeed())
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+	})
+	t.Run("relay", func(t *testing.T) {
+		mock := new(SQL).NewMock(ctx, 0)
+		defer mock.Close()
+
+		o, err := new(SQL).NewOutbox(&SQLOutboxConfiguration{Table: "core.outbox", MaxAttempts: 3})
+		Expect(err).To(Succeed())
+
+		now := time.Now()
+		mock.ExpectBegin()
+		mock.ExpectQuery(`FROM core\.outbox .* FOR UPDATE SKIP LOCKED`).WithArgs(100).WillReturnRows(columns,
+			[]interface{}{1, "a", nil, []byte("1"), "{}", 0, now},
+			[]interface{}{2, "b", nil, []byte("2"), "{}", 0, now},
+			[]interface{}{3, "b", nil, []byte("3"), "{}", 2, now},
+		)
+		mock.ExpectExec(`SET published_at = now\(\)`).WithArgs(1).WillReturnResult(0, 1)
+		mock.ExpectExec(`SET attempts = attempts \+ 1, last_error = \$2, available_at`).
+			WithArgs(2, "x", SQLMockAnyArg()).WillReturnResult(0, 1)
+		mock.ExpectExec(`SET attempts = attempts \+ 1, last_error = \$2, dead_at`).
+			WithArgs(3, "x").WillReturnResult(0, 1)
+		mock.ExpectCommit()
+
+		published := []string{}
+		n, err := o.RelayOnce(ctx, mock, SQLOutboxPublisherFunc(func(ctx context.Context, e SQLOutboxEvent) error {
+			if e.Topic == "b" {
+				return errX
+			}
+
+			published = append(published, string(e.Payload))
+
+			return nil
+		}))
+		Expect(n).To(Equal(3))
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("publish 2"))
+		Expect(err.Error()).To(ContainSubstring("dead 3"))
+		Expect(published).To(Equal([]string{"1"}))
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+	})
+	t.Run("trace-context", func(t *testing.T) {
+		mock := new(SQL).NewMock(ctx, 0)
+		defer mock.Close()
+
+		// NewTracer set the global propagator of W3C trace context
+		exp := tracetest.NewInMemoryExporter()
+		tr, err := OTel.NewTracer(ctx, &TracerConfiguration{Name: "test", Exporters: []sdk_trace.SpanExporter{exp}})
+		Expect(err).To(Succeed())
+
+		defer func() { Expect(tr.Shutdown(ctx)).To(Succeed()) }()
+
+		o, err := new(SQL).NewOutbox(nil)
+		Expect(err).To(Succeed())
+
+		tctx := tr.WithContext(ctx)
+		sctx, span := tr.Start(tctx, "command")
+		span.End()
+		Expect(span.SpanContext().IsSampled()).To(BeTrue())
+
+		// the span of the command is written into the headers at Add
+		var headers string
+		mock.ExpectExec(`^INSERT INTO outbox`).WithArgs("a", SQLMockAnyArg(), []byte("1"), outboxArg(func(v interface{}) bool {
+			headers, _ = v.(string)
+			return true
+		})).WillReturnResult(1, 1)
+		Expect(o.Add(sctx, mock, "a", nil, []byte("1"))).To(Succeed())
+		Expect(headers).To(ContainSubstring(`"traceparent":"00-` +
+			span.SpanContext().TraceID().String() + "-" + span.SpanContext().SpanID().String() + `-01"`))
+
+		// and restored by the relay, as the parent of the publish span
+		mock.ExpectBegin()
+		mock.ExpectQuery(`FOR UPDATE SKIP LOCKED`).WillReturnRows(columns, []interface{}{1, "a", nil, []byte("1"), headers, 0, time.Now()})
+		mock.ExpectExec(`SET published_at = now\(\)`).WithArgs(1).WillReturnResult(0, 1)
+		mock.ExpectCommit()
+
+		var published trace.SpanContext
+		n, err := o.RelayOnce(tctx, mock, SQLOutboxPublisherFunc(func(ctx context.Context, e SQLOutboxEvent) error {
+			published = trace.SpanContextFromContext(ctx)
+			Expect(e.Headers).To(HaveKeyWithValue("traceparent", ContainSubstring(published.SpanID().String())))
+
+			return nil
+		}))
+		Expect(err).To(Succeed())
+		Expect(n).To(Equal(1))
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+		Expect(published.TraceID()).To(Equal(span.SpanContext().TraceID()))
+
+		Expect(tr.ForceFlush(ctx)).To(Succeed())
+
+		spans := exp.GetSpans()
+		Expect(spans).To(HaveLen(2))
+		Expect(spans[1].Name).To(Equal("outbox publish a"))
+		Expect(spans[1].SpanContext.SpanID()).To(Equal(published.SpanID()))
+		Expect(spans[1].Parent.SpanID()).To(Equal(span.SpanContext().SpanID()))
+		Expect(spans[1].SpanKind).To(Equal(trace.SpanKindProducer))
+	})
+}
+
+// outboxArg is a SQLMockArg of fn.
+type outboxArg func(v interface{}) bool
+
+func (fn outboxArg) Match(v interface{}) bool { return fn(v) }
@@ -0,0 +1,377 @@
+package sdk
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// nolint: gochecknoglobals
+var ErrExplainQueueFull = errors.New("Explain queue is full")
+
+type SQLSlowLogConfiguration struct {
+	// Threshold of the latency to be logged, default to 200ms.
+	Threshold time.Duration
+
+	// SampleRate is the fraction (0, 1] of slow queries to be logged, default to 1.
+	SampleRate float64
+
+	// Limit the number of logs per Interval (default to 1s), default to 10.
+	// The suppressed logs are counted on the next log as `suppressed`.
+	Limit    int
+	Interval time.Duration
+
+	// Explain will run `EXPLAIN (FORMAT JSON)` of the slow statement on
+	// ExplainConn (default to the wrapped conn routed with SQL.WithReplica) and
+	// attach the plan as `plan`, only for PostgreSQL. The plan is captured by a
+	// background worker, so that the query is not delayed further; the slow
+	// queries over ExplainQueue (default to 16) are logged without the plan.
+	Explain        bool
+	ExplainConn    QueryRowContext
+	ExplainTimeout time.Duration
+	ExplainQueue   int
+
+	// Logger is used when there is no *Logger in the context of the query.
+	Logger *Logger
+}
+
+// WithSlowLog will wrap conn so that the query which took longer than the
+// threshold is logged with its sanitized statement & arguments and the caller.
+//
+//	conn, err := new(sdk.SQL).WithSlowLog(ctx, conn, &sdk.SQLSlowLogConfiguration{
+//	  Threshold: 100 * time.Millisecond,
+//	  Explain:   true,
+//	})
+//
+// Only the statements of conn are logged, the *sql.Tx of BeginTx and the
+// *sql.Stmt of PrepareContext are returned as is, so a slow statement within a
+// transaction or of a prepared statement is not logged.
+func (SQL) WithSlowLog(ctx context.Context, conn SQLConn, c *SQLSlowLogConfiguration) (SQLConn, error) {
+	if conn == nil {
+		return nil, fmt.Errorf("database: slow log: %w", ErrInvalidDatabase)
+	}
+
+	cfg := SQLSlowLogConfiguration{
+		Threshold:      200 * time.Millisecond,
+		SampleRate:     1,
+		Limit:          10,
+		Interval:       time.Second,
+		ExplainTimeout: time.Second,
+		ExplainQueue:   16,
+	}
+	if c != nil {
+		if c.Threshold > 0 {
+			cfg.Threshold = c.Threshold
+		}
+
+		if c.SampleRate > 0 && c.SampleRate < 1 {
+			cfg.SampleRate = c.SampleRate
+		}
+
+		if c.Limit > 0 {
+			cfg.Limit = c.Limit
+		}
+
+		if c.Interval > 0 {
+			cfg.Interval = c.Interval
+		}
+
+		if c.ExplainTimeout > 0 {
+			cfg.ExplainTimeout = c.ExplainTimeout
+		}
+
+		if c.ExplainQueue > 0 {
+			cfg.ExplainQueue = c.ExplainQueue
+		}
+
+		cfg.Explain, cfg.ExplainConn, cfg.Logger = c.Explain, c.ExplainConn, c.Logger
+	}
+
+	if cfg.Logger == nil {
+		cfg.Logger, _ = ctx.Value(loggerCtxKey{}).(*Logger)
+	}
+
+	if cfg.ExplainConn == nil {
+		cfg.ExplainConn = conn
+	}
+
+	x := &sqlSlowLog{SQLConn: conn, cfg: cfg, mu: new(sync.Mutex), closeOnce: new(sync.Once)}
+	if cfg.Explain {
+		x.explains, x.done, x.wg = make(chan sqlSlowLogExplain, cfg.ExplainQueue), make(chan struct{}), new(sync.WaitGroup)
+		x.wg.Add(1)
+
+		go x.explainWorker()
+	}
+
+	return x, nil
+}
+
+type sqlSlowLog struct {
+	SQLConn
+	cfg SQLSlowLogConfiguration
+
+	mu         *sync.Mutex
+	window     time.Time
+	count      int
+	suppressed int
+
+	// the slow queries waiting for their plan, see explainWorker
+	explains  chan sqlSlowLogExplain
+	done      chan struct{}
+	wg        *sync.WaitGroup
+	closeOnce *sync.Once
+}
+
+// sqlSlowLogExplain is a slow query log waiting for its plan.
+type sqlSlowLogExplain struct {
+	ctx   context.Context
+	e     *zerolog.Event
+	query string
+	args  []interface{}
+}
+
+func (x *sqlSlowLog) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	begin := time.Now()
+	res, err := x.SQLConn.ExecContext(ctx, query, args...)
+	x.log(ctx, begin, query, args, err)
+
+	return res, err
+}
+
+func (x *sqlSlowLog) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	begin := time.Now()
+	rows, err := x.SQLConn.QueryContext(ctx, query, args...)
+	x.log(ctx, begin, query, args, err)
+
+	return rows, err
+}
+
+func (x *sqlSlowLog) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	begin := time.Now()
+	row := x.SQLConn.QueryRowContext(ctx, query, args...)
+
+	var err error
+	if row != nil {
+		err = row.Err()
+	}
+
+	x.log(ctx, begin, query, args, err)
+
+	return row
+}
+
+// Close will stop the explain worker, the queued logs are written without the
+// plan, and then close the wrapped conn.
+func (x *sqlSlowLog) Close() error {
+	x.closeOnce.Do(func() {
+		if x.done != nil {
+			close(x.done)
+			x.wg.Wait()
+		}
+	})
+
+	return x.SQLConn.Close()
+}
+
+// Conn will return a dedicated connection when the wrapped conn is able to.
+func (x *sqlSlowLog) Conn(ctx context.Context) (*sql.Conn, error) {
+	c, ok := x.SQLConn.(interface {
+		Conn(ctx context.Context) (*sql.Conn, error)
+	})
+	if !ok {
+		return nil, ErrInvalidDatabase
+	}
+
+	return c.Conn(ctx)
+}
+
+func (x *sqlSlowLog) log(ctx context.Context, begin time.Time, query string, args []interface{}, err error) {
+	elapsed := time.Since(begin)
+	if elapsed < x.cfg.Threshold {
+		return
+	}
+
+	l, ok := ctx.Value(loggerCtxKey{}).(*Logger)
+	if !ok || l == nil {
+		l = x.cfg.Logger
+	}
+
+	if l == nil || l.Z() == nil {
+		return
+	}
+
+	suppressed, ok := x.allow()
+	if !ok {
+		return
+	}
+
+	e := l.Z().Warn().
+		Dur("elapsed", elapsed).
+		Dur("threshold", x.cfg.Threshold).
+		Str("statement", SQL{}.SanitizeQuery(query)).
+		Strs("args", sqlSlowLogArgs(args)).
+		Str("caller", sqlSlowLogCaller())
+
+	if name, ok := (SQL{}).QueryName(query); ok {
+		e = e.Str("name", name)
+	}
+
+	if suppressed > 0 {
+		e = e.Int("suppressed", suppressed)
+	}
+
+	if err != nil {
+		e = e.Err(err)
+	}
+
+	if x.cfg.Explain {
+		select {
+		case <-x.done:
+			e = e.AnErr("explain_error", fmt.Errorf("database: explain: %w", ErrAlreadyClosed))
+		default:
+			select {
+			case x.explains <- sqlSlowLogExplain{context.WithoutCancel(ctx), e, query, append([]interface{}{}, args...)}:
+				return
+			default:
+				e = e.AnErr("explain_error", fmt.Errorf("database: explain: %w", ErrExplainQueueFull))
+			}
+		}
+	}
+
+	e.Msg("slow query")
+}
+
+// explainWorker capture the plan of the queued logs one at a time, so that at
+// most one EXPLAIN is in flight.
+func (x *sqlSlowLog) explainWorker() {
+	defer x.wg.Done()
+
+	for {
+		select {
+		case j := <-x.explains:
+			if plan, err := x.explain(j.ctx, j.query, j.args); err != nil {
+				j.e.AnErr("explain_error", err).Msg("slow query")
+			} else {
+				j.e.RawJSON("plan", plan).Msg("slow query")
+			}
+		case <-x.done:
+			for {
+				select {
+				case j := <-x.explains:
+					j.e.AnErr("explain_error", fmt.Errorf("database: explain: %w", ErrAlreadyClosed)).Msg("slow query")
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// allow apply the sampling & rate limit, it returns the number of logs that
+// were suppressed by the rate limit since the last allowed log.
+func (x *sqlSlowLog) allow() (suppressed int, ok bool) {
+	if x.cfg.SampleRate < 1 && rand.Float64() >= x.cfg.SampleRate { // nolint: gosec
+		return 0, false
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if now := time.Now(); now.Sub(x.window) >= x.cfg.Interval {
+		x.window, x.count = now, 0
+	}
+
+	if x.count >= x.cfg.Limit {
+		x.suppressed++
+
+		return 0, false
+	}
+
+	x.count++
+	suppressed, x.suppressed = x.suppressed, 0
+
+	return suppressed, true
+}
+
+// explain will not run the statement (no ANALYZE) and is detached from the
+// cancellation of ctx, as ctx may already be done when the query is slow.
+func (x *sqlSlowLog) explain(ctx context.Context, query string, args []interface{}) ([]byte, error) {
+	query = SQL{}.RemoveComment(query)
+	if !(SQL{}).IsValidCommand(query) {
+		return nil, fmt.Errorf("database: explain: %w", ErrInvalidCommand)
+	}
+
+	switch op := strings.ToUpper(strings.SplitN(query+" ", " ", 2)[0]); op {
+	case "SELECT", "INSERT", "UPDATE", "DELETE", "WITH", "VALUES":
+	default:
+		return nil, fmt.Errorf("database: explain: %w: %s", ErrInvalidCommand, op)
+	}
+
+	ctx, cancel := context.WithTimeout(SQL{}.WithReplica(context.WithoutCancel(ctx)), x.cfg.ExplainTimeout)
+	defer cancel()
+
+	row := x.cfg.ExplainConn.QueryRowContext(ctx, "EXPLAIN (FORMAT JSON) "+query, args...)
+	if row == nil {
+		return nil, fmt.Errorf("database: explain: %w", ErrNoResult)
+	}
+
+	var plan []byte
+	if err := row.Scan(&plan); err != nil {
+		return nil, fmt.Errorf("database: explain: %w", err)
+	}
+
+	return plan, nil
+}
+
+// sqlSlowLogArgs only keep the type of the argument and the length of a string
+// or bytes, the value of a number, bool, time and nil are kept as is.
+func sqlSlowLogArgs(args []interface{}) []string {
+	s := make([]string, len(args))
+
+	for i, arg := range args {
+		switch v := arg.(type) {
+		case nil:
+			s[i] = "NULL"
+		case bool:
+			s[i] = strconv.FormatBool(v)
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+			s[i] = fmt.Sprint(v)
+		case time.Time:
+			s[i] = v.Format(time.RFC3339Nano)
+		case string:
+			s[i] = "string(len=" + strconv.Itoa(len(v)) + ")"
+		case []byte:
+			s[i] = "[]byte(len=" + strconv.Itoa(len(v)) + ")"
+		default:
+			s[i] = fmt.Sprintf("%T", v)
+		}
+	}
+
+	return s
+}
+
+// sqlSlowLogCaller return the first caller outside of this package.
+func sqlSlowLogCaller() string {
+	pc := make([]uintptr, 16)
+	frames := runtime.CallersFrames(pc[:runtime.Callers(3, pc)])
+
+	for {
+		f, more := frames.Next()
+		if !strings.HasPrefix(f.Function, "github.com/gunawanwijaya/forest/sdk.") && f.Function != "" {
+			return f.File + ":" + strconv.Itoa(f.Line)
+		}
+
+		if !more {
+			return ""
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package sdk_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/gunawanwijaya/forest/sdk"
+	. "github.com/onsi/gomega"
+)
+
+func test_SQLSlowLog(t *testing.T) {
+	t.Parallel()
+
+	g := NewWithT(t)
+	Expect := g.Expect
+	ctx := context.Background()
+
+	t.Run("threshold", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		conn, err := new(SQL).WithSlowLog(ctx, new(countConn), &SQLSlowLogConfiguration{
+			Threshold: time.Hour,
+			Logger:    OTel.NewLogger(ctx, buf),
+		})
+		Expect(err).To(Succeed())
+
+		_, _ = conn.ExecContext(ctx, "DELETE FROM t")
+		Expect(buf.String()).To(BeEmpty())
+	})
+	t.Run("log", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		conn, err := new(SQL).WithSlowLog(ctx, new(countConn), &SQLSlowLogConfiguration{
+			Threshold: time.Nanosecond,
+			Limit:     1,
+			Interval:  50 * time.Millisecond,
+			Logger:    OTel.NewLogger(ctx, buf),
+		})
+		Expect(err).To(Succeed())
+
+		_, _ = conn.ExecContext(ctx, "UPDATE t SET a = 'secret' WHERE b = $1 AND c = $2", "secret", 42)
+		Expect(buf.String()).To(ContainSubstring(`"message":"slow query"`))
+		Expect(buf.String()).To(ContainSubstring(`"statement":"UPDATE t SET a = ? WHERE b = $1 AND c = $2"`))
+		Expect(buf.String()).To(ContainSubstring(`"args":["string(len=6)","42"]`))
+		Expect(buf.String()).To(ContainSubstring(`SQL_slowlog_test.go`))
+		Expect(buf.String()).NotTo(ContainSubstring(`"secret"`))
+
+		buf.Reset()
+		_, _ = conn.QueryContext(ctx, "SELECT 1")
+		_, _ = conn.QueryContext(ctx, "SELECT 1")
+		Expect(buf.String()).To(BeEmpty())
+
+		// the suppressed logs are counted on the first log of the next interval
+		g.Eventually(func() string {
+			_, _ = conn.QueryContext(ctx, "SELECT 1")
+			return buf.String()
+		}, time.Second, 10*time.Millisecond).Should(MatchRegexp(`"suppressed":\d+`))
+	})
+	t.Run("explain", func(t *testing.T) {
+		buf := new(syncBuffer)
+		mock := new(SQL).NewMock(ctx, 1)
+		defer mock.Close()
+
+		conn, err := new(SQL).WithSlowLog(ctx, mock, &SQLSlowLogConfiguration{
+			Threshold: time.Nanosecond,
+			Explain:   true,
+			Logger:    OTel.NewLogger(ctx, buf),
+		})
+		Expect(err).To(Succeed())
+
+		columns := []string{"QUERY PLAN"}
+		mock.ExpectQuery(`^SELECT id FROM t WHERE a = \$1$`).WithArgs(int64(1)).OnReplica().
+			WillReturnRows([]string{"id"}, []interface{}{int64(1)})
+		mock.ExpectQuery(`^EXPLAIN \(FORMAT JSON\) SELECT id FROM t WHERE a = \$1$`).WithArgs(int64(1)).OnReplica().
+			WillDelay(50*time.Millisecond).
+			WillReturnRows(columns, []interface{}{[]byte(`[{"Plan":{"Node Type":"Seq Scan"}}]`)})
+		// the plan of a DML is read from the replica, EXPLAIN never run the statement
+		mock.ExpectExec(`^UPDATE t SET a = \$1$`).WithArgs(int64(2)).OnPrimary().WillReturnResult(0, 1)
+		mock.ExpectQuery(`^EXPLAIN \(FORMAT JSON\) UPDATE t SET a = \$1$`).WithArgs(int64(2)).OnReplica().
+			WillReturnRows(columns, []interface{}{[]byte(`[{"Plan":{"Node Type":"ModifyTable"}}]`)})
+
+		rows, err := conn.QueryContext(ctx, "SELECT id FROM t WHERE a = $1", 1)
+		Expect(err).To(Succeed())
+		Expect(rows.Close()).To(Succeed())
+
+		// the query is not delayed by the EXPLAIN, the log is written by the worker
+		Expect(buf.String()).To(BeEmpty())
+		g.Eventually(buf.String, time.Second).Should(ContainSubstring(`"plan":[{"Plan":{"Node Type":"Seq Scan"}}]`))
+		Expect(buf.String()).To(ContainSubstring(`SQL_slowlog_test.go`))
+
+		buf.Reset()
+		_, err = conn.ExecContext(ctx, "UPDATE t SET a = $1", 2)
+		Expect(err).To(Succeed())
+		g.Eventually(buf.String, time.Second).Should(ContainSubstring(`"plan":[{"Plan":{"Node Type":"ModifyTable"}}]`))
+		Expect(buf.String()).NotTo(ContainSubstring("explain_error"))
+		Expect(conn.Close()).To(Succeed())
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+	})
+	t.Run("explain-queue", func(t *testing.T) {
+		buf := new(syncBuffer)
+		explain := new(SQL).NewMock(ctx, 0)
+		defer explain.Close()
+
+		explain.ExpectQuery(`^EXPLAIN`).WillDelay(100*time.Millisecond).
+			WillReturnRows([]string{"QUERY PLAN"}, []interface{}{[]byte(`[]`)})
+
+		conn, err := new(SQL).WithSlowLog(ctx, new(countConn), &SQLSlowLogConfiguration{
+			Threshold:    time.Nanosecond,
+			Explain:      true,
+			ExplainConn:  explain,
+			ExplainQueue: 1,
+			Logger:       OTel.NewLogger(ctx, buf),
+		})
+		Expect(err).To(Succeed())
+
+		// a slow query over the queue is logged right away without the plan
+		for i := 0; i < 3; i++ {
+			_, _ = conn.ExecContext(ctx, "DELETE FROM t")
+		}
+
+		g.Eventually(buf.String, time.Second).Should(ContainSubstring(`"explain_error":"database: explain: Explain queue is full"`))
+
+		// the queued logs are written on Close
+		Expect(conn.Close()).To(Succeed())
+		Expect(strings.Count(buf.String(), `"message":"slow query"`)).To(Equal(3))
+	})
+}
@@ -0,0 +1,372 @@
+package sdk
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// nolint: gochecknoglobals
+var sqlQueryNames sync.Map
+
+type sqlReplicaCtxKey struct{}
+
+type SQLTelemetryConfiguration struct {
+	// System is the value of `db.system`, default to postgresql.
+	System string
+
+	// Name of the tracer & meter instrumentation scope when they are not found
+	// in the context, default to the package path of sdk.
+	Name string
+}
+
+// RegisterQueryName will tag the query with name (e.g. the variable name of the
+// query in cqrs package), so that the span and metrics of SQL.WithTelemetry
+// could be grouped by name instead of the statement text.
+func (SQL) RegisterQueryName(name, query string) {
+	sqlQueryNames.Store(sqlQueryKey(query), name)
+
+	// the named parameters are rewritten before reaching SQLConn
+	if names := (SQL{}).NamedParameters(query); len(names) > 0 {
+		values := map[string]interface{}{}
+		for _, n := range names {
+			values[n] = nil
+		}
+
+		if q, _, err := (SQL{}).Named(SQLDialectPostgreSQL, query, values); err == nil {
+			sqlQueryNames.Store(sqlQueryKey(q), name)
+		}
+	}
+}
+
+// QueryName return the name registered via SQL.RegisterQueryName.
+func (SQL) QueryName(query string) (name string, ok bool) {
+	v, ok := sqlQueryNames.Load(sqlQueryKey(query))
+	if !ok {
+		return "", false
+	}
+
+	return v.(string), true
+}
+
+func sqlQueryKey(query string) string {
+	return strings.Join(strings.Fields(SQL{}.RemoveComment(query)), " ")
+}
+
+// SanitizeQuery will replace every literal string and number of query with `?`
+// and remove the comments, so that the statement is safe to be recorded.
+func (SQL) SanitizeQuery(query string) string {
+	query = SQL{}.RemoveComment(query)
+	b := new(strings.Builder)
+	isIdent := func(c byte) bool {
+		return c == '_' || c == '$' || c == '.' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+	}
+
+	for i := 0; i < len(query); i++ {
+		switch c := query[i]; {
+		case c == '\'':
+			for i++; i < len(query); i++ {
+				if query[i] == '\'' && (i+1 >= len(query) || query[i+1] != '\'') {
+					break
+				} else if query[i] == '\'' {
+					i++
+				}
+			}
+
+			_ = b.WriteByte('?')
+		case c >= '0' && c <= '9' && (i == 0 || !isIdent(query[i-1])):
+			for i+1 < len(query) && (isIdent(query[i+1]) && query[i+1] != '$') {
+				i++
+			}
+
+			_ = b.WriteByte('?')
+		default:
+			_ = b.WriteByte(c)
+		}
+	}
+
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// WithTelemetry will wrap conn (including SQL.NewRoundRobin) so that each call
+// produce a client span and the duration metrics, the pool statistics of
+// *sql.DB are published as gauges. The tracer and meter are taken from ctx
+// (see Tracer.WithContext and Meter.WithContext) or the global provider.
+//
+//	conn, err := new(sdk.SQL).WithTelemetry(ctx, new(sdk.SQL).NewRoundRobin(ctx, conns...), nil)
+func (SQL) WithTelemetry(ctx context.Context, conn SQLConn, c *SQLTelemetryConfiguration) (SQLConn, error) {
+	t, err := newSQLTelemetry(ctx, conn, c)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sqlTelemetryConn{t, conn}, nil
+}
+
+// WithTxTelemetry is SQL.WithTelemetry for *sql.Tx or any SQLTxConn.
+func (SQL) WithTxTelemetry(ctx context.Context, tx SQLTxConn, c *SQLTelemetryConfiguration) (SQLTxConn, error) {
+	t, err := newSQLTelemetry(ctx, nil, c)
+	if err != nil {
+		return nil, err
+	}
+
+	t.SQLTxConn = tx
+
+	return t, nil
+}
+
+type sqlTelemetry struct {
+	SQLTxConn
+
+	system   string
+	tracer   trace.Tracer
+	duration metric.Float64Histogram
+	errors   metric.Int64Counter
+	reg      metric.Registration
+}
+
+func newSQLTelemetry(ctx context.Context, conn SQLConn, c *SQLTelemetryConfiguration) (t *sqlTelemetry, err error) {
+	cfg := SQLTelemetryConfiguration{"postgresql", "github.com/gunawanwijaya/forest/sdk"}
+	if c != nil && c.System != "" {
+		cfg.System = c.System
+	}
+
+	if c != nil && c.Name != "" {
+		cfg.Name = c.Name
+	}
+
+	t = &sqlTelemetry{SQLTxConn: conn, system: cfg.System, tracer: otel.Tracer(cfg.Name)}
+	if tr, ok := ctx.Value(tracerCtxKey{}).(*Tracer); ok && tr != nil && tr.Tracer != nil {
+		t.tracer = tr.Tracer
+	}
+
+	meter := otel.Meter(cfg.Name)
+	if m, ok := ctx.Value(meterCtxKey{}).(*Meter); ok && m != nil && m.Meter != nil {
+		meter = m.Meter
+	}
+
+	if t.duration, err = meter.Float64Histogram("db.client.operation.duration",
+		metric.WithUnit("s"),
+		metric.WithDescription("Duration of database client operations."),
+	); err != nil {
+		return nil, err
+	}
+
+	if t.errors, err = meter.Int64Counter("db.client.operation.errors",
+		metric.WithUnit("{error}"),
+		metric.WithDescription("Number of failed database client operations."),
+	); err != nil {
+		return nil, err
+	}
+
+	if conn != nil {
+		if t.reg, err = t.observeStats(meter, conn); err != nil {
+			return nil, err
+		}
+	}
+
+	return t, nil
+}
+
+// observeStats publish sql.DBStats of *sql.DB or each *sql.DB of round-robin.
+func (t *sqlTelemetry) observeStats(meter metric.Meter, conn SQLConn) (metric.Registration, error) {
+	stats := func() map[int]sql.DBStats { return nil }
+
+	switch x := conn.(type) {
+	case interface{ stats() map[int]sql.DBStats }:
+		stats = x.stats
+	case interface{ Stats() sql.DBStats }:
+		stats = func() map[int]sql.DBStats { return map[int]sql.DBStats{0: x.Stats()} }
+	default:
+		return nil, nil
+	}
+
+	gauge := func(name, desc string) (metric.Int64ObservableGauge, error) {
+		return meter.Int64ObservableGauge(name, metric.WithUnit("{connection}"), metric.WithDescription(desc))
+	}
+
+	open, err := gauge("db.client.connections.open", "Number of established connections.")
+	if err != nil {
+		return nil, err
+	}
+
+	inUse, err := gauge("db.client.connections.in_use", "Number of connections currently in use.")
+	if err != nil {
+		return nil, err
+	}
+
+	idle, err := gauge("db.client.connections.idle", "Number of idle connections.")
+	if err != nil {
+		return nil, err
+	}
+
+	maxOpen, err := gauge("db.client.connections.max", "Maximum number of open connections.")
+	if err != nil {
+		return nil, err
+	}
+
+	waitCount, err := meter.Int64ObservableCounter("db.client.connections.wait_count",
+		metric.WithUnit("{wait}"), metric.WithDescription("Total number of connections waited for."))
+	if err != nil {
+		return nil, err
+	}
+
+	waitDuration, err := meter.Float64ObservableCounter("db.client.connections.wait_duration",
+		metric.WithUnit("s"), metric.WithDescription("Total time blocked waiting for a new connection."))
+	if err != nil {
+		return nil, err
+	}
+
+	return meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+		for i, s := range stats() {
+			attrs := metric.WithAttributes(
+				attribute.String("db.system", t.system),
+				attribute.Int("db.sql.replica", i),
+			)
+
+			o.ObserveInt64(open, int64(s.OpenConnections), attrs)
+			o.ObserveInt64(inUse, int64(s.InUse), attrs)
+			o.ObserveInt64(idle, int64(s.Idle), attrs)
+			o.ObserveInt64(maxOpen, int64(s.MaxOpenConnections), attrs)
+			o.ObserveInt64(waitCount, s.WaitCount, attrs)
+			o.ObserveFloat64(waitDuration, s.WaitDuration.Seconds(), attrs)
+		}
+
+		return nil
+	}, open, inUse, idle, maxOpen, waitCount, waitDuration)
+}
+
+// start a client span of query, the returned func should be called with the
+// error of the operation to end the span and record the metrics.
+func (t *sqlTelemetry) start(ctx context.Context, op, query string) (context.Context, func(error)) {
+	name, named := SQL{}.QueryName(query)
+	if op == "" {
+		op = strings.ToUpper(strings.SplitN(SQL{}.RemoveComment(query)+" ", " ", 2)[0])
+	}
+
+	attrs := []attribute.KeyValue{
+		attribute.String("db.system", t.system),
+		attribute.String("db.operation", op),
+	}
+	if query != "" {
+		attrs = append(attrs, attribute.String("db.statement", SQL{}.SanitizeQuery(query)))
+	}
+
+	spanName := op
+	if named {
+		spanName = op + " " + name
+		attrs = append(attrs, attribute.String("db.sql.name", name))
+	}
+
+	replica, begin := -1, time.Now()
+	ctx = context.WithValue(ctx, sqlReplicaCtxKey{}, &replica)
+	ctx, span := t.tracer.Start(ctx, spanName, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+
+	return ctx, func(err error) {
+		if replica > -1 {
+			span.SetAttributes(attribute.Int("db.sql.replica", replica))
+			attrs = append(attrs, attribute.Int("db.sql.replica", replica))
+		}
+
+		metricAttrs := []attribute.KeyValue{attrs[0], attrs[1]}
+		if named {
+			metricAttrs = append(metricAttrs, attribute.String("db.sql.name", name))
+		}
+
+		if replica > -1 {
+			metricAttrs = append(metricAttrs, attribute.Int("db.sql.replica", replica))
+		}
+
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			t.errors.Add(ctx, 1, metric.WithAttributes(metricAttrs...))
+			metricAttrs = append(metricAttrs, attribute.String("error.type", "error"))
+		}
+
+		t.duration.Record(ctx, time.Since(begin).Seconds(), metric.WithAttributes(metricAttrs...))
+		span.End()
+	}
+}
+
+func (t *sqlTelemetry) ExecContext(ctx context.Context, query string, args ...interface{}) (res sql.Result, err error) {
+	ctx, end := t.start(ctx, "", query)
+	defer func() { end(err) }()
+
+	return t.SQLTxConn.ExecContext(ctx, query, args...)
+}
+
+func (t *sqlTelemetry) PrepareContext(ctx context.Context, query string) (stmt *sql.Stmt, err error) {
+	ctx, end := t.start(ctx, "PREPARE", query)
+	defer func() { end(err) }()
+
+	return t.SQLTxConn.PrepareContext(ctx, query)
+}
+
+// QueryContext span is ended when the rows are returned, not when it is closed.
+func (t *sqlTelemetry) QueryContext(ctx context.Context, query string, args ...interface{}) (rows *sql.Rows, err error) {
+	ctx, end := t.start(ctx, "", query)
+	defer func() { end(err) }()
+
+	return t.SQLTxConn.QueryContext(ctx, query, args...)
+}
+
+func (t *sqlTelemetry) QueryRowContext(ctx context.Context, query string, args ...interface{}) (row *sql.Row) {
+	ctx, end := t.start(ctx, "", query)
+
+	row = t.SQLTxConn.QueryRowContext(ctx, query, args...)
+	if row != nil {
+		end(row.Err())
+	} else {
+		end(ErrNoResult)
+	}
+
+	return row
+}
+
+type sqlTelemetryConn struct {
+	*sqlTelemetry
+	conn SQLConn
+}
+
+func (t *sqlTelemetryConn) BeginTx(ctx context.Context, opts *sql.TxOptions) (tx *sql.Tx, err error) {
+	ctx, end := t.start(ctx, "BEGIN", "")
+	defer func() { end(err) }()
+
+	return t.conn.BeginTx(ctx, opts)
+}
+
+func (t *sqlTelemetryConn) PingContext(ctx context.Context) (err error) {
+	ctx, end := t.start(ctx, "PING", "")
+	defer func() { end(err) }()
+
+	return t.conn.PingContext(ctx)
+}
+
+func (t *sqlTelemetryConn) Close() (err error) {
+	if t.reg != nil {
+		err = t.reg.Unregister()
+	}
+
+	return new(ListError).Add(err, t.conn.Close()).Err()
+}
+
+// Conn will return a dedicated connection when the wrapped conn is able to.
+func (t *sqlTelemetryConn) Conn(ctx context.Context) (*sql.Conn, error) {
+	c, ok := t.conn.(interface {
+		Conn(ctx context.Context) (*sql.Conn, error)
+	})
+	if !ok {
+		return nil, ErrInvalidDatabase
+	}
+
+	return c.Conn(ctx)
+}
@@ -0,0 +1,170 @@
+package sdk_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/gunawanwijaya/forest/sdk"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdk_metric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdk_trace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func test_SQLTelemetry(t *testing.T) {
+	t.Parallel()
+
+	Expect := NewWithT(t).Expect
+	ctx := context.Background()
+
+	t.Run("sanitize", func(t *testing.T) {
+		x := new(SQL)
+		Expect(x.SanitizeQuery("-- name: A\nSELECT a1, 'it''s' FROM t WHERE b = 42 AND c = $1 /* x */ LIMIT 10")).
+			To(Equal("SELECT a1, ? FROM t WHERE b = ? AND c = $1 LIMIT ?"))
+		Expect(x.SanitizeQuery("UPDATE t SET a = -1.5e3")).To(Equal("UPDATE t SET a = -?"))
+	})
+	t.Run("query-name", func(t *testing.T) {
+		x := new(SQL)
+		x.RegisterQueryName("new_user", "-- name: NewUser :exec\nINSERT INTO u (id, email) VALUES (:id, :email)")
+
+		name, ok := x.QueryName("INSERT INTO u (id, email) VALUES ($1, $2)")
+		Expect(ok).To(BeTrue())
+		Expect(name).To(Equal("new_user"))
+
+		_, ok = x.QueryName("SELECT 1")
+		Expect(ok).To(BeFalse())
+	})
+	t.Run("wrap", func(t *testing.T) {
+		primary, replica := new(countConn), new(countConn)
+		conn, err := new(SQL).WithTelemetry(ctx, new(SQL).NewRoundRobin(ctx, primary, replica), nil)
+		Expect(err).To(Succeed())
+
+		_, _ = conn.QueryContext(ctx, "SELECT 1")
+		_, _ = conn.ExecContext(ctx, "INSERT INTO t VALUES (1)")
+		_ = conn.QueryRowContext(ctx, "SELECT 1")
+		Expect(primary.n).To(Equal(1))
+		Expect(replica.n).To(Equal(2))
+		Expect(conn.Close()).To(Succeed())
+
+		tx, err := new(SQL).WithTxTelemetry(ctx, primary, nil)
+		Expect(err).To(Succeed())
+
+		_, _ = tx.ExecContext(ctx, "DELETE FROM t")
+		Expect(primary.n).To(Equal(2))
+	})
+	t.Run("span-metrics", func(t *testing.T) {
+		exp, reader := tracetest.NewInMemoryExporter(), sdk_metric.NewManualReader()
+		tr, err := OTel.NewTracer(ctx, &TracerConfiguration{Name: "test", Exporters: []sdk_trace.SpanExporter{exp}})
+		Expect(err).To(Succeed())
+
+		defer func() { Expect(tr.Shutdown(ctx)).To(Succeed()) }()
+
+		m := &Meter{Meter: sdk_metric.NewMeterProvider(sdk_metric.WithReader(reader)).Meter("test")}
+		mock := new(SQL).NewMock(ctx, 1)
+		defer mock.Close()
+
+		conn, err := new(SQL).WithTelemetry(m.WithContext(tr.WithContext(ctx)),
+			new(SQL).NewRoundRobin(ctx, mock.Primary(), mock.Replica(1)), nil)
+		Expect(err).To(Succeed())
+
+		new(SQL).RegisterQueryName("get_user", "SELECT id FROM u WHERE email = $1")
+
+		errX := errors.New("x")
+		mock.ExpectQuery(`^SELECT id FROM u WHERE email = \$1$`).WithArgs("a@b.c").OnReplica().
+			WillReturnRows([]string{"id"}, []interface{}{int64(1)})
+		mock.ExpectExec(`^UPDATE u SET name = 'alice'$`).OnPrimary().WillReturnError(errX)
+
+		rows, err := conn.QueryContext(ctx, "SELECT id FROM u WHERE email = $1", "a@b.c")
+		Expect(err).To(Succeed())
+		Expect(rows.Close()).To(Succeed())
+		_, err = conn.ExecContext(ctx, "UPDATE u SET name = 'alice'")
+		Expect(err).To(MatchError(errX))
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+
+		// the spans are named by the registered name and the statement is sanitized
+		Expect(tr.ForceFlush(ctx)).To(Succeed())
+		spans := exp.GetSpans()
+		Expect(spans).To(HaveLen(2))
+		Expect(spans[0].Name).To(Equal("SELECT get_user"))
+		Expect(spans[0].SpanKind).To(Equal(trace.SpanKindClient))
+		Expect(spans[0].Attributes).To(ContainElements(
+			attribute.String("db.system", "postgresql"),
+			attribute.String("db.operation", "SELECT"),
+			attribute.String("db.statement", "SELECT id FROM u WHERE email = $1"),
+			attribute.String("db.sql.name", "get_user"),
+			attribute.Int("db.sql.replica", 1),
+		))
+		Expect(spans[1].Name).To(Equal("UPDATE"))
+		Expect(spans[1].Attributes).To(ContainElements(
+			attribute.String("db.statement", "UPDATE u SET name = ?"),
+			attribute.Int("db.sql.replica", 0),
+		))
+		Expect(spans[1].Status).To(Equal(sdk_trace.Status{Code: codes.Error, Description: "x"}))
+		Expect(spans[1].Events).To(HaveLen(1))
+		Expect(spans[1].Events[0].Name).To(Equal("exception"))
+
+		var rm metricdata.ResourceMetrics
+		Expect(reader.Collect(ctx, &rm)).To(Succeed())
+		Expect(rm.ScopeMetrics).To(HaveLen(1))
+
+		metrics := map[string]metricdata.Aggregation{}
+		for _, mm := range rm.ScopeMetrics[0].Metrics {
+			metrics[mm.Name] = mm.Data
+		}
+
+		// the duration of each operation, by the same attributes as the span
+		duration, ok := metrics["db.client.operation.duration"].(metricdata.Histogram[float64])
+		Expect(ok).To(BeTrue())
+		Expect(duration.DataPoints).To(HaveLen(2))
+
+		for _, dp := range duration.DataPoints {
+			Expect(dp.Count).To(Equal(uint64(1)))
+
+			replica, ok := dp.Attributes.Value("db.sql.replica")
+			Expect(ok).To(BeTrue())
+			Expect(replica.Type()).To(Equal(attribute.INT64))
+
+			if op, _ := dp.Attributes.Value("db.operation"); op.AsString() == "SELECT" {
+				Expect(replica.AsInt64()).To(Equal(int64(1)))
+				Expect(dp.Attributes.HasValue("db.sql.name")).To(BeTrue())
+				Expect(dp.Attributes.HasValue("error.type")).To(BeFalse())
+			} else {
+				Expect(replica.AsInt64()).To(Equal(int64(0)))
+				Expect(dp.Attributes.HasValue("error.type")).To(BeTrue())
+			}
+		}
+
+		errs, ok := metrics["db.client.operation.errors"].(metricdata.Sum[int64])
+		Expect(ok).To(BeTrue())
+		Expect(errs.DataPoints).To(HaveLen(1))
+		Expect(errs.DataPoints[0].Value).To(Equal(int64(1)))
+		Expect(errs.DataPoints[0].Attributes.HasValue("error.type")).To(BeFalse())
+
+		op, _ := errs.DataPoints[0].Attributes.Value("db.operation")
+		Expect(op.AsString()).To(Equal("UPDATE"))
+
+		// the pool statistics of each *sql.DB of the round-robin
+		for _, name := range []string{
+			"db.client.connections.open", "db.client.connections.in_use",
+			"db.client.connections.idle", "db.client.connections.max",
+		} {
+			gauge, ok := metrics[name].(metricdata.Gauge[int64])
+			Expect(ok).To(BeTrue(), name)
+			Expect(gauge.DataPoints).To(HaveLen(2), name)
+		}
+
+		open := metrics["db.client.connections.open"].(metricdata.Gauge[int64])
+		for _, dp := range open.DataPoints {
+			Expect(dp.Value).To(BeNumerically(">=", 1))
+		}
+
+		Expect(metrics).To(HaveKey("db.client.connections.wait_count"))
+		Expect(metrics).To(HaveKey("db.client.connections.wait_duration"))
+		Expect(conn.Close()).To(Succeed())
+	})
+}
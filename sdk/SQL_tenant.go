@@ -0,0 +1,410 @@
+package sdk
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// nolint: gochecknoglobals
+var (
+	ErrTenantRequired            = errors.New("Tenant required")
+	ErrTenantInvalid             = errors.New("Invalid tenant")
+	ErrTenantTransactionRequired = errors.New("Tenant transaction required")
+
+	sqlTenantIdentifier = regexp.MustCompile(`^[a-z][a-z0-9_]{0,47}$`)
+)
+
+type sqlTenantCtxKey struct{}
+
+// ContextWithTenant return a context carrying the tenant, the tenant should be
+// a lowercase identifier (`^[a-z][a-z0-9_]{0,47}$`) as it is part of the schema.
+func (SQL) ContextWithTenant(ctx context.Context, tenant string) (context.Context, error) {
+	if !sqlTenantIdentifier.MatchString(tenant) {
+		return ctx, fmt.Errorf("database: tenant: %w: %q", ErrTenantInvalid, tenant)
+	}
+
+	return context.WithValue(ctx, sqlTenantCtxKey{}, tenant), nil
+}
+
+// TenantFromContext return the tenant set via SQL.ContextWithTenant or MuxTenant.
+func (SQL) TenantFromContext(ctx context.Context) (tenant string, ok bool) {
+	tenant, ok = ctx.Value(sqlTenantCtxKey{}).(string)
+
+	return tenant, ok && tenant != ""
+}
+
+type SQLTenantConfiguration struct {
+	// Schema of the tenant, default to the tenant itself.
+	Schema func(tenant string) string
+
+	// SearchPath after the schema of the tenant, default to public.
+	SearchPath []string
+
+	// Pool return a dedicated conn of the tenant whose search_path is already
+	// set, e.g. SQL.TenantPoolWithDSN; the conn is cached and closed on Close.
+	// When nil, conn is shared by the tenants and `SET LOCAL search_path` is
+	// applied on BeginTx, the statements outside a transaction are refused.
+	Pool func(ctx context.Context, tenant, searchPath string) (SQLConn, error)
+
+	// MaxPools of the tenants kept open, default to 100; the least recently used
+	// pool is closed once it is exceeded and opened again on its next statement.
+	MaxPools int
+}
+
+// WithTenant will wrap conn so that every statement is routed into the schema
+// of the tenant in the context, a statement without tenant is refused.
+//
+//	conn, err := new(sdk.SQL).WithTenant(ctx, conn, &sdk.SQLTenantConfiguration{
+//	  Schema: func(tenant string) string { return "tenant_" + tenant },
+//	})
+//	mux := new(sdk.Mux).Handle(http.MethodGet, "/", sdk.Middleware(
+//	  sdk.MuxTenant(&sdk.MuxTenantConfiguration{HostSuffix: ".example.com"}),
+//	  handler, // tx, err := conn.BeginTx(r.Context(), nil)
+//	))
+func (SQL) WithTenant(ctx context.Context, conn SQLConn, c *SQLTenantConfiguration) (SQLConn, error) {
+	if conn == nil {
+		return nil, fmt.Errorf("database: tenant: %w", ErrInvalidDatabase)
+	}
+
+	cfg := SQLTenantConfiguration{
+		Schema:     func(tenant string) string { return tenant },
+		SearchPath: []string{"public"},
+		MaxPools:   100,
+	}
+	if c != nil {
+		if c.Schema != nil {
+			cfg.Schema = c.Schema
+		}
+
+		if c.SearchPath != nil {
+			cfg.SearchPath = c.SearchPath
+		}
+
+		if c.MaxPools > 0 {
+			cfg.MaxPools = c.MaxPools
+		}
+
+		cfg.Pool = c.Pool
+	}
+
+	for _, s := range cfg.SearchPath {
+		if !sqlIdentifier.MatchString(s) {
+			return nil, fmt.Errorf("database: tenant: %w: search path %q", ErrInvalidValue, s)
+		}
+	}
+
+	return &sqlTenant{SQLConn: conn, cfg: cfg, mu: new(sync.Mutex), pools: map[string]*sqlTenantPool{}}, nil
+}
+
+type sqlTenant struct {
+	SQLConn
+	cfg SQLTenantConfiguration
+
+	mu     *sync.Mutex
+	pools  map[string]*sqlTenantPool
+	tick   int64
+	closed bool
+}
+
+// sqlTenantPool is the pool of a tenant, it is opened once by the first caller
+// while the others wait for ready.
+type sqlTenantPool struct {
+	ready chan struct{}
+	conn  SQLConn
+	err   error
+	used  int64
+}
+
+// searchPath of the tenant in the context, quoted and validated.
+func (x *sqlTenant) searchPath(ctx context.Context) (tenant, searchPath string, err error) {
+	tenant, ok := SQL{}.TenantFromContext(ctx)
+	if !ok {
+		return "", "", fmt.Errorf("database: tenant: %w", ErrTenantRequired)
+	} else if !sqlTenantIdentifier.MatchString(tenant) {
+		return "", "", fmt.Errorf("database: tenant: %w: %q", ErrTenantInvalid, tenant)
+	}
+
+	schema := x.cfg.Schema(tenant)
+	if !sqlColumnIdentifier.MatchString(schema) {
+		return "", "", fmt.Errorf("database: tenant: %w: schema %q", ErrTenantInvalid, schema)
+	}
+
+	return tenant, strings.Join(append([]string{`"` + schema + `"`}, x.cfg.SearchPath...), ", "), nil
+}
+
+// conn return the pool of the tenant, or nil when there is no Pool. The pool
+// is opened outside of the lock, so that a slow or unreachable tenant does not
+// block the others.
+func (x *sqlTenant) conn(ctx context.Context) (SQLConn, string, error) {
+	tenant, searchPath, err := x.searchPath(ctx)
+	if err != nil || x.cfg.Pool == nil {
+		return nil, searchPath, err
+	}
+
+	x.mu.Lock()
+	x.tick++
+
+	p, ok := x.pools[tenant]
+	if !ok {
+		p = &sqlTenantPool{ready: make(chan struct{})}
+		x.pools[tenant] = p
+	}
+
+	p.used = x.tick
+	x.mu.Unlock()
+
+	if !ok {
+		x.open(ctx, tenant, searchPath, p)
+	}
+
+	select {
+	case <-p.ready:
+	case <-ctx.Done():
+		return nil, searchPath, fmt.Errorf("database: tenant: %s: %w", tenant, ctx.Err())
+	}
+
+	return p.conn, searchPath, p.err
+}
+
+// open the pool p of the tenant, a failed pool is forgotten to be retried on the
+// next statement, and the least recently used pools over MaxPools are closed.
+func (x *sqlTenant) open(ctx context.Context, tenant, searchPath string, p *sqlTenantPool) {
+	defer close(p.ready)
+
+	conn, err := x.cfg.Pool(ctx, tenant, searchPath)
+	if err == nil && conn == nil {
+		err = ErrInvalidDatabase
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if err != nil {
+		p.err = fmt.Errorf("database: tenant: %s: %w", tenant, err)
+		if x.pools[tenant] == p {
+			delete(x.pools, tenant)
+		}
+
+		return
+	}
+
+	if x.closed {
+		// Close was called while the pool was being opened
+		p.err = fmt.Errorf("database: tenant: %s: %w", tenant, ErrAlreadyClosed)
+		go func() { _ = conn.Close() }()
+
+		return
+	}
+
+	p.conn = conn
+
+	for len(x.pools) > x.cfg.MaxPools {
+		lru := ""
+		for t, q := range x.pools {
+			if q.conn != nil && t != tenant && (lru == "" || q.used < x.pools[lru].used) {
+				lru = t
+			}
+		}
+
+		if lru == "" {
+			return
+		}
+
+		// Close wait for the statements that are already started
+		go func(conn SQLConn) { _ = conn.Close() }(x.pools[lru].conn)
+		delete(x.pools, lru)
+	}
+}
+
+func (x *sqlTenant) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	conn, searchPath, err := x.conn(ctx)
+	if err != nil {
+		return nil, err
+	} else if conn != nil {
+		return conn.BeginTx(ctx, opts)
+	}
+
+	tx, err := x.SQLConn.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// set_config(..., true) is the parameterized form of `SET LOCAL`
+	if _, err = tx.ExecContext(ctx, "SELECT set_config('search_path', $1, true)", searchPath); err != nil {
+		_ = tx.Rollback()
+
+		return nil, fmt.Errorf("database: tenant: %w", err)
+	}
+
+	return tx, nil
+}
+
+func (x *sqlTenant) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	conn, _, err := x.conn(ctx)
+	if err != nil {
+		return nil, err
+	} else if conn == nil {
+		return nil, fmt.Errorf("database: tenant: %w", ErrTenantTransactionRequired)
+	}
+
+	return conn.ExecContext(ctx, query, args...)
+}
+
+func (x *sqlTenant) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	conn, _, err := x.conn(ctx)
+	if err != nil {
+		return nil, err
+	} else if conn == nil {
+		return nil, fmt.Errorf("database: tenant: %w", ErrTenantTransactionRequired)
+	}
+
+	return conn.PrepareContext(ctx, query)
+}
+
+func (x *sqlTenant) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	conn, _, err := x.conn(ctx)
+	if err != nil {
+		return nil, err
+	} else if conn == nil {
+		return nil, fmt.Errorf("database: tenant: %w", ErrTenantTransactionRequired)
+	}
+
+	return conn.QueryContext(ctx, query, args...)
+}
+
+// QueryRowContext return a *sql.Row that carry ErrTenantRequired or
+// ErrTenantTransactionRequired on Scan when the statement is refused, the
+// statement never reach the shared conn, see sqlErrRow.
+func (x *sqlTenant) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	conn, _, err := x.conn(ctx)
+	if err != nil {
+		return sqlErrRow(err)
+	} else if conn == nil {
+		return sqlErrRow(fmt.Errorf("database: tenant: %w", ErrTenantTransactionRequired))
+	}
+
+	return conn.QueryRowContext(ctx, query, args...)
+}
+
+// PingContext the shared conn and the pools.
+func (x *sqlTenant) PingContext(ctx context.Context) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	errs := new(ListError).Add(x.SQLConn.PingContext(ctx))
+	for _, p := range x.pools {
+		if p.conn != nil {
+			errs = errs.Add(p.conn.PingContext(ctx))
+		}
+	}
+
+	return errs.Err()
+}
+
+// Close the shared conn and the pools.
+func (x *sqlTenant) Close() error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	x.closed = true
+
+	errs := new(ListError).Add(x.SQLConn.Close())
+	for tenant, p := range x.pools {
+		if p.conn != nil {
+			errs = errs.Add(p.conn.Close())
+		}
+
+		delete(x.pools, tenant)
+	}
+
+	return errs.Err()
+}
+
+// TenantPoolWithDSN is a SQLTenantConfiguration.Pool that open a connection of
+// each tenant using SQL.OpenWithDSN with the search_path as startup parameter,
+// the dsn should be a `postgres://` dsn.
+func (SQL) TenantPoolWithDSN(dsn string) func(ctx context.Context, tenant, searchPath string) (SQLConn, error) {
+	return func(ctx context.Context, tenant, searchPath string) (SQLConn, error) {
+		sep := "?"
+		if strings.Contains(dsn, "?") {
+			sep = "&"
+		}
+
+		return SQL{}.OpenWithDSN(ctx, dsn+sep+"search_path="+url.QueryEscape(searchPath))
+	}
+}
+
+// -----------------------------------------------------------------------------
+// Mux
+// -----------------------------------------------------------------------------
+
+type MuxTenantConfiguration struct {
+	// Header carrying the tenant, default to X-Tenant-ID.
+	Header string
+
+	// HostSuffix when set, the tenant is taken from the host, e.g. a request to
+	// `acme.example.com` with `.example.com` is the tenant acme. The header
+	// take precedence over the host.
+	HostSuffix string
+
+	// Required will refuse a request without tenant.
+	Required bool
+}
+
+// MuxTenant is a middleware that set the tenant of the request, see
+// SQL.WithTenant. An invalid (or missing when it is Required) tenant will
+// respond with 400 Bad Request and cancel the request, see CancelRequest.
+func MuxTenant(c *MuxTenantConfiguration) http.Handler {
+	cfg := MuxTenantConfiguration{Header: "X-Tenant-ID"}
+	if c != nil {
+		if c.Header != "" {
+			cfg.Header = c.Header
+		}
+
+		cfg.HostSuffix, cfg.Required = c.HostSuffix, c.Required
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant := r.Header.Get(cfg.Header)
+		if tenant == "" && cfg.HostSuffix != "" {
+			host, _, err := net.SplitHostPort(r.Host)
+			if err != nil {
+				host = r.Host
+			}
+
+			if strings.HasSuffix(host, cfg.HostSuffix) {
+				tenant = strings.TrimSuffix(host, cfg.HostSuffix)
+			}
+		}
+
+		if tenant == "" && !cfg.Required {
+			return
+		}
+
+		ctx, err := SQL{}.ContextWithTenant(r.Context(), tenant)
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			CancelRequest(r)
+
+			return
+		}
+
+		*r = *(r.WithContext(ctx))
+	})
+}
+
+// TenantFromRequest is a helper function that extract the tenant that have
+// been set using MuxTenant.
+func TenantFromRequest(r *http.Request) string {
+	tenant, _ := get(r, sqlTenantCtxKey{}).(string)
+
+	return tenant
+}
@@ -0,0 +1,194 @@
+package sdk_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/gunawanwijaya/forest/sdk"
+	. "github.com/onsi/gomega"
+)
+
+func test_SQLTenant(t *testing.T) {
+	t.Parallel()
+
+	Expect := NewWithT(t).Expect
+	ctx := context.Background()
+
+	t.Run("context", func(t *testing.T) {
+		_, ok := new(SQL).TenantFromContext(ctx)
+		Expect(ok).To(BeFalse())
+
+		tctx, err := new(SQL).ContextWithTenant(ctx, "acme")
+		Expect(err).To(Succeed())
+
+		tenant, ok := new(SQL).TenantFromContext(tctx)
+		Expect(ok).To(BeTrue())
+		Expect(tenant).To(Equal("acme"))
+
+		for _, tenant := range []string{"", "Acme", "1acme", `acme"; DROP SCHEMA public; --`, "acme-corp"} {
+			_, err = new(SQL).ContextWithTenant(ctx, tenant)
+			Expect(err).To(MatchError(ErrTenantInvalid), tenant)
+		}
+	})
+	t.Run("shared", func(t *testing.T) {
+		mock := new(SQL).NewMock(ctx, 0)
+		conn, err := new(SQL).WithTenant(ctx, mock, &SQLTenantConfiguration{
+			Schema: func(tenant string) string { return "tenant_" + tenant },
+		})
+		Expect(err).To(Succeed())
+		defer conn.Close()
+
+		_, err = conn.BeginTx(ctx, nil)
+		Expect(err).To(MatchError(ErrTenantRequired))
+		_, err = conn.QueryContext(ctx, "SELECT 1")
+		Expect(err).To(MatchError(ErrTenantRequired))
+		Expect(conn.QueryRowContext(ctx, "SELECT 1").Scan(new(int))).To(MatchError(ErrTenantRequired))
+
+		tctx, _ := new(SQL).ContextWithTenant(ctx, "acme")
+		_, err = conn.ExecContext(tctx, "DELETE FROM users")
+		Expect(err).To(MatchError(ErrTenantTransactionRequired))
+		Expect(conn.QueryRowContext(tctx, "SELECT 1").Scan(new(int))).To(MatchError(ErrTenantTransactionRequired))
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`SELECT set_config\('search_path', \$1, true\)`).WithArgs(`"tenant_acme", public`)
+		mock.ExpectExec(`DELETE FROM users`)
+		mock.ExpectCommit()
+
+		tx, err := conn.BeginTx(tctx, nil)
+		Expect(err).To(Succeed())
+		_, err = tx.ExecContext(tctx, "DELETE FROM users")
+		Expect(err).To(Succeed())
+		Expect(tx.Commit()).To(Succeed())
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+	})
+	t.Run("pool", func(t *testing.T) {
+		mock := new(SQL).NewMock(ctx, 0)
+		pools := map[string]*SQLMock{}
+		conn, err := new(SQL).WithTenant(ctx, mock, &SQLTenantConfiguration{
+			SearchPath: []string{"public", "extensions"},
+			Pool: func(ctx context.Context, tenant, searchPath string) (SQLConn, error) {
+				Expect(searchPath).To(Equal(`"` + tenant + `", public, extensions`))
+
+				pools[tenant] = new(SQL).NewMock(ctx, 0)
+				pools[tenant].ExpectExec(`DELETE FROM users`)
+				pools[tenant].ExpectExec(`DELETE FROM users`)
+
+				return pools[tenant], nil
+			},
+		})
+		Expect(err).To(Succeed())
+
+		// the pool is created on the first statement of each tenant, then reused
+		for _, tenant := range []string{"acme", "globex", "acme"} {
+			tctx, _ := new(SQL).ContextWithTenant(ctx, tenant)
+			_, err = conn.ExecContext(tctx, "DELETE FROM users")
+			Expect(err).To(Succeed())
+		}
+
+		Expect(pools).To(HaveLen(2))
+		Expect(pools["acme"].ExpectationsWereMet()).To(Succeed())
+		Expect(pools["globex"].ExpectationsWereMet()).NotTo(Succeed())
+		Expect(conn.Close()).To(Succeed())
+
+		_, err = new(SQL).WithTenant(ctx, mock, &SQLTenantConfiguration{SearchPath: []string{"public; --"}})
+		Expect(err).To(MatchError(ErrInvalidValue))
+	})
+	t.Run("pool-slow", func(t *testing.T) {
+		g := NewWithT(t)
+		slow, opened := make(chan struct{}), make(chan struct{})
+		conn, err := new(SQL).WithTenant(ctx, new(SQL).NewMock(ctx, 0), &SQLTenantConfiguration{
+			Pool: func(ctx context.Context, tenant, searchPath string) (SQLConn, error) {
+				pool := new(SQL).NewMock(ctx, 0)
+				pool.ExpectExec(`DELETE FROM users`)
+				if tenant == "slow" {
+					close(opened)
+					<-slow
+				}
+
+				return pool, nil
+			},
+		})
+		g.Expect(err).To(Succeed())
+		defer conn.Close()
+
+		// a tenant whose pool is still opening does not block the others
+		errc := make(chan error, 2)
+		for i := 0; i < 2; i++ {
+			go func() {
+				tctx, _ := new(SQL).ContextWithTenant(ctx, "slow")
+				_, err := conn.ExecContext(tctx, "DELETE FROM users")
+				errc <- err
+			}()
+		}
+		<-opened
+
+		tctx, _ := new(SQL).ContextWithTenant(ctx, "fast")
+		_, err = conn.ExecContext(tctx, "DELETE FROM users")
+		g.Expect(err).To(Succeed())
+		g.Consistently(errc).ShouldNot(Receive())
+
+		// the waiting statement share the pool opened once, and the mock expects
+		// only one statement
+		close(slow)
+		g.Eventually(errc).Should(Receive(BeNil()))
+		g.Eventually(errc).Should(Receive(HaveOccurred()))
+	})
+	t.Run("pool-evict", func(t *testing.T) {
+		opened := map[string]int{}
+		conn, err := new(SQL).WithTenant(ctx, new(SQL).NewMock(ctx, 0), &SQLTenantConfiguration{
+			MaxPools: 2,
+			Pool: func(ctx context.Context, tenant, searchPath string) (SQLConn, error) {
+				opened[tenant]++
+				pool := new(SQL).NewMock(ctx, 0)
+				pool.ExpectExec(`DELETE FROM users`)
+				pool.ExpectExec(`DELETE FROM users`)
+
+				return pool, nil
+			},
+		})
+		Expect(err).To(Succeed())
+		defer conn.Close()
+
+		// globex is the least recently used when initech is opened
+		for _, tenant := range []string{"acme", "globex", "acme", "initech", "globex"} {
+			tctx, _ := new(SQL).ContextWithTenant(ctx, tenant)
+			_, err = conn.ExecContext(tctx, "DELETE FROM users")
+			Expect(err).To(Succeed(), tenant)
+		}
+
+		Expect(opened).To(Equal(map[string]int{"acme": 1, "globex": 2, "initech": 1}))
+	})
+	t.Run("mux", func(t *testing.T) {
+		var tenant string
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { tenant = TenantFromRequest(r) })
+		h := Middleware(MuxTenant(&MuxTenantConfiguration{HostSuffix: ".example.com"}), next)
+
+		for _, v := range []struct {
+			host, header, tenant string
+			code                 int
+		}{
+			{"acme.example.com:8080", "", "acme", http.StatusOK},
+			{"acme.example.com", "globex", "globex", http.StatusOK},
+			{"example.org", "", "", http.StatusOK},
+			{"Acme.example.com", "", "", http.StatusBadRequest},
+		} {
+			tenant = ""
+			r := httptest.NewRequest(http.MethodGet, "http://"+v.host+"/", nil)
+			if v.header != "" {
+				r.Header.Set("X-Tenant-ID", v.header)
+			}
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			Expect(w.Code).To(Equal(v.code), v.host)
+			Expect(tenant).To(Equal(v.tenant), v.host)
+		}
+
+		w := httptest.NewRecorder()
+		Middleware(MuxTenant(&MuxTenantConfiguration{Required: true}), next).
+			ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+		Expect(w.Code).To(Equal(http.StatusBadRequest))
+	})
+}
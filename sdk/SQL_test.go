@@ -0,0 +1,56 @@
+package sdk_test
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	. "github.com/gunawanwijaya/forest/sdk"
+	. "github.com/onsi/gomega"
+)
+
+func test_SQL(t *testing.T) {
+	t.Parallel()
+
+	Expect := NewWithT(t).Expect
+	errX := errors.New("x")
+
+	t.Run("remove-comment", func(t *testing.T) {
+		x := new(SQL)
+		Expect(x.RemoveComment("-- name: A :exec\nINSERT INTO a (b) VALUES ('--', $1) /* c */")).
+			To(Equal("INSERT INTO a (b) VALUES ('--', $1)"))
+		Expect(x.IsValidCommand(x.RemoveComment("-- insert when login\nINSERT INTO a DEFAULT VALUES"))).To(BeTrue())
+		Expect(x.IsValidCommand(x.RemoveComment("-- select product"))).To(BeFalse())
+	})
+	t.Run("explain", func(t *testing.T) {
+		x := new(SQL)
+		Expect(x.IsSELECTCommand("EXPLAIN (FORMAT JSON) UPDATE t SET a = 1")).To(BeTrue())
+		Expect(x.IsSELECTCommand("explain delete from t")).To(BeTrue())
+		Expect(x.IsValidCommand("EXPLAIN ANALYZE UPDATE t SET a = 1")).To(BeFalse())
+		Expect(x.IsDMLCommand("EXPLAIN (FORMAT JSON) UPDATE t SET a = 1")).To(BeFalse())
+
+		// the options are parsed rather than searched, an EXPLAIN ANALYZE is routed
+		// by the explained statement
+		for query, expect := range map[string]bool{
+			"EXPLAIN SELECT * FROM analyze_jobs":                           true,
+			"EXPLAIN (ANALYZE) SELECT 1":                                   true,
+			"EXPLAIN ANALYZE UPDATE t SET x = (SELECT 1)":                  false,
+			"explain analyse verbose\ndelete from t where a in (select 1)": false,
+			"EXPLAIN (FORMAT JSON, ANALYZE) INSERT INTO t SELECT 1":        false,
+			"EXPLAIN (ANALYZE FALSE, FORMAT JSON) UPDATE t SET a = 1":      true,
+			"EXPLAIN (ANALYZE ON) WITH x AS (DELETE FROM t) SELECT 1":      false,
+		} {
+			Expect(x.IsSELECTCommand(query)).To(Equal(expect), query)
+		}
+	})
+	t.Run("box-query-row", func(t *testing.T) {
+		row := new(SQL).BoxQueryRow(nil, errX)
+		Expect(row.Err()).To(MatchError(errX))
+		Expect(row.Scan(new(int))).To(MatchError(errX))
+
+		row = new(SQL).BoxQueryRow(nil, nil)
+		Expect(row.Err()).To(Succeed())
+		Expect(row.Scan()).To(MatchError(ErrInvalidArgumentsScan))
+		Expect(row.Scan(new(int))).To(MatchError(sql.ErrNoRows))
+	})
+}